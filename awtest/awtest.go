@@ -0,0 +1,194 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+/*
+Package awtest provides utilities for testing AwGo workflows without Alfred.
+
+A Harness creates a throwaway Alfred-like environment (temporary cache and
+data directories, bundle ID, version etc.) and a Workflow bound to it.
+Harness.Run calls your workflow's entry point in-process, captures the
+feedback that would have been sent to Alfred, and intercepts the calls to
+os.Exit that Workflow makes after a fatal error or a magic action.
+
+	func TestScriptFilter(t *testing.T) {
+		h := awtest.New()
+		defer h.Close()
+
+		// Configure the environment before calling Workflow()
+		h.Env[aw.EnvVarDebug] = "1"
+
+		wf = h.Workflow()
+		h.Run(run, "query")
+
+		fb := h.Feedback(t)
+		fb.AssertCount(t, 2)
+		fb.AssertVar(t, "mode", "search")
+		fb.Items[0].AssertArg(t, "https://www.example.com")
+	}
+
+The parsed feedback (Feedback, Item and Modifier) mirrors Alfred's Script
+Filter JSON and provides typed assertions that report failures via
+testing.TB.
+
+Harness.Run swaps os.Args for the duration of the run, so tests that use
+a Harness must not run in parallel with other tests that read os.Args.
+*/
+package awtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+
+	"go.deanishe.net/env"
+
+	aw "github.com/ChicK00o/awgo"
+	"github.com/ChicK00o/awgo/internal/testenv"
+)
+
+// Default values for the test environment. Change them by altering
+// Harness.Env before calling Harness.Workflow().
+const (
+	DefaultBundleID      = "net.deanishe.awgo.test"
+	DefaultName          = "AwGo Test"
+	DefaultVersion       = "1.0.0"
+	DefaultUID           = "user.workflow.00000000-0000-0000-0000-000000000000"
+	DefaultAlfredVersion = "4.6"
+	DefaultAlfredBuild   = "1271"
+)
+
+// NewEnv returns a minimal Alfred environment whose cache and data
+// directories are subdirectories of dir. The directories are not created.
+//
+// The debug flag is off. Set aw.EnvVarDebug to "1" to turn it on.
+func NewEnv(dir string) env.MapEnv {
+	return env.MapEnv{
+		aw.EnvVarBundleID:      DefaultBundleID,
+		aw.EnvVarName:          DefaultName,
+		aw.EnvVarVersion:       DefaultVersion,
+		aw.EnvVarUID:           DefaultUID,
+		aw.EnvVarDebug:         "0",
+		aw.EnvVarAlfredVersion: DefaultAlfredVersion,
+		aw.EnvVarAlfredBuild:   DefaultAlfredBuild,
+		aw.EnvVarCacheDir:      testenv.CacheDir(dir),
+		aw.EnvVarDataDir:       testenv.DataDir(dir),
+	}
+}
+
+// Harness runs workflow code in a throwaway Alfred environment.
+//
+// Create Harnesses with New() and delete their temporary directory
+// with Close().
+type Harness struct {
+	// Dir is the temporary directory the environment's cache and
+	// data directories are in.
+	Dir string
+	// Env is the environment the Workflow is created from. Changes
+	// made after the first call to Workflow() have no effect on
+	// the cache and data directories.
+	Env env.MapEnv
+
+	opts     []aw.Option
+	wf       *aw.Workflow
+	out      bytes.Buffer
+	exited   bool
+	exitCode int
+}
+
+// New creates a Harness with a new temporary directory and an environment
+// created by NewEnv. Options are passed to the Workflow the Harness creates.
+// It panics if the temporary directory cannot be created.
+func New(opts ...aw.Option) *Harness {
+	dir, err := testenv.TempDir("awtest-")
+	if err != nil {
+		panic(err)
+	}
+	return &Harness{Dir: dir, Env: NewEnv(dir), opts: opts}
+}
+
+// Workflow returns the Workflow under test, creating it (and its cache and
+// data directories) from Env on the first call.
+//
+// The Workflow writes its feedback to the Harness and calls the Harness
+// instead of os.Exit.
+func (h *Harness) Workflow() *aw.Workflow {
+	if h.wf != nil {
+		return h.wf
+	}
+
+	if err := testenv.MkDirs(h.Env[aw.EnvVarCacheDir], h.Env[aw.EnvVarDataDir]); err != nil {
+		panic(err)
+	}
+
+	opts := append([]aw.Option{aw.Output(&h.out), aw.ExitFunc(h.exit)}, h.opts...)
+	h.wf = aw.NewFromEnv(h.Env, opts...)
+	return h.wf
+}
+
+// Run calls fn via Workflow.Run with args as the program's command-line
+// arguments (i.e. os.Args[1:]). It returns when fn returns or the Workflow
+// tries to exit the program.
+func (h *Harness) Run(fn func(), args ...string) {
+	wf := h.Workflow()
+
+	prev := os.Args
+	os.Args = append([]string{prev[0]}, args...)
+	defer func() { os.Args = prev }()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wf.Run(fn)
+	}()
+	<-done
+}
+
+// exit records the status code and stops the goroutine running the workflow.
+func (h *Harness) exit(code int) {
+	h.exited = true
+	h.exitCode = code
+	runtime.Goexit()
+}
+
+// Exited returns true and the exit status if the Workflow tried to
+// terminate the program.
+func (h *Harness) Exited() (code int, exited bool) { return h.exitCode, h.exited }
+
+// Output returns everything the Workflow has written to its output.
+func (h *Harness) Output() []byte { return h.out.Bytes() }
+
+// Feedback parses the workflow's output as Script Filter JSON. It fails
+// the test immediately if the output isn't valid feedback.
+func (h *Harness) Feedback(tb testing.TB) *Feedback {
+	tb.Helper()
+	fb, err := ParseFeedback(h.Output())
+	if err != nil {
+		tb.Fatalf("invalid feedback: %v\n%s", err, h.Output())
+	}
+	return fb
+}
+
+// AssertExit fails the test if the Workflow did not try to exit with
+// status code.
+func (h *Harness) AssertExit(tb testing.TB, code int) bool {
+	tb.Helper()
+	if !h.exited {
+		tb.Errorf("workflow did not exit, expected exit status %d", code)
+		return false
+	}
+	if h.exitCode != code {
+		tb.Errorf("unexpected exit status: expected=%d, actual=%d", code, h.exitCode)
+		return false
+	}
+	return true
+}
+
+// Close deletes the Harness's temporary directory.
+func (h *Harness) Close() error {
+	if err := os.RemoveAll(h.Dir); err != nil {
+		return fmt.Errorf("delete temporary directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package awtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	aw "github.com/ChicK00o/awgo"
+	"github.com/ChicK00o/awgo/util"
+)
+
+// Harness creates a valid environment.
+func TestHarness_Workflow(t *testing.T) {
+	h := New()
+	defer h.Close()
+
+	h.Env[aw.EnvVarDebug] = "1"
+	wf := h.Workflow()
+
+	assert.Equal(t, DefaultBundleID, wf.BundleID(), "unexpected bundle ID")
+	assert.Equal(t, DefaultVersion, wf.Version(), "unexpected version")
+	assert.True(t, wf.Debug(), "debug flag not set")
+	assert.True(t, util.PathExists(wf.CacheDir()), "cache dir does not exist")
+	assert.True(t, util.PathExists(wf.DataDir()), "data dir does not exist")
+	assert.Equal(t, wf, h.Workflow(), "Workflow not cached")
+
+	require.Nil(t, h.Close(), "close harness")
+	assert.False(t, util.PathExists(h.Dir), "temp dir not deleted")
+}
+
+// Feedback is captured and parsed.
+func TestHarness_Run(t *testing.T) {
+	h := New()
+	defer h.Close()
+
+	var args []string
+	wf := h.Workflow()
+	h.Run(func() {
+		args = wf.Args()
+		wf.Var("mode", "search").Rerun(0.5)
+		it := wf.NewItem("one").Arg("1").Valid(true).Var("id", "1")
+		it.Cmd().Arg("a", "b").Subtitle("multiple")
+		wf.NewItem("two")
+		wf.SendFeedback()
+	}, "query")
+
+	assert.Equal(t, []string{"query"}, args, "unexpected args")
+	_, exited := h.Exited()
+	assert.False(t, exited, "workflow exited")
+
+	fb := h.Feedback(t)
+	fb.AssertCount(t, 2)
+	fb.AssertTitles(t, "one", "two")
+	fb.AssertVar(t, "mode", "search")
+	fb.AssertRerun(t, 0.5)
+
+	it := fb.AssertItem(t, "one")
+	require.NotNil(t, it, "item not found")
+	it.AssertArg(t, "1")
+	it.AssertValid(t, true)
+	it.AssertVar(t, "id", "1")
+	it.AssertVar(t, "mode", "search")
+
+	m := it.AssertMod(t, aw.ModCmd)
+	require.NotNil(t, m, "modifier not found")
+	m.AssertArg(t, "a", "b")
+	m.AssertSubtitle(t, "multiple")
+	m.AssertValid(t, true)
+	m.AssertVar(t, "id", "1")
+
+	it = fb.AssertItem(t, "two")
+	require.NotNil(t, it, "item not found")
+	it.AssertArg(t)
+	it.AssertValid(t, false)
+}
+
+// Fatal errors and panics are shown in feedback and exit is intercepted.
+func TestHarness_Run_fatal(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(wf *aw.Workflow)
+	}{
+		{"Fatal", func(wf *aw.Workflow) { wf.FatalError(errors.New("oops")) }},
+		{"panic", func(wf *aw.Workflow) { panic("oops") }},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			h := New()
+			defer h.Close()
+
+			var reached bool
+			wf := h.Workflow()
+			h.Run(func() {
+				td.fn(wf)
+				reached = true
+			})
+
+			assert.False(t, reached, "workflow not stopped")
+			h.AssertExit(t, 1)
+			fb := h.Feedback(t)
+			fb.AssertTitles(t, "oops")
+		})
+	}
+}
+
+// Text errors are written to output.
+func TestHarness_Run_textErrors(t *testing.T) {
+	h := New(aw.TextErrors(true))
+	defer h.Close()
+
+	wf := h.Workflow()
+	h.Run(func() { wf.Fatal("oops") })
+	h.AssertExit(t, 1)
+	assert.Equal(t, "oops", string(h.Output()), "unexpected output")
+}
+
+// Magic actions exit the workflow.
+func TestHarness_Run_magic(t *testing.T) {
+	h := New()
+	defer h.Close()
+
+	wf := h.Workflow()
+	p := wf.Cache.Dir + "/test.txt"
+	require.Nil(t, wf.Cache.Store("test.txt", []byte("test")), "store data")
+
+	var reached bool
+	h.Run(func() {
+		wf.Args()
+		reached = true
+	}, "workflow:delcache")
+
+	assert.False(t, reached, "workflow not stopped")
+	h.AssertExit(t, 0)
+	assert.False(t, util.PathExists(p), "cache not cleared")
+	h.Feedback(t).AssertCount(t, 1)
+}
+
+// Args are parsed from strings and arrays, and invalid feedback is rejected.
+func TestParseFeedback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in  string
+		x   Args
+		err bool
+	}{
+		{`{"items":[{"title":"t","arg":"one"}]}`, Args{"one"}, false},
+		{`{"items":[{"title":"t","arg":["one","two"]}]}`, Args{"one", "two"}, false},
+		{`{"items":[{"title":"t"}]}`, nil, false},
+		{`{"items":[{"title":"t","arg":1}]}`, nil, true},
+		{`{"items":[{"title":"t","mods":{"cmd":null}}]}`, nil, false},
+		{`{"items":[{"arg":"one"}]}`, nil, true},
+		{`{"variables":{}}`, nil, true},
+		{`oops`, nil, true},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.in, func(t *testing.T) {
+			t.Parallel()
+			fb, err := ParseFeedback([]byte(td.in))
+			if td.err {
+				assert.NotNil(t, err, "invalid feedback accepted")
+				return
+			}
+			require.Nil(t, err, "parse feedback")
+			assert.Equal(t, td.x, fb.Items[0].Arg, "unexpected arg")
+		})
+	}
+}
+
+// Valid defaults to true and modifiers inherit it from their Item.
+func TestParseFeedback_valid(t *testing.T) {
+	t.Parallel()
+
+	fb, err := ParseFeedback([]byte(`{"items":[
+		{"title":"default","mods":{"cmd":{},"alt":{"valid":false}}},
+		{"title":"invalid","valid":false,"mods":{"cmd":{},"alt":{"valid":true}}}
+	]}`))
+	require.Nil(t, err, "parse feedback")
+
+	it := fb.Item("default")
+	it.AssertValid(t, true)
+	it.AssertMod(t, "cmd").AssertValid(t, true)
+	it.AssertMod(t, "alt").AssertValid(t, false)
+
+	it = fb.Item("invalid")
+	it.AssertValid(t, false)
+	it.AssertMod(t, "cmd").AssertValid(t, false)
+	it.AssertMod(t, "alt").AssertValid(t, true)
+}
+
+// Failed assertions are reported.
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+
+	fb, err := ParseFeedback([]byte(`{"rerun":1,"items":[{"title":"t","mods":{"cmd":{"arg":"x"}}}]}`))
+	require.Nil(t, err, "parse feedback")
+
+	mt := &mockTB{TB: t}
+	assert.False(t, fb.AssertCount(mt, 2), "count")
+	assert.False(t, fb.AssertTitles(mt, "u"), "titles")
+	assert.False(t, fb.AssertRerun(mt, 2), "rerun")
+	assert.False(t, fb.AssertVar(mt, "k", "v"), "var")
+	assert.Nil(t, fb.AssertItem(mt, "u"), "item")
+
+	it := fb.Items[0]
+	assert.False(t, it.AssertArg(mt, "x"), "item arg")
+	assert.False(t, it.AssertValid(mt, false), "item valid")
+	assert.Nil(t, it.AssertMod(mt, "alt"), "item mod")
+
+	m := it.AssertMod(t, "cmd")
+	require.NotNil(t, m, "modifier not found")
+	assert.Equal(t, "cmd", m.Key, "modifier key")
+	assert.False(t, m.AssertArg(mt, "y"), "modifier arg")
+	assert.False(t, m.AssertVar(mt, "k", "v"), "modifier var")
+	assert.True(t, mt.failed, "failures not reported")
+}
+
+// mockTB records failures instead of failing the test.
+type mockTB struct {
+	testing.TB
+	failed bool
+}
+
+func (tb *mockTB) Helper()                                   {}
+func (tb *mockTB) Errorf(format string, args ...interface{}) { tb.failed = true }
+func (tb *mockTB) Fatalf(format string, args ...interface{}) { tb.failed = true }
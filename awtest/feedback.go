@@ -0,0 +1,284 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package awtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	aw "github.com/ChicK00o/awgo"
+)
+
+// Args is an Item or Modifier arg. Alfred accepts a string or an array
+// of strings. Both are unmarshalled to a slice.
+type Args []string
+
+// UnmarshalJSON accepts a JSON string or array of strings.
+func (a *Args) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = Args{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(data, &l); err != nil {
+		return fmt.Errorf("arg is neither string nor array of strings: %s", data)
+	}
+	*a = Args(l)
+	return nil
+}
+
+// Icon is a parsed Item or Modifier icon.
+type Icon struct {
+	Value string `json:"path"`
+	Type  string `json:"type"`
+}
+
+// Text is an Item's copy & largetype text.
+type Text struct {
+	Copy  string `json:"copy"`
+	Large string `json:"largetype"`
+}
+
+// Modifier is a parsed Item modifier. If its JSON has no valid, Valid is
+// inherited from the Item.
+type Modifier struct {
+	Key       string            `json:"-"`
+	Arg       Args              `json:"arg"`
+	Subtitle  string            `json:"subtitle"`
+	Valid     bool              `json:"valid"`
+	Icon      *Icon             `json:"icon"`
+	Variables map[string]string `json:"variables"`
+
+	valid *bool // valid as set in JSON
+}
+
+// UnmarshalJSON decodes a Modifier. Valid is set by ParseFeedback.
+func (m *Modifier) UnmarshalJSON(data []byte) error {
+	type modifier Modifier
+	v := struct {
+		*modifier
+		Valid *bool `json:"valid"`
+	}{modifier: (*modifier)(m)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.valid = v.Valid
+	return nil
+}
+
+// Item is a parsed Script Filter result. As in Alfred, Valid is true if
+// the JSON has no valid.
+type Item struct {
+	Title        string               `json:"title"`
+	Subtitle     string               `json:"subtitle"`
+	Match        string               `json:"match"`
+	Autocomplete string               `json:"autocomplete"`
+	Arg          Args                 `json:"arg"`
+	UID          string               `json:"uid"`
+	Valid        bool                 `json:"valid"`
+	Type         string               `json:"type"`
+	Text         *Text                `json:"text"`
+	Icon         *Icon                `json:"icon"`
+	Quicklook    string               `json:"quicklookurl"`
+	Variables    map[string]string    `json:"variables"`
+	Mods         map[string]*Modifier `json:"mods"`
+	Action       map[string]Args      `json:"action"`
+}
+
+// Feedback is parsed Script Filter JSON.
+type Feedback struct {
+	Variables map[string]string `json:"variables"`
+	Rerun     float64           `json:"rerun"`
+	Items     []*Item           `json:"items"`
+}
+
+// ParseFeedback parses Script Filter JSON. The JSON is decoded and
+// validated by aw.Feedback, so it is read the same way AwGo reads it.
+func ParseFeedback(data []byte) (*Feedback, error) {
+	var v struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if v.Items == nil {
+		return nil, errors.New("no items array")
+	}
+
+	src := &aw.Feedback{}
+	if err := json.Unmarshal(data, src); err != nil {
+		return nil, err
+	}
+	if err := src.Validate(); err != nil {
+		return nil, err
+	}
+	// read the normalised JSON aw.Feedback generates
+	data, err := src.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	fb := &Feedback{}
+	if err := json.Unmarshal(data, fb); err != nil {
+		return nil, err
+	}
+	for _, it := range fb.Items {
+		for k, m := range it.Mods {
+			m.Key = k
+			m.Valid = it.Valid
+			if m.valid != nil {
+				m.Valid = *m.valid
+			}
+		}
+	}
+	return fb, nil
+}
+
+// Titles returns the titles of all Items.
+func (fb *Feedback) Titles() []string {
+	titles := make([]string, len(fb.Items))
+	for i, it := range fb.Items {
+		titles[i] = it.Title
+	}
+	return titles
+}
+
+// Item returns the first Item with the given title or nil.
+func (fb *Feedback) Item(title string) *Item {
+	for _, it := range fb.Items {
+		if it.Title == title {
+			return it
+		}
+	}
+	return nil
+}
+
+// AssertCount fails the test if Feedback does not contain n Items.
+func (fb *Feedback) AssertCount(tb testing.TB, n int) bool {
+	tb.Helper()
+	if len(fb.Items) != n {
+		tb.Errorf("unexpected item count: expected=%d, actual=%d %q", n, len(fb.Items), fb.Titles())
+		return false
+	}
+	return true
+}
+
+// AssertTitles fails the test if the titles of the Items are not
+// exactly titles (in the same order).
+func (fb *Feedback) AssertTitles(tb testing.TB, titles ...string) bool {
+	tb.Helper()
+	return assertEqual(tb, "titles", titles, fb.Titles())
+}
+
+// AssertVar fails the test if top-level variable key is not set to value.
+func (fb *Feedback) AssertVar(tb testing.TB, key, value string) bool {
+	tb.Helper()
+	return assertVar(tb, "feedback", fb.Variables, key, value)
+}
+
+// AssertRerun fails the test if Feedback's rerun is not secs.
+func (fb *Feedback) AssertRerun(tb testing.TB, secs float64) bool {
+	tb.Helper()
+	return assertEqual(tb, "rerun", secs, fb.Rerun)
+}
+
+// AssertItem fails the test if there is no Item with the given title. It
+// returns the Item or nil.
+func (fb *Feedback) AssertItem(tb testing.TB, title string) *Item {
+	tb.Helper()
+	it := fb.Item(title)
+	if it == nil {
+		tb.Errorf("no item with title %q in %q", title, fb.Titles())
+	}
+	return it
+}
+
+// AssertArg fails the test if Item's arg is not arg.
+func (it *Item) AssertArg(tb testing.TB, arg ...string) bool {
+	tb.Helper()
+	return assertEqual(tb, fmt.Sprintf("arg of %q", it.Title), normArgs(arg), normArgs(it.Arg))
+}
+
+// AssertValid fails the test if Item's valid is not v.
+func (it *Item) AssertValid(tb testing.TB, v bool) bool {
+	tb.Helper()
+	return assertEqual(tb, fmt.Sprintf("valid of %q", it.Title), v, it.Valid)
+}
+
+// AssertVar fails the test if Item variable key is not set to value.
+func (it *Item) AssertVar(tb testing.TB, key, value string) bool {
+	tb.Helper()
+	return assertVar(tb, fmt.Sprintf("item %q", it.Title), it.Variables, key, value)
+}
+
+// AssertMod fails the test if Item has no Modifier for key, e.g. "cmd"
+// or "alt+cmd". It returns the Modifier or nil.
+func (it *Item) AssertMod(tb testing.TB, key string) *Modifier {
+	tb.Helper()
+	m, ok := it.Mods[key]
+	if !ok {
+		tb.Errorf("item %q has no %q modifier", it.Title, key)
+		return nil
+	}
+	return m
+}
+
+// AssertArg fails the test if Modifier's arg is not arg.
+func (m *Modifier) AssertArg(tb testing.TB, arg ...string) bool {
+	tb.Helper()
+	return assertEqual(tb, fmt.Sprintf("arg of %q modifier", m.Key), normArgs(arg), normArgs(m.Arg))
+}
+
+// AssertSubtitle fails the test if Modifier's subtitle is not s.
+func (m *Modifier) AssertSubtitle(tb testing.TB, s string) bool {
+	tb.Helper()
+	return assertEqual(tb, fmt.Sprintf("subtitle of %q modifier", m.Key), s, m.Subtitle)
+}
+
+// AssertValid fails the test if Modifier's valid is not v.
+func (m *Modifier) AssertValid(tb testing.TB, v bool) bool {
+	tb.Helper()
+	return assertEqual(tb, fmt.Sprintf("valid of %q modifier", m.Key), v, m.Valid)
+}
+
+// AssertVar fails the test if Modifier variable key is not set to value.
+func (m *Modifier) AssertVar(tb testing.TB, key, value string) bool {
+	tb.Helper()
+	return assertVar(tb, fmt.Sprintf("%q modifier", m.Key), m.Variables, key, value)
+}
+
+// normArgs returns an empty Args for nil, so no arg equals an unset arg.
+func normArgs(a []string) Args {
+	if a == nil {
+		return Args{}
+	}
+	return Args(a)
+}
+
+func assertEqual(tb testing.TB, what string, expected, actual interface{}) bool {
+	tb.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		tb.Errorf("unexpected %s: expected=%#v, actual=%#v", what, expected, actual)
+		return false
+	}
+	return true
+}
+
+func assertVar(tb testing.TB, owner string, vars map[string]string, key, value string) bool {
+	tb.Helper()
+	v, ok := vars[key]
+	if !ok {
+		tb.Errorf("variable %q not set on %s", key, owner)
+		return false
+	}
+	if v != value {
+		tb.Errorf("unexpected value for variable %q on %s: expected=%q, actual=%q", key, owner, value, v)
+		return false
+	}
+	return true
+}
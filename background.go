@@ -9,7 +9,6 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strconv"
 	"syscall"
 
@@ -59,15 +58,34 @@ func (wf *Workflow) RunInBackground(jobName string, cmd *exec.Cmd) error {
 	return wf.savePid(jobName, cmd.Process.Pid)
 }
 
-// Kill stops a background job.
+// Kill stops a background job by sending it SIGTERM.
+//
+// Jobs started with RunJob are stopped with their whole process group, so
+// any processes the job's command started are also stopped, and processes
+// that are still running 2 seconds later are killed (SIGKILL). Only the
+// process of a job started with RunInBackground is sent SIGTERM.
 func (wf *Workflow) Kill(jobName string) error {
 	pid, err := wf.getPid(jobName)
 	if err != nil {
 		return err
 	}
 	p := wf.pidFile(jobName)
-	err = syscall.Kill(pid, syscall.SIGTERM)
+	j := wf.Job(jobName)
+	if !util.PathExists(j.recordFile()) {
+		err = syscall.Kill(pid, syscall.SIGTERM)
+		os.Remove(p)
+		return err
+	}
+
+	// tell wrapper job was killed, not timed out
+	if err := util.WriteFile(j.killedFile(), []byte{}, 0600); err != nil {
+		return fmt.Errorf("record kill: %w", err)
+	}
+	err = killGroup(pid)
 	os.Remove(p)
+	if err == nil {
+		err = j.recordKilled()
+	}
 	return err
 }
 
@@ -105,6 +123,5 @@ func (wf *Workflow) getPid(jobName string) (int, error) {
 
 // Path to PID file for job.
 func (wf *Workflow) pidFile(jobName string) string {
-	dir := util.MustExist(filepath.Join(wf.awCacheDir(), "jobs"))
-	return filepath.Join(dir, jobName+".pid")
+	return wf.jobFile(jobName, ".pid")
 }
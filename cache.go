@@ -4,6 +4,7 @@
 package aw
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -30,8 +31,9 @@ func init() {
 
 // Cache implements a simple store/load API, saving data to specified directory.
 //
-// There are two APIs, one for storing/loading bytes and one for
-// marshalling and storing/loading and unmarshalling JSON.
+// There are three APIs, one for storing/loading bytes, one for
+// marshalling and storing/loading and unmarshalling JSON, and one
+// (the *Value methods) for serialising values with a Codec set via SetCodec.
 //
 // Each API has basic Store/Load functions plus a LoadOrStore function which
 // loads cached data if these exist and aren't too old, or retrieves new data
@@ -41,18 +43,65 @@ func init() {
 // for the on-disk cache, so make sure it's filesystem-safe, and consider
 // adding an appropriate extension to the name, e.g. use "name.txt" (or
 // "name.json" with LoadOrStoreJSON).
+//
+// By default, a Cache grows without limit. Set MaxSize and/or MaxEntries
+// to limit it: when a Store call takes the cache over quota, other entries
+// are deleted according to Eviction. See also Prune and List.
 type Cache struct {
 	Dir string // Directory to save data in
+
+	MaxSize    int64          // Maximum total size of cached data in bytes. 0 = no limit.
+	MaxEntries int            // Maximum number of cached files. 0 = no limit.
+	Eviction   EvictionPolicy // Which entries to delete first when over quota
+
+	codec   Codec            // Default Codec for *Value methods
+	codecs  map[string]Codec // Per-name Codecs for *Value methods
+	exclude map[string]bool  // Files in Dir that aren't cache entries
 }
 
 // NewCache creates a new Cache using given directory.
 // Directory is created if it doesn't exist. Panics if directory can't be created.
 func NewCache(dir string) *Cache {
 	util.MustExist(dir)
-	return &Cache{dir}
+	return &Cache{Dir: dir}
+}
+
+// SetCodec sets the Codec used by the *Value methods. If names are given,
+// codec is only used for caches with those names, otherwise it is the
+// default for all names. Pass a nil codec to revert to the default
+// (JSONCodec).
+func (c *Cache) SetCodec(codec Codec, names ...string) {
+	if len(names) == 0 {
+		c.codec = codec
+		return
+	}
+	if c.codecs == nil {
+		c.codecs = map[string]Codec{}
+	}
+	for _, name := range names {
+		if codec == nil {
+			delete(c.codecs, name)
+		} else {
+			c.codecs[name] = codec
+		}
+	}
+}
+
+// Codec returns the Codec used by the *Value methods for the named cache.
+func (c Cache) Codec(name string) Codec {
+	if codec, ok := c.codecs[name]; ok {
+		return codec
+	}
+	if c.codec != nil {
+		return c.codec
+	}
+	return JSONCodec
 }
 
 // Store saves data under the given name. If data is nil, the cache is deleted.
+//
+// Data are written atomically (see util.WriteFile), so concurrent readers
+// never see a partially-written cache.
 func (c Cache) Store(name string, data []byte) error {
 	p := c.path(name)
 	if data == nil {
@@ -61,7 +110,13 @@ func (c Cache) Store(name string, data []byte) error {
 		}
 		return nil
 	}
-	return util.WriteFile(p, data, 0600)
+	if err := util.WriteFile(p, data, 0600); err != nil {
+		return err
+	}
+	if c.MaxSize > 0 || c.MaxEntries > 0 {
+		return c.trim(name)
+	}
+	return nil
 }
 
 // StoreJSON serialises v to JSON and saves it to the cache. If v is nil,
@@ -84,47 +139,104 @@ func (c Cache) StoreJSON(name string, v interface{}) error {
 // Load reads data saved under given name.
 func (c Cache) Load(name string) ([]byte, error) {
 	p := c.path(name)
-	if _, err := os.Stat(p); err != nil {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
 		return nil, err
 	}
-	return ioutil.ReadFile(p)
+	if c.Eviction == EvictLRU {
+		c.touch(p, fi)
+	}
+	return data, nil
 }
 
 // LoadJSON unmarshals named cache into v.
 func (c Cache) LoadJSON(name string, v interface{}) error {
-	p := c.path(name)
-	data, err := ioutil.ReadFile(p)
+	data, err := c.Load(name)
 	if err != nil {
 		return fmt.Errorf("read file: %w", err)
 	}
 	return json.Unmarshal(data, v)
 }
 
+// StoreValue serialises v with the named cache's Codec and saves it to
+// the cache. If v is nil, the cache is deleted.
+func (c Cache) StoreValue(name string, v interface{}) error {
+	return c.storeWith(name, c.Codec(name), v)
+}
+
+// LoadValue deserialises the named cache into v with the cache's Codec.
+func (c Cache) LoadValue(name string, v interface{}) error {
+	data, err := c.Load(name)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	return c.Codec(name).Decode(data, v)
+}
+
 // LoadOrStore loads data from cache if they exist and are newer than maxAge.
 // If data do not exist or are older than maxAge, the reload function is
 // called, and the returned data are saved to the cache and also returned.
 //
 // If maxAge is 0, any cached data are always returned.
 func (c Cache) LoadOrStore(name string, maxAge time.Duration, reload func() ([]byte, error)) ([]byte, error) {
-	var load bool
-	age, err := c.Age(name)
+	return c.LoadOrStoreContext(context.Background(), name, maxAge, func(context.Context) ([]byte, error) {
+		return reload()
+	})
+}
+
+// LoadOrStoreContext is like LoadOrStore, but ctx is passed to the reload
+// function. If ctx is cancelled, nothing is cached and ctx's error is returned.
+//
+// Reloading is protected by an advisory lock, so if several processes try
+// to reload the same cache at once, only one calls reload. The others
+// return the previous data if these exist, or otherwise wait for the
+// reload to finish and load its result.
+func (c Cache) LoadOrStoreContext(ctx context.Context, name string, maxAge time.Duration, reload func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if !c.needsReload(name, maxAge) {
+		return c.Load(name)
+	}
+
+	lock := util.NewLockFile(c.lockPath(name))
+	ok, err := lock.TryLock()
 	if err != nil {
-		load = true
-	} else if maxAge > 0 && age > maxAge {
-		load = true
+		return nil, fmt.Errorf("lock cache: %w", err)
 	}
-	// log.Printf("age=%v, maxAge=%v, load=%v", age, maxAge, load)
-	if load {
-		data, err := reload()
-		if err != nil {
-			return nil, fmt.Errorf("reload data: %w", err)
+	if !ok {
+		if c.Exists(name) {
+			log.Printf("[cache] %q is being reloaded by another process, using previous data", name)
+			return c.Load(name)
 		}
-		if err := c.Store(name, data); err != nil {
-			return nil, err
+		log.Printf("[cache] waiting for another process to reload %q ...", name)
+		if err := lock.Lock(ctx); err != nil {
+			return nil, fmt.Errorf("lock cache: %w", err)
+		}
+	}
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			log.Printf("[cache] %v", err)
 		}
-		return data, nil
+	}()
+
+	// Another process may have reloaded the data while we were waiting
+	if !c.needsReload(name, maxAge) {
+		return c.Load(name)
+	}
+
+	data, err := reload(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reload data: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("reload data: %w", err)
 	}
-	return c.Load(name)
+	if err := c.Store(name, data); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
 // LoadOrStoreJSON loads JSON-serialised data from cache if they exist and are
@@ -134,38 +246,60 @@ func (c Cache) LoadOrStore(name string, maxAge time.Duration, reload func() ([]b
 //
 // If maxAge is 0, any cached data are loaded regardless of age.
 func (c Cache) LoadOrStoreJSON(name string, maxAge time.Duration, reload func() (interface{}, error), v interface{}) error {
-	var (
-		load bool
-		data []byte
-		err  error
-	)
-	age, err := c.Age(name)
+	return c.LoadOrStoreJSONContext(context.Background(), name, maxAge, func(context.Context) (interface{}, error) {
+		return reload()
+	}, v)
+}
+
+// LoadOrStoreJSONContext is like LoadOrStoreJSON, but ctx is passed to the
+// reload function. If ctx is cancelled, nothing is cached and ctx's error
+// is returned.
+func (c Cache) LoadOrStoreJSONContext(ctx context.Context, name string, maxAge time.Duration, reload func(ctx context.Context) (interface{}, error), v interface{}) error {
+	return c.loadOrStoreWith(ctx, name, maxAge, indentJSONCodec{}, reload, v)
+}
+
+// LoadOrStoreValue is like LoadOrStoreJSON, but the data are serialised
+// with the named cache's Codec.
+func (c Cache) LoadOrStoreValue(name string, maxAge time.Duration, reload func() (interface{}, error), v interface{}) error {
+	return c.LoadOrStoreValueContext(context.Background(), name, maxAge, func(context.Context) (interface{}, error) {
+		return reload()
+	}, v)
+}
+
+// LoadOrStoreValueContext is like LoadOrStoreValue, but ctx is passed to
+// the reload function. If ctx is cancelled, nothing is cached and ctx's
+// error is returned.
+func (c Cache) LoadOrStoreValueContext(ctx context.Context, name string, maxAge time.Duration, reload func(ctx context.Context) (interface{}, error), v interface{}) error {
+	return c.loadOrStoreWith(ctx, name, maxAge, c.Codec(name), reload, v)
+}
+
+// storeWith serialises v with codec and caches it. If v is nil, the
+// cache is deleted.
+func (c Cache) storeWith(name string, codec Codec, v interface{}) error {
+	if v == nil {
+		return c.Store(name, nil)
+	}
+	data, err := codec.Encode(v)
 	if err != nil {
-		load = true
-	} else if maxAge > 0 && age > maxAge {
-		load = true
+		return err
 	}
+	return c.Store(name, data)
+}
 
-	if load {
-		i, err := reload()
-		if err != nil {
-			return fmt.Errorf("reload data: %w", err)
-		}
-		data, err = json.MarshalIndent(i, "", "  ")
-		if err != nil {
-			return fmt.Errorf("marshal data to JSON: %w", err)
-		}
-		if err := c.Store(name, data); err != nil {
-			return err
-		}
-	} else {
-		data, err = c.Load(name)
+// loadOrStoreWith implements LoadOrStoreJSON and LoadOrStoreValue.
+func (c Cache) loadOrStoreWith(ctx context.Context, name string, maxAge time.Duration, codec Codec, reload func(ctx context.Context) (interface{}, error), v interface{}) error {
+	data, err := c.LoadOrStoreContext(ctx, name, maxAge, func(ctx context.Context) ([]byte, error) {
+		i, err := reload(ctx)
 		if err != nil {
-			return fmt.Errorf("load cached data: %w", err)
+			return nil, err
 		}
+		return codec.Encode(i)
+	})
+	if err != nil {
+		return err
 	}
-	// TODO: Is there any way to directly return i without marshalling and unmarshalling it?
-	return json.Unmarshal(data, v)
+	// TODO: Is there any way to directly return i without encoding and decoding it?
+	return codec.Decode(data, v)
 }
 
 // Exists returns true if the named cache exists.
@@ -190,6 +324,19 @@ func (c Cache) Age(name string) (time.Duration, error) {
 	return time.Since(fi.ModTime()), nil
 }
 
+// needsReload returns true if the named cache doesn't exist or is older
+// than maxAge. If maxAge is 0, existing data never need reloading.
+func (c Cache) needsReload(name string, maxAge time.Duration) bool {
+	age, err := c.Age(name)
+	if err != nil {
+		return true
+	}
+	return maxAge > 0 && age > maxAge
+}
+
+// lockPath returns the path of the lock file for a named cache.
+func (c Cache) lockPath(name string) string { return c.path(name) + ".lock" }
+
 // path returns the path to a named file within cache directory.
 func (c Cache) path(name string) string { return filepath.Join(c.Dir, name) }
 
@@ -217,7 +364,7 @@ type Session struct {
 
 // NewSession creates and initialises a Session.
 func NewSession(dir, sessionID string) *Session {
-	s := &Session{sessionID, NewCache(dir)}
+	s := &Session{SessionID: sessionID, cache: NewCache(dir)}
 	return s
 }
 
@@ -267,6 +414,12 @@ func (s Session) StoreJSON(name string, v interface{}) error {
 	return s.cache.StoreJSON(s.name(name), v)
 }
 
+// StoreValue serialises v with the named cache's Codec and saves it to
+// the cache. If v is nil, the cache is deleted.
+func (s Session) StoreValue(name string, v interface{}) error {
+	return s.cache.StoreValue(s.name(name), v)
+}
+
 // Load reads data saved under given name.
 func (s Session) Load(name string) ([]byte, error) {
 	return s.cache.Load(s.name(name))
@@ -277,12 +430,23 @@ func (s Session) LoadJSON(name string, v interface{}) error {
 	return s.cache.LoadJSON(s.name(name), v)
 }
 
+// LoadValue deserialises the named cache into v with the cache's Codec.
+func (s Session) LoadValue(name string, v interface{}) error {
+	return s.cache.LoadValue(s.name(name), v)
+}
+
 // LoadOrStore loads data from cache if they exist. If data do not exist,
 // reload is called, and the resulting data are cached & returned.
 func (s Session) LoadOrStore(name string, reload func() ([]byte, error)) ([]byte, error) {
 	return s.cache.LoadOrStore(s.name(name), 0, reload)
 }
 
+// LoadOrStoreContext is like LoadOrStore, but ctx is passed to the reload
+// function.
+func (s Session) LoadOrStoreContext(ctx context.Context, name string, reload func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	return s.cache.LoadOrStoreContext(ctx, s.name(name), 0, reload)
+}
+
 // LoadOrStoreJSON loads JSON-serialised data from cache if they exist.
 // If the data do not exist, reload is called, and the resulting interface{}
 // is cached and returned.
@@ -290,6 +454,40 @@ func (s Session) LoadOrStoreJSON(name string, reload func() (interface{}, error)
 	return s.cache.LoadOrStoreJSON(s.name(name), 0, reload, v)
 }
 
+// LoadOrStoreJSONContext is like LoadOrStoreJSON, but ctx is passed to the
+// reload function.
+func (s Session) LoadOrStoreJSONContext(ctx context.Context, name string, reload func(ctx context.Context) (interface{}, error), v interface{}) error {
+	return s.cache.LoadOrStoreJSONContext(ctx, s.name(name), 0, reload, v)
+}
+
+// LoadOrStoreValue is like LoadOrStoreJSON, but the data are serialised
+// with the named cache's Codec.
+func (s Session) LoadOrStoreValue(name string, reload func() (interface{}, error), v interface{}) error {
+	return s.cache.LoadOrStoreValue(s.name(name), 0, reload, v)
+}
+
+// LoadOrStoreValueContext is like LoadOrStoreValue, but ctx is passed to
+// the reload function.
+func (s Session) LoadOrStoreValueContext(ctx context.Context, name string, reload func(ctx context.Context) (interface{}, error), v interface{}) error {
+	return s.cache.LoadOrStoreValueContext(ctx, s.name(name), 0, reload, v)
+}
+
+// SetCodec sets the Codec used by the *Value methods. If names are given,
+// codec is only used for caches with those names, otherwise it is the
+// default for all names.
+func (s Session) SetCodec(codec Codec, names ...string) {
+	prefixed := make([]string, len(names))
+	for i, name := range names {
+		prefixed[i] = s.name(name)
+	}
+	s.cache.SetCodec(codec, prefixed...)
+}
+
+// Codec returns the Codec used by the *Value methods for the named cache.
+func (s Session) Codec(name string) Codec {
+	return s.cache.Codec(s.name(name))
+}
+
 // Exists returns true if the named cache exists.
 func (s Session) Exists(name string) bool {
 	return s.cache.Exists(s.name(name))
@@ -0,0 +1,18 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// atime returns a file's access time.
+func atime(fi os.FileInfo) time.Time {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atimespec.Unix())
+	}
+	return fi.ModTime()
+}
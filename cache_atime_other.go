@@ -0,0 +1,16 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package aw
+
+import (
+	"os"
+	"time"
+)
+
+// atime returns a file's modification time, as access time isn't
+// available on this platform.
+func atime(fi os.FileInfo) time.Time { return fi.ModTime() }
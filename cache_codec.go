@@ -0,0 +1,115 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Codec serialises values for Cache and Session.
+//
+// The Store/Load*Value methods of Cache and Session use the Codec set with
+// SetCodec. AwGo provides JSONCodec, GobCodec and the Gzip wrapper.
+type Codec interface {
+	// Encode serialises v.
+	Encode(v interface{}) ([]byte, error)
+	// Decode deserialises data into v.
+	Decode(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec serialises values to compact JSON. It is the default Codec.
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec serialises values with encoding/gob, which is considerably
+	// faster to decode than JSON, especially for large data.
+	GobCodec Codec = gobCodec{}
+)
+
+// Gzip returns a Codec that compresses the output of codec with gzip.
+func Gzip(codec Codec) Codec { return gzipCodec{codec} }
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal JSON: %w", err)
+	}
+	return data, nil
+}
+
+func (jsonCodec) Decode(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unmarshal JSON: %w", err)
+	}
+	return nil
+}
+
+// indentJSONCodec is used by the *JSON methods of Cache for backwards
+// compatibility.
+type indentJSONCodec struct{ jsonCodec }
+
+func (indentJSONCodec) Encode(v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal data to JSON: %w", err)
+	}
+	return data, nil
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, fmt.Errorf("encode gob: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte, v interface{}) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return fmt.Errorf("decode gob: %w", err)
+	}
+	return nil
+}
+
+type gzipCodec struct {
+	codec Codec
+}
+
+func (c gzipCodec) Encode(v interface{}) ([]byte, error) {
+	data, err := c.codec.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("compress data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("compress data: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gzipCodec) Decode(data []byte, v interface{}) error {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decompress data: %w", err)
+	}
+	defer r.Close()
+
+	if data, err = ioutil.ReadAll(r); err != nil {
+		return fmt.Errorf("decompress data: %w", err)
+	}
+	return c.codec.Decode(data, v)
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Codecs round-trip data.
+func TestCodecs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{"JSON", JSONCodec},
+		{"gob", GobCodec},
+		{"gzip JSON", Gzip(JSONCodec)},
+		{"gzip gob", Gzip(GobCodec)},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			t.Parallel()
+			a := &TestData{"one", "two"}
+			data, err := td.codec.Encode(a)
+			require.Nil(t, err, "encode failed")
+
+			b := &TestData{}
+			require.Nil(t, td.codec.Decode(data, b), "decode failed")
+			assert.Equal(t, a, b, "unexpected data")
+
+			assert.NotNil(t, td.codec.Decode([]byte("not valid"), b), "invalid data decoded")
+		})
+	}
+}
+
+// Gzip compresses data.
+func TestGzip(t *testing.T) {
+	t.Parallel()
+
+	v := bytes.Repeat([]byte("a"), 1000)
+	data, err := Gzip(GobCodec).Encode(v)
+	require.Nil(t, err, "encode failed")
+	assert.True(t, bytes.HasPrefix(data, []byte{0x1f, 0x8b}), "data not gzipped")
+	assert.Less(t, len(data), 100, "data not compressed")
+}
+
+// Codecs are selected per name or per Cache.
+func TestCache_SetCodec(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		c := NewCache(dir)
+		assert.Equal(t, JSONCodec, c.Codec("a"), "unexpected default codec")
+
+		c.SetCodec(GobCodec)
+		c.SetCodec(Gzip(JSONCodec), "b", "c")
+		assert.Equal(t, GobCodec, c.Codec("a"), "default codec not set")
+		assert.Equal(t, Gzip(JSONCodec), c.Codec("b"), "codec for name not set")
+
+		c.SetCodec(nil, "b")
+		assert.Equal(t, GobCodec, c.Codec("b"), "codec for name not removed")
+		c.SetCodec(nil)
+		assert.Equal(t, JSONCodec, c.Codec("a"), "default codec not reset")
+		assert.Equal(t, Gzip(JSONCodec), c.Codec("c"), "codec for name reset")
+	})
+}
+
+// Round-trip data through the Value caching API.
+func TestCache_StoreValue(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		n := "test.gob.gz"
+		c := NewCache(dir)
+		c.SetCodec(Gzip(GobCodec), n)
+
+		a := &TestData{"one", "two"}
+		require.Nil(t, c.StoreValue(n, a), "cache data failed")
+
+		data, err := ioutil.ReadFile(c.path(n))
+		require.Nil(t, err, "read cache file")
+		assert.True(t, bytes.HasPrefix(data, []byte{0x1f, 0x8b}), "data not gzipped")
+
+		b := &TestData{}
+		require.Nil(t, c.LoadValue(n, b), "load data failed")
+		assert.Equal(t, a, b, "unexpected data")
+
+		// Delete store
+		require.Nil(t, c.StoreValue(n, nil), "clear cached data failed")
+		assert.False(t, c.Exists(n), "deleted data exist")
+		assert.NotNil(t, c.LoadValue(n, b), "load non-existent data succeeded")
+
+		var reloaded bool
+		reload := func() (interface{}, error) {
+			reloaded = true
+			return a, nil
+		}
+		b = &TestData{}
+		require.Nil(t, c.LoadOrStoreValue(n, 0, reload, b), "load/store failed")
+		assert.True(t, reloaded, "reload not called")
+		assert.Equal(t, a, b, "unexpected data")
+
+		reloaded = false
+		b = &TestData{}
+		require.Nil(t, c.LoadOrStoreValue(n, 0, reload, b), "load/store failed")
+		assert.False(t, reloaded, "reload called")
+		assert.Equal(t, a, b, "unexpected data")
+	})
+}
+
+// Session supports Codecs.
+func TestSession_StoreValue(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		var (
+			n = "test.gob"
+			s = NewSession(dir, NewSessionID())
+			a = &TestData{"one", "two"}
+			b = &TestData{}
+		)
+		s.SetCodec(GobCodec, n)
+		assert.Equal(t, GobCodec, s.Codec(n), "codec not set")
+		assert.Equal(t, JSONCodec, s.cache.Codec(n), "codec set without session prefix")
+
+		require.Nil(t, s.StoreValue(n, a), "cache data failed")
+		require.Nil(t, s.LoadValue(n, b), "load data failed")
+		assert.Equal(t, a, b, "unexpected data")
+
+		data, err := s.Load(n)
+		require.Nil(t, err, "load data failed")
+		assert.NotNil(t, JSONCodec.Decode(data, b), "data are JSON")
+
+		b = &TestData{}
+		reload := func() (interface{}, error) { return nil, nil }
+		require.Nil(t, s.LoadOrStoreValue(n, reload, b), "load/store failed")
+		assert.Equal(t, a, b, "unexpected data")
+	})
+}
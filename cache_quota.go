@@ -0,0 +1,167 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EvictionPolicy determines which entries a Cache deletes first when it
+// exceeds its quota.
+type EvictionPolicy int
+
+// Valid EvictionPolicy values.
+const (
+	// EvictOldest deletes the entries that were stored longest ago first.
+	EvictOldest EvictionPolicy = iota
+	// EvictLRU deletes the least recently loaded entries first.
+	// Cache records access times when Load* methods are called.
+	EvictLRU
+)
+
+// String implements Stringer.
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictOldest:
+		return "oldest-first"
+	case EvictLRU:
+		return "LRU"
+	default:
+		return fmt.Sprintf("EvictionPolicy(%d)", int(p))
+	}
+}
+
+// CacheEntry contains metadata about a file in a Cache.
+type CacheEntry struct {
+	Name     string    // Name of cache, as passed to Store
+	Size     int64     // Size of file in bytes
+	Modified time.Time // When data were last stored
+	Accessed time.Time // When data were last loaded (or stored)
+}
+
+// Age returns the time since the entry was stored.
+func (e CacheEntry) Age() time.Duration { return time.Since(e.Modified) }
+
+// List returns metadata about all entries in the Cache, sorted by name.
+// Subdirectories, hidden files and lock files are ignored.
+func (c Cache) List() ([]CacheEntry, error) {
+	infos, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("read directory (%s): %w", c.Dir, err)
+	}
+
+	var entries []CacheEntry
+	for _, fi := range infos {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() || strings.HasPrefix(name, ".") ||
+			strings.HasSuffix(name, ".lock") || c.exclude[name] {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Name:     name,
+			Size:     fi.Size(),
+			Modified: fi.ModTime(),
+			Accessed: atime(fi),
+		})
+	}
+	// ReadDir sorts by name
+	return entries, nil
+}
+
+// Prune deletes all entries older than maxAge.
+func (c Cache) Prune(maxAge time.Duration) error {
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Age() <= maxAge {
+			continue
+		}
+		if err := c.remove(e); err != nil {
+			return err
+		}
+		log.Printf("[cache] pruned %q (age=%v)", e.Name, e.Age().Round(time.Second))
+	}
+	return nil
+}
+
+// Trim deletes entries according to Eviction until the Cache is within
+// MaxSize and MaxEntries. Store calls Trim automatically.
+func (c Cache) Trim() error { return c.trim("") }
+
+// trim enforces the Cache's quota, but does not delete entry keep.
+func (c Cache) trim(keep string) error {
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+	}
+	n := len(entries)
+	over := func() bool {
+		return (c.MaxSize > 0 && size > c.MaxSize) || (c.MaxEntries > 0 && n > c.MaxEntries)
+	}
+	if !over() {
+		return nil
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if c.Eviction == EvictLRU {
+			return entries[i].Accessed.Before(entries[j].Accessed)
+		}
+		return entries[i].Modified.Before(entries[j].Modified)
+	})
+
+	for _, e := range entries {
+		if !over() {
+			break
+		}
+		if e.Name == keep {
+			continue
+		}
+		if err := c.remove(e); err != nil {
+			return err
+		}
+		log.Printf("[cache] evicted %q (%d bytes, %v)", e.Name, e.Size, c.Eviction)
+		size -= e.Size
+		n--
+	}
+	return nil
+}
+
+// remove deletes a cache entry.
+func (c Cache) remove(e CacheEntry) error {
+	if err := os.Remove(c.path(e.Name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete cache %q: %w", e.Name, err)
+	}
+	return nil
+}
+
+// touch records that a cache file was accessed for LRU eviction.
+func (c Cache) touch(path string, fi os.FileInfo) {
+	if err := os.Chtimes(path, time.Now(), fi.ModTime()); err != nil {
+		log.Printf("[cache] record access: %v", err)
+	}
+}
+
+// excludeFiles marks files in the cache directory that aren't cache
+// entries, e.g. the workflow's log files.
+func (c *Cache) excludeFiles(names ...string) {
+	if c.exclude == nil {
+		c.exclude = map[string]bool{}
+	}
+	for _, name := range names {
+		c.exclude[name] = true
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeAged caches data and sets their modification and access times.
+func storeAged(t *testing.T, c *Cache, name, data string, mtime, atime time.Time) {
+	require.Nil(t, c.Store(name, []byte(data)), "store %q", name)
+	require.Nil(t, os.Chtimes(c.path(name), atime, mtime), "set times of %q", name)
+}
+
+func entryNames(t *testing.T, c *Cache) []string {
+	entries, err := c.List()
+	require.Nil(t, err, "list cache")
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// List returns cache entries and ignores other files.
+func TestCache_List(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		c := NewCache(dir)
+		c.excludeFiles("excluded.log")
+		then := time.Now().Add(-time.Hour)
+
+		storeAged(t, c, "b.txt", "bb", then, then)
+		require.Nil(t, c.Store("a.txt", []byte("a")), "store")
+		for _, name := range []string{".hidden", "a.txt.lock", "excluded.log"} {
+			require.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("x"), 0600), "write %q", name)
+		}
+		require.Nil(t, os.Mkdir(filepath.Join(dir, "subdir"), 0700), "create subdir")
+
+		entries, err := c.List()
+		require.Nil(t, err, "list cache")
+		require.Equal(t, 2, len(entries), "unexpected entries: %v", entries)
+		assert.Equal(t, "a.txt", entries[0].Name, "unexpected name")
+		assert.Equal(t, int64(1), entries[0].Size, "unexpected size")
+		assert.Equal(t, "b.txt", entries[1].Name, "unexpected name")
+		assert.Equal(t, int64(2), entries[1].Size, "unexpected size")
+		assert.True(t, entries[1].Age() > 59*time.Minute, "unexpected age")
+	})
+}
+
+// Prune deletes old entries.
+func TestCache_Prune(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		c := NewCache(dir)
+		then := time.Now().Add(-time.Hour)
+		storeAged(t, c, "old.txt", "old", then, then)
+		require.Nil(t, c.Store("new.txt", []byte("new")), "store")
+
+		require.Nil(t, c.Prune(time.Minute), "prune")
+		assert.Equal(t, []string{"new.txt"}, entryNames(t, c), "unexpected entries")
+	})
+}
+
+// Caches over quota evict entries.
+func TestCache_quota(t *testing.T) {
+	t.Parallel()
+
+	var (
+		now   = time.Now()
+		hour1 = now.Add(-time.Hour)
+		hour2 = now.Add(-2 * time.Hour)
+		hour3 = now.Add(-3 * time.Hour)
+	)
+
+	tests := []struct {
+		name       string
+		maxSize    int64
+		maxEntries int
+		policy     EvictionPolicy
+		x          []string
+	}{
+		{"no limit", 0, 0, EvictOldest, []string{"a", "b", "c", "d"}},
+		{"entries oldest", 0, 2, EvictOldest, []string{"c", "d"}},
+		{"entries LRU", 0, 2, EvictLRU, []string{"a", "d"}},
+		{"size oldest", 7, 0, EvictOldest, []string{"b", "c", "d"}},
+		{"size LRU", 5, 0, EvictLRU, []string{"a", "d"}},
+		// new entry is never evicted
+		{"too big", 1, 0, EvictOldest, []string{"d"}},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			t.Parallel()
+			withTempDir(func(dir string) {
+				c := NewCache(dir)
+				// a is oldest, but most recently used
+				storeAged(t, c, "a", "aa", hour3, now)
+				storeAged(t, c, "b", "bb", hour2, hour3)
+				storeAged(t, c, "c", "cc", hour1, hour2)
+
+				c.MaxSize = td.maxSize
+				c.MaxEntries = td.maxEntries
+				c.Eviction = td.policy
+				require.Nil(t, c.Store("d", []byte("dd")), "store")
+				assert.Equal(t, td.x, entryNames(t, c), "unexpected entries")
+			})
+		})
+	}
+}
+
+// Load records access time for LRU eviction.
+func TestCache_Load_LRU(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		c := NewCache(dir)
+		then := time.Now().Add(-time.Hour)
+		storeAged(t, c, "test", "data", then, then)
+
+		c.Eviction = EvictLRU
+		_, err := c.Load("test")
+		require.Nil(t, err, "load")
+
+		entries, err := c.List()
+		require.Nil(t, err, "list")
+		assert.True(t, time.Since(entries[0].Accessed) < time.Minute, "access time not updated")
+		assert.True(t, entries[0].Age() > 59*time.Minute, "modification time changed")
+	})
+}
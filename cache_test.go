@@ -4,8 +4,11 @@
 package aw
 
 import (
+	"context"
 	"errors"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -254,6 +257,97 @@ func TestCache_reloadError(t *testing.T) {
 	})
 }
 
+// Only one concurrent LoadOrStore reloads data.
+func TestCache_LoadOrStore_concurrent(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		var (
+			c     = NewCache(dir)
+			n     = "test.txt"
+			calls int32
+			wg    sync.WaitGroup
+		)
+
+		reload := func() ([]byte, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(100 * time.Millisecond)
+			return []byte("data"), nil
+		}
+
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				data, err := c.LoadOrStore(n, 0, reload)
+				assert.Nil(t, err, "load/store failed")
+				assert.Equal(t, []byte("data"), data, "unexpected data")
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "reload not called exactly once")
+	})
+}
+
+// Previous data are returned while another process reloads them.
+func TestCache_LoadOrStore_locked(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		var (
+			c    = NewCache(dir)
+			n    = "test.txt"
+			then = time.Now().Add(-time.Hour)
+		)
+		require.Nil(t, c.Store(n, []byte("old")), "store failed")
+		require.Nil(t, os.Chtimes(c.path(n), then, then), "set mtime")
+
+		lock := util.NewLockFile(c.lockPath(n))
+		ok, err := lock.TryLock()
+		require.Nil(t, err, "lock failed")
+		require.True(t, ok, "not locked")
+		defer lock.Unlock()
+
+		reload := func() ([]byte, error) { return []byte("new"), nil }
+		data, err := c.LoadOrStore(n, time.Minute, reload)
+		require.Nil(t, err, "load/store failed")
+		assert.Equal(t, []byte("old"), data, "unexpected data")
+	})
+}
+
+// Nothing is cached if the Context is cancelled.
+func TestCache_LoadOrStoreContext(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		var (
+			c           = NewCache(dir)
+			n           = "test"
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+
+		reload := func(ctx context.Context) ([]byte, error) {
+			cancel()
+			return []byte("data"), nil
+		}
+		_, err := c.LoadOrStoreContext(ctx, n, 0, reload)
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+		assert.False(t, c.Exists(n), "data cached")
+
+		reloadJSON := func(ctx context.Context) (interface{}, error) {
+			return &TestData{A: "a"}, nil
+		}
+		v := &TestData{}
+		err = c.LoadOrStoreJSONContext(ctx, n, 0, reloadJSON, v)
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+		assert.False(t, c.Exists(n), "data cached")
+
+		data, err := c.LoadOrStoreContext(context.Background(), n, 0, reload)
+		require.Nil(t, err, "load/store failed")
+		assert.Equal(t, []byte("data"), data, "unexpected data")
+	})
+}
+
 // Session-scoped caching.
 func TestSession_Load(t *testing.T) {
 	t.Parallel()
@@ -6,6 +6,7 @@ package aw
 import (
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -70,13 +71,29 @@ var runJS = func(script string) error {
 //	    // handle error
 //	}
 //
+// SetInt(), SetBool(), SetDuration() and SetJSON() are typed counterparts
+// of Set(). Queued changes are returned by Pending() and can be discarded
+// with Rollback(). The Get* methods return queued and saved values, so
+// changes are visible before Alfred passes them to the next run.
+//
 // Finally, you can use Config.To() to populate a struct from environment
 // variables, and Config.From() to read a struct's fields and save them
 // to info.plist.
+//
+// By default, Do() asks Alfred to save variables if the workflow is running
+// in Alfred, and otherwise (or if Alfred can't be reached) edits info.plist
+// directly. Set Backend to choose a specific method.
 type Config struct {
 	Env
-	reader  env.Reader
-	scripts []string
+	Backend   ConfigBackend // How Do() saves variables. Default: BackendAuto
+	InfoPlist string        // Path of info.plist. Default: found from working directory
+
+	reader      env.Reader
+	scripts     []string
+	changes     []ConfigChange
+	saved       map[string]*string // Changes saved by Do(). nil means unset.
+	err         error              // Error from a typed setter
+	lastBackend ConfigBackend
 }
 
 // NewConfig creates a new Config from the environment.
@@ -90,11 +107,13 @@ func NewConfig(e ...Env) *Config {
 	} else {
 		ev = env.System
 	}
-	return &Config{
+	cfg := &Config{
 		Env:     ev,
-		reader:  env.New(ev),
 		scripts: []string{},
 	}
+	// read via Lookup, so Get* see pending changes
+	cfg.reader = env.New(cfg)
+	return cfg
 }
 
 // Get returns the value for envvar "key".
@@ -162,6 +181,7 @@ func (cfg *Config) Set(key, value string, export bool, bundleID ...string) *Conf
 		"exportable": export,
 	}
 
+	cfg.changes = append(cfg.changes, ConfigChange{Key: key, Value: value, Export: export, BundleID: bid})
 	return cfg.addScript(scriptSetConfig, key, opts)
 }
 
@@ -176,26 +196,68 @@ func (cfg *Config) Unset(key string, bundleID ...string) *Config {
 		"inWorkflow": bid,
 	}
 
+	cfg.changes = append(cfg.changes, ConfigChange{Key: key, Unset: true, BundleID: bid})
 	return cfg.addScript(scriptRmConfig, key, opts)
 }
 
-// Do calls Alfred and runs the accumulated actions.
+// Do saves the accumulated changes using Config's Backend, and logs which
+// backend was used. LastBackend also returns it.
 //
-// Returns an error if there are no commands to run, or if the call to Alfred fails.
-// Succeed or fail, any accumulated scripts and errors are cleared when Do()
-// is called.
+// Returns an error if there are no commands to run, if a typed setter
+// failed, or if saving the changes fails. Succeed or fail, any accumulated
+// scripts and errors are cleared when Do() is called.
+//
+// Saved changes remain visible to the Get* methods for the rest of the run,
+// as Alfred only passes the new values to the next run of the workflow.
 func (cfg *Config) Do() error {
-	if len(cfg.scripts) == 0 {
+	var (
+		script  = strings.Join(cfg.scripts, "\n")
+		changes = cfg.changes
+		setErr  = cfg.err
+	)
+	// reset
+	cfg.Rollback()
+
+	if setErr != nil {
+		return setErr
+	}
+	if len(changes) == 0 {
 		return errors.New("no commands to run")
 	}
 
-	script := strings.Join(cfg.scripts, "\n")
-	// reset
-	cfg.scripts = []string{}
+	b, err := cfg.do(script, changes)
+	cfg.lastBackend = b
+	if err != nil {
+		return fmt.Errorf("save config via %s: %w", b, err)
+	}
+	cfg.save(changes)
+	log.Printf("[config] saved %d variable(s) via %s", len(changes), b)
+	return nil
+}
 
-	return runJS(script)
+// do saves changes and returns the backend used.
+func (cfg *Config) do(script string, changes []ConfigChange) (ConfigBackend, error) {
+	switch cfg.Backend {
+	case BackendAlfred:
+		return BackendAlfred, runJS(script)
+	case BackendInfoPlist:
+		return BackendInfoPlist, cfg.savePlist(changes)
+	}
+
+	if cfg.Get(EnvVarAlfredVersion) != "" {
+		err := runJS(script)
+		if err == nil {
+			return BackendAlfred, nil
+		}
+		log.Printf("[config] calling Alfred failed, editing info.plist instead: %v", err)
+	}
+	return BackendInfoPlist, cfg.savePlist(changes)
 }
 
+// LastBackend returns the backend used by the last call to Do(), or
+// BackendAuto if Do() hasn't been called.
+func (cfg *Config) LastBackend() ConfigBackend { return cfg.lastBackend }
+
 // Extract bundle ID from argument or default.
 func (cfg *Config) getBundleID(bundleID ...string) string {
 	if len(bundleID) > 0 {
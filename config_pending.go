@@ -0,0 +1,141 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ConfigChange is a change to a workflow variable queued by Config.Set or
+// Config.Unset.
+type ConfigChange struct {
+	Key      string // Name of variable
+	Value    string // New value of variable
+	Export   bool   // Whether variable is exportable
+	Unset    bool   // Variable is to be deleted
+	BundleID string // Workflow whose variable is changed
+}
+
+// SetInt saves an int workflow variable. See Set.
+func (cfg *Config) SetInt(key string, n int, export bool, bundleID ...string) *Config {
+	return cfg.Set(key, strconv.Itoa(n), export, bundleID...)
+}
+
+// SetBool saves a boolean workflow variable. See Set.
+//
+// The value is saved as "true" or "false", which GetBool understands.
+func (cfg *Config) SetBool(key string, b bool, export bool, bundleID ...string) *Config {
+	return cfg.Set(key, strconv.FormatBool(b), export, bundleID...)
+}
+
+// SetDuration saves a time.Duration workflow variable. See Set.
+//
+// The value is saved in the format understood by GetDuration, e.g. "1h30m0s".
+func (cfg *Config) SetDuration(key string, d time.Duration, export bool, bundleID ...string) *Config {
+	return cfg.Set(key, d.String(), export, bundleID...)
+}
+
+// SetJSON saves v as a JSON-encoded workflow variable. See Set and
+// GetJSON.
+//
+// If v can't be encoded, nothing is queued and Do() returns the error.
+func (cfg *Config) SetJSON(key string, v interface{}, export bool, bundleID ...string) *Config {
+	data, err := json.Marshal(v)
+	if err != nil {
+		if cfg.err == nil {
+			cfg.err = fmt.Errorf("encode %s: %w", key, err)
+		}
+		return cfg
+	}
+	return cfg.Set(key, string(data), export, bundleID...)
+}
+
+// GetJSON decodes the JSON-encoded value of envvar "key" into v. It
+// returns an error if key isn't set or its value isn't valid JSON.
+func (cfg *Config) GetJSON(key string, v interface{}) error {
+	s, ok := cfg.Lookup(key)
+	if !ok {
+		return fmt.Errorf("variable %s not set", key)
+	}
+	if err := json.Unmarshal([]byte(s), v); err != nil {
+		return fmt.Errorf("decode %s: %w", key, err)
+	}
+	return nil
+}
+
+// Pending returns the changes queued by Set and Unset that haven't been
+// saved by Do() yet.
+func (cfg *Config) Pending() []ConfigChange {
+	return append([]ConfigChange{}, cfg.changes...)
+}
+
+// Rollback discards queued changes to the variables named keys. If no keys
+// are specified, all queued changes and errors are discarded.
+func (cfg *Config) Rollback(keys ...string) *Config {
+	if len(keys) == 0 {
+		cfg.scripts = []string{}
+		cfg.changes = nil
+		cfg.err = nil
+		return cfg
+	}
+
+	drop := map[string]bool{}
+	for _, k := range keys {
+		drop[k] = true
+	}
+	var (
+		scripts = []string{}
+		changes []ConfigChange
+	)
+	for i, c := range cfg.changes {
+		if !drop[c.Key] {
+			scripts = append(scripts, cfg.scripts[i])
+			changes = append(changes, c)
+		}
+	}
+	cfg.scripts, cfg.changes = scripts, changes
+	return cfg
+}
+
+// Lookup implements Env. It returns the value of workflow variable key,
+// including changes to the current workflow's variables that are queued
+// or that Do() has saved, but which aren't in the environment yet.
+func (cfg *Config) Lookup(key string) (string, bool) {
+	bid, _ := cfg.Env.Lookup(EnvVarBundleID)
+	for i := len(cfg.changes) - 1; i >= 0; i-- {
+		if c := cfg.changes[i]; c.Key == key && c.BundleID == bid {
+			return c.Value, !c.Unset
+		}
+	}
+	if v, ok := cfg.saved[key]; ok {
+		if v == nil {
+			return "", false
+		}
+		return *v, true
+	}
+	return cfg.Env.Lookup(key)
+}
+
+// save adds saved changes to the current workflow's variables to the
+// overlay read by Lookup.
+func (cfg *Config) save(changes []ConfigChange) {
+	bid, _ := cfg.Env.Lookup(EnvVarBundleID)
+	if cfg.saved == nil {
+		cfg.saved = map[string]*string{}
+	}
+	for _, c := range changes {
+		if c.BundleID != bid {
+			continue
+		}
+		if c.Unset {
+			cfg.saved[c.Key] = nil
+		} else {
+			v := c.Value
+			cfg.saved[c.Key] = &v
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.deanishe.net/env"
+)
+
+func pendingTestConfig() *Config {
+	return NewConfig(env.MapEnv{
+		EnvVarAlfredVersion: "4.0.4",
+		EnvVarBundleID:      "net.deanishe.awgo",
+		"NAME":              "original",
+		"OLD":               "old value",
+	})
+}
+
+// Typed setters queue string values.
+func TestConfig_SetTyped(t *testing.T) {
+	t.Parallel()
+
+	type point struct{ X, Y int }
+
+	cfg := pendingTestConfig().
+		SetInt("INT", 42, false).
+		SetBool("BOOL", true, true).
+		SetDuration("DURATION", 90*time.Minute, false).
+		SetJSON("JSON", point{1, 2}, false).
+		Unset("OLD").
+		Set("OTHER", "value", false, "net.deanishe.other")
+
+	x := []ConfigChange{
+		{Key: "INT", Value: "42", BundleID: "net.deanishe.awgo"},
+		{Key: "BOOL", Value: "true", Export: true, BundleID: "net.deanishe.awgo"},
+		{Key: "DURATION", Value: "1h30m0s", BundleID: "net.deanishe.awgo"},
+		{Key: "JSON", Value: `{"X":1,"Y":2}`, BundleID: "net.deanishe.awgo"},
+		{Key: "OLD", Unset: true, BundleID: "net.deanishe.awgo"},
+		{Key: "OTHER", Value: "value", BundleID: "net.deanishe.other"},
+	}
+	assert.Equal(t, x, cfg.Pending(), "unexpected pending changes")
+
+	// pending values are visible to getters
+	assert.Equal(t, 42, cfg.GetInt("INT"), "unexpected int")
+	assert.True(t, cfg.GetBool("BOOL"), "unexpected bool")
+	assert.Equal(t, 90*time.Minute, cfg.GetDuration("DURATION"), "unexpected duration")
+	var p point
+	require.Nil(t, cfg.GetJSON("JSON", &p), "GetJSON failed")
+	assert.Equal(t, point{1, 2}, p, "unexpected JSON")
+	assert.Equal(t, "default", cfg.Get("OLD", "default"), "unset variable visible")
+	assert.Equal(t, "original", cfg.Get("NAME"), "unexpected value")
+	// changes to other workflows aren't
+	assert.Equal(t, "", cfg.Get("OTHER"), "other workflow's variable visible")
+
+	assert.NotNil(t, cfg.GetJSON("NAME", &p), "decoded invalid JSON")
+	assert.NotNil(t, cfg.GetJSON("MISSING", &p), "decoded missing variable")
+}
+
+func TestConfig_Rollback(t *testing.T) {
+	t.Parallel()
+
+	cfg := pendingTestConfig().
+		Set("NAME", "first", false).
+		Set("A", "a", false).
+		Set("NAME", "second", false)
+	assert.Equal(t, "second", cfg.Get("NAME"), "unexpected value")
+
+	cfg.Rollback("NAME")
+	assert.Equal(t, []ConfigChange{{Key: "A", Value: "a", BundleID: "net.deanishe.awgo"}}, cfg.Pending(), "unexpected pending changes")
+	assert.Equal(t, 1, len(cfg.scripts), "scripts not rolled back")
+	assert.Equal(t, "original", cfg.Get("NAME"), "rolled back value visible")
+
+	cfg.Rollback()
+	assert.Empty(t, cfg.Pending(), "changes not rolled back")
+	assert.Equal(t, "", cfg.Get("A"), "rolled back value visible")
+	assert.NotNil(t, cfg.Do(), "empty changes saved")
+}
+
+func TestConfig_DoOverlay(t *testing.T) {
+	orig := runJS
+	defer func() { runJS = orig }()
+
+	var jsErr error
+	runJS = func(script string) error { return jsErr }
+
+	cfg := pendingTestConfig()
+	cfg.Backend = BackendAlfred
+
+	// saved values remain visible
+	require.Nil(t, cfg.Set("NAME", "saved", false).Unset("OLD").Do(), "Do failed")
+	assert.Empty(t, cfg.Pending(), "changes still pending")
+	assert.Equal(t, "saved", cfg.Get("NAME"), "saved value not visible")
+	_, ok := cfg.Lookup("OLD")
+	assert.False(t, ok, "unset value visible")
+
+	// failed saves aren't
+	jsErr = errors.New("failed")
+	assert.NotNil(t, cfg.Set("NAME", "failed", false).Do(), "Do succeeded")
+	assert.Equal(t, "saved", cfg.Get("NAME"), "failed value visible")
+
+	// encoding errors are returned by Do
+	jsErr = nil
+	err := cfg.SetJSON("JSON", make(chan int), false).Set("A", "a", false).Do()
+	assert.NotNil(t, err, "invalid JSON saved")
+	assert.Equal(t, "", cfg.Get("A"), "value saved despite error")
+}
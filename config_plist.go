@@ -0,0 +1,130 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ChicK00o/awgo/util"
+	"howett.net/plist"
+)
+
+// ConfigBackend is how Config.Do saves workflow variables.
+type ConfigBackend int
+
+// Backends for Config.Do.
+const (
+	// Use Alfred if the workflow is running in Alfred, falling back to
+	// editing info.plist if calling Alfred fails.
+	BackendAuto ConfigBackend = iota
+	// Call Alfred via JXA. Alfred must be running.
+	BackendAlfred
+	// Edit the workflow's info.plist directly. Works without Alfred,
+	// but can only change the current workflow's variables.
+	BackendInfoPlist
+)
+
+// String implements Stringer.
+func (b ConfigBackend) String() string {
+	switch b {
+	case BackendAuto:
+		return "auto"
+	case BackendAlfred:
+		return "Alfred"
+	case BackendInfoPlist:
+		return "info.plist"
+	default:
+		return fmt.Sprintf("ConfigBackend(%d)", int(b))
+	}
+}
+
+// infoPlist returns the path of the workflow's info.plist.
+func (cfg *Config) infoPlist() string {
+	if cfg.InfoPlist != "" {
+		return cfg.InfoPlist
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "info.plist"
+	}
+	return filepath.Join(findWorkflowRoot(wd), "info.plist")
+}
+
+// savePlist applies changes to info.plist.
+func (cfg *Config) savePlist(changes []ConfigChange) error {
+	path := cfg.infoPlist()
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var info map[string]interface{}
+	format, err := plist.Unmarshal(data, &info)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	bid, _ := info["bundleid"].(string)
+	vars, _ := info["variables"].(map[string]interface{})
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	dontExport := map[string]bool{}
+	if a, ok := info["variablesdontexport"].([]interface{}); ok {
+		for _, v := range a {
+			if s, ok := v.(string); ok {
+				dontExport[s] = true
+			}
+		}
+	}
+
+	for _, c := range changes {
+		if c.BundleID != "" && c.BundleID != bid {
+			return fmt.Errorf("can't change variables of workflow %q in info.plist of %q", c.BundleID, bid)
+		}
+		if c.Unset {
+			delete(vars, c.Key)
+			delete(dontExport, c.Key)
+			continue
+		}
+		vars[c.Key] = c.Value
+		dontExport[c.Key] = !c.Export
+	}
+
+	// keep order of existing unexported variables
+	var names []interface{}
+	if a, ok := info["variablesdontexport"].([]interface{}); ok {
+		for _, v := range a {
+			if s, ok := v.(string); ok && dontExport[s] {
+				names = append(names, s)
+				delete(dontExport, s)
+			}
+		}
+	}
+	for _, c := range changes {
+		if dontExport[c.Key] {
+			names = append(names, c.Key)
+			delete(dontExport, c.Key)
+		}
+	}
+
+	info["variables"] = vars
+	if len(names) > 0 {
+		info["variablesdontexport"] = names
+	} else {
+		delete(info, "variablesdontexport")
+	}
+
+	if data, err = plist.MarshalIndent(info, format, "\t"); err != nil {
+		return err
+	}
+	return util.WriteFile(path, data, fi.Mode())
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.deanishe.net/env"
+	"howett.net/plist"
+)
+
+// withTestPlist calls fn with the path to a copy of testdata/info.plist.
+func withTestPlist(fn func(path string)) {
+	dir, err := ioutil.TempDir("", "awgo-")
+	panicOnErr(err)
+	defer func() { panicOnErr(os.RemoveAll(dir)) }()
+
+	data, err := ioutil.ReadFile("testdata/info.plist")
+	panicOnErr(err)
+	path := filepath.Join(dir, "info.plist")
+	panicOnErr(ioutil.WriteFile(path, data, 0600))
+	fn(path)
+}
+
+// readTestPlist returns the variables and unexported variables in path.
+func readTestPlist(t *testing.T, path string) (map[string]string, []string) {
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err, "read info.plist")
+	v := struct {
+		Variables  map[string]string `plist:"variables"`
+		DontExport []string          `plist:"variablesdontexport"`
+		BundleID   string            `plist:"bundleid"`
+	}{}
+	_, err = plist.Unmarshal(data, &v)
+	require.Nil(t, err, "parse info.plist")
+	require.Equal(t, "net.deanishe.awgo", v.BundleID, "info.plist corrupted")
+	return v.Variables, v.DontExport
+}
+
+func TestConfig_DoInfoPlist(t *testing.T) {
+	t.Parallel()
+
+	withTestPlist(func(path string) {
+		cfg := NewConfig(env.MapEnv{EnvVarBundleID: "net.deanishe.awgo"})
+		cfg.Backend = BackendInfoPlist
+		cfg.InfoPlist = path
+
+		err := cfg.Set("NEW_VAR", "new value", true).
+			Set("SECRET", "hunter2", false).
+			Set("exported_var", "changed", false).
+			Unset("unexported_var").
+			Do()
+		require.Nil(t, err, "Do failed")
+		assert.Equal(t, BackendInfoPlist, cfg.LastBackend(), "unexpected backend")
+
+		vars, dontExport := readTestPlist(t, path)
+		assert.Equal(t, map[string]string{
+			"NEW_VAR":      "new value",
+			"SECRET":       "hunter2",
+			"exported_var": "changed",
+		}, vars, "unexpected variables")
+		assert.Equal(t, []string{"SECRET", "exported_var"}, dontExport, "unexpected unexported variables")
+
+		// variables of other workflows can't be changed
+		err = cfg.Set("NEW_VAR", "other", true, "net.deanishe.other").Do()
+		assert.NotNil(t, err, "changed other workflow")
+		vars, _ = readTestPlist(t, path)
+		assert.Equal(t, "new value", vars["NEW_VAR"], "variable changed")
+	})
+}
+
+func TestConfig_DoBackend(t *testing.T) {
+	orig := runJS
+	defer func() { runJS = orig }()
+
+	tests := []struct {
+		backend ConfigBackend
+		env     env.MapEnv
+		jsErr   error
+		x       ConfigBackend
+		fail    bool
+	}{
+		// running in Alfred
+		{BackendAuto, env.MapEnv{EnvVarAlfredVersion: "4.1"}, nil, BackendAlfred, false},
+		// Alfred not running
+		{BackendAuto, env.MapEnv{EnvVarAlfredVersion: "4.1"}, errors.New("not running"), BackendInfoPlist, false},
+		// not running in Alfred
+		{BackendAuto, env.MapEnv{}, errors.New("not running"), BackendInfoPlist, false},
+		{BackendAlfred, env.MapEnv{}, nil, BackendAlfred, false},
+		{BackendAlfred, env.MapEnv{}, errors.New("not running"), BackendAlfred, true},
+		{BackendInfoPlist, env.MapEnv{EnvVarAlfredVersion: "4.1"}, nil, BackendInfoPlist, false},
+	}
+
+	for _, td := range tests {
+		withTestPlist(func(path string) {
+			var called bool
+			runJS = func(script string) error {
+				called = true
+				return td.jsErr
+			}
+			td.env[EnvVarBundleID] = "net.deanishe.awgo"
+			cfg := NewConfig(td.env)
+			cfg.Backend = td.backend
+			cfg.InfoPlist = path
+
+			err := cfg.Set("NEW_VAR", "value", true).Do()
+			if td.fail {
+				assert.NotNil(t, err, "Do succeeded")
+			} else {
+				assert.Nil(t, err, "Do failed")
+			}
+			assert.Equal(t, td.x, cfg.LastBackend(), "unexpected backend")
+
+			vars, _ := readTestPlist(t, path)
+			if td.x == BackendInfoPlist {
+				assert.Equal(t, "value", vars["NEW_VAR"], "info.plist not changed")
+			} else {
+				assert.True(t, called, "Alfred not called")
+				assert.Equal(t, "", vars["NEW_VAR"], "info.plist changed")
+			}
+		})
+	}
+
+	// missing info.plist
+	withTempDir(func(dir string) {
+		cfg := NewConfig(env.MapEnv{})
+		cfg.InfoPlist = filepath.Join(dir, "info.plist")
+		assert.NotNil(t, cfg.Set("NEW_VAR", "value", true).Do(), "saved to missing info.plist")
+	})
+}
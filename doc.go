@@ -129,8 +129,40 @@ API of Workflow:
 You can set workflow variables (via feedback) with Workflow.Var, Item.Var
 and Modifier.Var.
 
+By default, Filter sorts Items on their match field or title. Use the
+SortFields Option to also match subtitles and Item.Keywords with different
+weights, Item.Boost to promote or demote individual Items, and
+MatchPositions to find which characters to highlight.
+
+If fuzzy matching isn't appropriate, e.g. for ticket IDs or hostnames,
+pass a Matcher such as PrefixMatcher, InitialsMatcher, SubstringMatcher,
+RegexpMatcher or TokenMatcher to the UseMatcher Option.
+
+To move results the user often (and recently) selects up the list, record
+selections in Workflow.History (e.g. with the "remember" magic action)
+and pass the UseHistory Option to blend their "frecency" into Filter's
+fuzzy scores.
+
+Instead of truncating results with MaxResults, pass the PageSize Option
+to split them into pages: a "More results…" Item autocompletes to the next
+page, and Workflow.Args removes the page marker from the query. Give Items
+a Section to group them under header Items.
+
+For drill-down menus (e.g. project → environment → action) in a single
+Script Filter, register a MenuFunc for each path with a Menu. The path is
+carried in the query, and Menu adds a "Back" Item automatically.
+
+Subpackage query splits structured queries such as
+`report status:open #work -draft` into free text, key:value filters,
+tags and exclusions, and can suggest known keys and values via
+Item.Autocomplete.
+
 See Workflow.SendFeedback for more documentation.
 
+Feedback, Item and ArgVars can also be decoded from Alfred's JSON, e.g.
+to test your workflow's output against golden files. Feedback.Validate
+reports any problems with the feedback.
+
 # Run Script actions
 
 Alfred requires a different JSON format if you wish to set workflow variables.
@@ -159,6 +191,15 @@ A Workflow can be re-configured later using its Configure() method.
 
 See the documentation for Option for more information on configuring a Workflow.
 
+Config.Do saves variables by calling Alfred, or edits the workflow's
+info.plist directly when Alfred isn't available (e.g. in tests or CI).
+Use the UseConfigBackend Option to choose one explicitly.
+
+To let users change your workflow's settings from Alfred's search box,
+declare them as a tagged struct and pass it to NewSettings. Settings.Run
+generates a Script Filter that lists and validates settings, and
+Settings.Save saves them via Config.From.
+
 # Updates
 
 AwGo can check for and install new versions of your workflow.
@@ -247,6 +288,10 @@ JSON, the API can auto-refresh expired cache data.
 
 See Cache and Session for the API documentation.
 
+In addition to raw bytes and JSON, Cache and Session can serialise values
+with a Codec (JSONCodec, GobCodec or a Gzip-wrapped Codec), set per Cache
+or per cache name with SetCodec and used by the *Value methods.
+
 Workflow has three caches tied to different directories:
 
 	Workflow.Data     // Cache pointing to workflow's data directory
@@ -263,6 +308,16 @@ directory, so may be deleted by the system or "system maintenance" tools.
 The Data directory lives with Alfred's application data and would not
 normally be deleted.
 
+A Cache grows without limit unless you set its MaxSize and/or MaxEntries,
+in which case old or least-recently-used entries are evicted. Cache.List
+and Cache.Prune let you inspect and tidy caches, and the "caches" magic
+action lets users browse and delete entries in Workflow.Cache.
+
+Cache.LoadOrStore blocks while expired data are reloaded. To keep Script
+Filters responsive, Workflow.LoadOrRefresh instead returns stale data
+immediately and refreshes the cache with a background job, telling Alfred
+to re-run the Script Filter until the fresh data are saved.
+
 # Scripts and background jobs
 
 Subpackage util provides several functions for running script files and
@@ -277,6 +332,28 @@ Filters extremely responsive.
 
 See _examples/update and _examples/workflows for demonstrations of this API.
 
+Workflow.RunJob additionally records a job's STDOUT and STDERR, start and
+finish times and exit status, and can kill jobs that exceed a maximum
+runtime. Use Job.Status to show the progress or last failure of a job in
+a Script Filter.
+
+Instead of building commands by hand, you can register Go functions as
+tasks with Workflow.RegisterTask and start them in the background with
+Workflow.Spawn, which re-runs your workflow's executable. Workflow.Args
+recognises the re-run and calls the task instead of your usual code.
+
+Workflow.RunContext passes your entry point a Context that is cancelled
+when Alfred terminates the Script Filter (e.g. because the user changed
+the query) or when the deadline set with the RunTimeout Option passes.
+Cache.LoadOrStoreContext and util.RunCmdContext accept this Context, so
+slow fetches abort cleanly instead of caching partial data.
+
+# Testing
+
+Subpackage awtest runs your workflow code in a throwaway Alfred-like
+environment, captures the feedback it sends and provides assertions on
+the parsed Items, Modifiers and variables. See awtest for documentation.
+
 # Links
 
 Docs:     https://godoc.org/github.com/ChicK00o/awgo
@@ -7,10 +7,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.deanishe.net/fuzzy"
 )
@@ -37,6 +40,13 @@ const (
 	TypeText = "text" // values are just text
 )
 
+// Item types understood by Alfred. Passed to Item.Type(). TypeFile
+// is also a valid Item type.
+const (
+	TypeDefault       = "default"        // Item is not a file
+	TypeFileSkipCheck = "file:skipcheck" // Item is a file, but Alfred doesn't check that it exists
+)
+
 // Item is a single Alfred Script Filter result.
 // Together with Feedback & Modifier, Item generates Script Filter feedback
 // for Alfred.
@@ -50,7 +60,7 @@ type Item struct {
 	autocomplete *string
 	arg          []string
 	valid        bool
-	file         bool
+	typ          string
 	copytext     *string
 	largetype    *string
 	ql           *string
@@ -58,7 +68,12 @@ type Item struct {
 	mods         map[string]*Modifier
 	actions      map[string][]string
 	icon         *Icon
-	noUID        bool // Suppress UID in JSON
+	keywords     []string // Additional terms to sort on
+	boost        float64  // Added to sort score
+	noUID        bool     // Suppress UID in JSON
+	argProblem   string   // Set by UnmarshalJSON if arg is invalid
+	section      string   // Name of section Item is grouped under
+	extra        int      // Set on Items added by Paginate and Group
 }
 
 // Title sets the title of the item in Alfred's results.
@@ -117,7 +132,19 @@ func (it *Item) Valid(b bool) *Item {
 // IsFile tells Alfred that this Item is a file, i.e. Arg is a path
 // and Alfred's File Actions should be made available.
 func (it *Item) IsFile(b bool) *Item {
-	it.file = b
+	if b {
+		it.typ = TypeFile
+	} else {
+		it.typ = ""
+	}
+	return it
+}
+
+// Type sets the Item's type. It may be one of TypeDefault, TypeFile or
+// TypeFileSkipCheck. Alfred hides results of type TypeFile if the file
+// specified by Arg doesn't exist; with TypeFileSkipCheck, it doesn't check.
+func (it *Item) Type(typ string) *Item {
+	it.typ = typ
 	return it
 }
 
@@ -240,17 +267,17 @@ func (it *Item) Vars() map[string]string {
 
 // MarshalJSON serializes Item to Alfred's JSON format.
 // You shouldn't need to call this directly: use SendFeedback() instead.
-func (it *Item) MarshalJSON() ([]byte, error) {
+func (it *Item) MarshalJSON() ([]byte, error) { return it.marshal(alfredVersion{}) }
+
+// marshal serializes Item to the JSON format understood by Alfred version v.
+// Fields (and modifiers) that version of Alfred doesn't support are omitted.
+func (it *Item) marshal(v alfredVersion) ([]byte, error) {
 	var (
-		typ  string
 		ql   string
 		text *itemText
+		mods map[string]json.RawMessage
 	)
 
-	if it.file {
-		typ = "file"
-	}
-
 	if it.ql != nil {
 		ql = *it.ql
 	}
@@ -259,44 +286,57 @@ func (it *Item) MarshalJSON() ([]byte, error) {
 		text = &itemText{Copy: it.copytext, Large: it.largetype}
 	}
 
+	for k, m := range it.mods {
+		// Alfred 3 doesn't support combined modifiers
+		if strings.Contains(k, "+") && !v.atLeast(4, 0) {
+			continue
+		}
+		data, err := m.marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if mods == nil {
+			mods = map[string]json.RawMessage{}
+		}
+		mods[k] = data
+	}
+
 	// Serialise Item
-	v := struct {
-		Title     string               `json:"title"`
-		Subtitle  *string              `json:"subtitle,omitempty"`
-		Match     *string              `json:"match,omitempty"`
-		Auto      *string              `json:"autocomplete,omitempty"`
-		Arg       interface{}          `json:"arg,omitempty"`
-		UID       *string              `json:"uid,omitempty"`
-		Valid     bool                 `json:"valid"`
-		Type      string               `json:"type,omitempty"`
-		Text      *itemText            `json:"text,omitempty"`
-		Icon      *Icon                `json:"icon,omitempty"`
-		Quicklook string               `json:"quicklookurl,omitempty"`
-		Variables map[string]string    `json:"variables,omitempty"`
-		Mods      map[string]*Modifier `json:"mods,omitempty"`
-		Actions   map[string][]string  `json:"action,omitempty"`
+	o := struct {
+		Title     string                     `json:"title"`
+		Subtitle  *string                    `json:"subtitle,omitempty"`
+		Match     *string                    `json:"match,omitempty"`
+		Auto      *string                    `json:"autocomplete,omitempty"`
+		Arg       interface{}                `json:"arg,omitempty"`
+		UID       *string                    `json:"uid,omitempty"`
+		Valid     bool                       `json:"valid"`
+		Type      string                     `json:"type,omitempty"`
+		Text      *itemText                  `json:"text,omitempty"`
+		Icon      *Icon                      `json:"icon,omitempty"`
+		Quicklook string                     `json:"quicklookurl,omitempty"`
+		Variables map[string]string          `json:"variables,omitempty"`
+		Mods      map[string]json.RawMessage `json:"mods,omitempty"`
+		Actions   map[string][]string        `json:"action,omitempty"`
 	}{
 		Title:     it.title,
 		Subtitle:  it.subtitle,
 		Match:     it.match,
 		Auto:      it.autocomplete,
+		Arg:       v.arg(it.arg),
 		UID:       it.uid,
 		Valid:     it.valid,
-		Type:      typ,
+		Type:      it.typ,
 		Text:      text,
 		Icon:      it.icon,
 		Quicklook: ql,
 		Variables: it.vars,
-		Mods:      it.mods,
-		Actions:   it.actions,
+		Mods:      mods,
 	}
-	// serialise single arg as string
-	if len(it.arg) == 1 {
-		v.Arg = it.arg[0]
-	} else if len(it.arg) > 1 {
-		v.Arg = it.arg
+	// Universal Actions were added in Alfred 4.5
+	if v.atLeast(4, 5) {
+		o.Actions = it.actions
 	}
-	return Marshal(v)
+	return Marshal(o)
 }
 
 // itemText encapsulates the copytext and largetext values for a result Item.
@@ -320,9 +360,11 @@ type Modifier struct {
 	Key      string
 	arg      []string
 	subtitle *string
-	valid    bool
+	valid    *bool
 	icon     *Icon
 	vars     map[string]string
+	// Set by UnmarshalJSON if arg is invalid
+	argProblem string
 }
 
 // newModifier creates a Modifier, validating key.
@@ -359,9 +401,10 @@ func (m *Modifier) Subtitle(s string) *Modifier {
 	return m
 }
 
-// Valid sets the valid status for the Modifier.
+// Valid sets the valid status for the Modifier. If it isn't set,
+// the Modifier inherits the valid status of its Item.
 func (m *Modifier) Valid(v bool) *Modifier {
-	m.valid = v
+	m.valid = &v
 	return m
 }
 
@@ -382,30 +425,25 @@ func (m *Modifier) Vars() map[string]string {
 	return m.vars
 }
 
-// MarshalJSON serializes Item to Alfred 3's JSON format.
+// MarshalJSON serializes Modifier to Alfred's JSON format.
 // You shouldn't need to call this directly: use SendFeedback() instead.
-func (m *Modifier) MarshalJSON() ([]byte, error) {
-	v := struct {
+func (m *Modifier) MarshalJSON() ([]byte, error) { return m.marshal(alfredVersion{}) }
+
+// marshal serializes Modifier to the JSON format understood by Alfred version v.
+func (m *Modifier) marshal(v alfredVersion) ([]byte, error) {
+	return Marshal(struct {
 		Arg       interface{}       `json:"arg,omitempty"`
 		Subtitle  *string           `json:"subtitle,omitempty"`
-		Valid     bool              `json:"valid,omitempty"`
+		Valid     *bool             `json:"valid,omitempty"`
 		Icon      *Icon             `json:"icon,omitempty"`
 		Variables map[string]string `json:"variables,omitempty"`
 	}{
+		Arg:       v.arg(m.arg),
 		Subtitle:  m.subtitle,
 		Valid:     m.valid,
 		Icon:      m.icon,
 		Variables: m.vars,
-	}
-
-	// serialise single arg as string
-	if len(m.arg) == 1 {
-		v.Arg = m.arg[0]
-	} else if len(m.arg) > 1 {
-		v.Arg = m.arg
-	}
-
-	return Marshal(v)
+	})
 }
 
 // Feedback represents the results for an Alfred Script Filter.
@@ -415,12 +453,31 @@ func (m *Modifier) MarshalJSON() ([]byte, error) {
 // functions for Feedback, Item and Modifier structs so they are properly
 // initialised and bound to their parent.
 type Feedback struct {
-	Items  []*Item           // The results to be sent to Alfred.
-	NoUIDs bool              // If true, suppress Item UIDs.
-	rerun  float64           // Tell Alfred to re-run Script Filter.
-	sent   bool              // Set to true when feedback has been sent.
-	vars   map[string]string // Top-level feedback variables.
-}
+	Items   []*Item           // The results to be sent to Alfred.
+	NoUIDs  bool              // If true, suppress Item UIDs.
+	History *History          // If set, Sort and Filter add Items' frecency to their scores.
+	Weights *FieldWeights     // If set, Sort and Filter match multiple Item fields.
+	Matcher Matcher           // If set, Sort and Filter use it instead of fuzzy matching.
+	rerun   float64           // Tell Alfred to re-run Script Filter.
+	cache   *feedbackCache    // Tell Alfred to cache results.
+	skipKB  bool              // Tell Alfred not to learn from user's choices.
+	sent    bool              // Set to true when feedback has been sent.
+	compact bool              // Send JSON without indentation.
+	vars    map[string]string // Top-level feedback variables.
+	version alfredVersion     // Version of Alfred to generate JSON for.
+}
+
+// feedbackCache is the "cache" object of Script Filter feedback.
+type feedbackCache struct {
+	Seconds     int  `json:"seconds"`
+	LooseReload bool `json:"loosereload,omitempty"`
+}
+
+// Minimum and maximum durations Alfred will cache results for.
+const (
+	minFeedbackCache = 5 * time.Second
+	maxFeedbackCache = 24 * time.Hour
+)
 
 // NewFeedback creates a new, initialised Feedback struct.
 func NewFeedback() *Feedback {
@@ -442,6 +499,36 @@ func (fb *Feedback) Rerun(secs float64) *Feedback {
 	return fb
 }
 
+// Cache tells Alfred to cache the results for d, which must be between
+// 5 seconds and 24 hours (other values are clamped to that range).
+// Pass 0 to turn caching off again. If looseReload is true, Alfred shows
+// stale results while it re-runs the Script Filter in the background.
+//
+// Added in Alfred 5. Older versions ignore it.
+func (fb *Feedback) Cache(d time.Duration, looseReload bool) *Feedback {
+	if d == 0 {
+		fb.cache = nil
+		return fb
+	}
+	if d < minFeedbackCache {
+		d = minFeedbackCache
+	}
+	if d > maxFeedbackCache {
+		d = maxFeedbackCache
+	}
+	fb.cache = &feedbackCache{Seconds: int(d / time.Second), LooseReload: looseReload}
+	return fb
+}
+
+// SkipKnowledge tells Alfred not to learn from the user's choices, i.e.
+// to keep results in the order they are sent even if they have UIDs.
+//
+// Added in Alfred 5. Older versions ignore it.
+func (fb *Feedback) SkipKnowledge(b bool) *Feedback {
+	fb.skipKB = b
+	return fb
+}
+
 // Vars returns the Feedback's workflow variables.
 func (fb *Feedback) Vars() map[string]string {
 	return fb.vars
@@ -479,43 +566,76 @@ func (fb *Feedback) NewItem(title string) *Item {
 
 // MarshalJSON serializes Feedback to Alfred's JSON format.
 // You shouldn't need to call this: use Send() instead.
+//
+// Fields that aren't supported by the version of Alfred the workflow is
+// running in (according to the alfred_version environment variable)
+// are omitted.
 func (fb *Feedback) MarshalJSON() ([]byte, error) {
-	return Marshal(&struct {
-		Variables map[string]string `json:"variables,omitempty"`
-		Rerun     float64           `json:"rerun,omitempty"`
-		Items     []*Item           `json:"items"`
-	}{
-		Items:     fb.Items,
-		Rerun:     fb.rerun,
-		Variables: fb.vars,
-	})
+	var buf bytes.Buffer
+	if err := fb.encode(&buf, false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // Send generates JSON from this struct and sends it to Alfred
 // (by writing the JSON to STDOUT).
 //
 // You shouldn't need to call this directly: use SendFeedback() instead.
-func (fb *Feedback) Send() error {
+func (fb *Feedback) Send() error { return fb.send(os.Stdout) }
+
+// send writes Feedback's JSON to w. Items are encoded one at a time,
+// so the whole payload is never held in memory.
+func (fb *Feedback) send(w io.Writer) error {
 	if fb.sent {
 		log.Printf("Feedback already sent. Ignoring.")
 		return nil
 	}
-	output, err := MarshalIndent(fb, "", "  ")
-	if err != nil {
-		return fmt.Errorf("Error generating JSON : %w", err)
+	if err := fb.encode(w, !fb.compact); err != nil {
+		return fmt.Errorf("write feedback: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("write feedback: %w", err)
 	}
-
-	os.Stdout.Write(output)
 	fb.sent = true
 	log.Printf("Sent %d result(s) to Alfred", len(fb.Items))
 	return nil
 }
 
 // Sort sorts Items against query. Uses a fuzzy.Sorter with the specified
-// options.
+// options, or Feedback.Matcher if it is set.
+//
+// By default, Items are sorted on their match field or title. If
+// Feedback.Weights is set, Items' titles, subtitles, match fields and
+// keywords are all matched against query. Item boosts and, if
+// Feedback.History is set, Items' frecency scores are added to the
+// scores of matching Items.
 func (fb *Feedback) Sort(query string, opts ...fuzzy.Option) []*fuzzy.Result {
-	s := fuzzy.New(fb, opts...)
-	return s.Sort(query)
+	var res []*fuzzy.Result
+	switch {
+	case fb.Matcher != nil:
+		res = fb.sortWith(fb.Matcher, query)
+	case fb.Weights != nil:
+		res = fb.sortWith(fuzzy.New(nil, opts...), query)
+	default:
+		res = fuzzy.New(fb, opts...).Sort(query)
+	}
+
+	var rerank bool
+	for i, it := range fb.Items {
+		if it.boost != 0 && res[i].Match {
+			res[i].Score += it.boost
+			rerank = true
+		}
+	}
+	if fb.History != nil {
+		fb.History.addScores(fb.Items, res)
+		rerank = true
+	}
+	if rerank {
+		sort.Stable(rankedItems{fb.Items, res})
+	}
+	return res
 }
 
 // Filter fuzzy-sorts Items against query and deletes Items that don't match.
@@ -562,6 +682,56 @@ func (fb *Feedback) Less(i, j int) bool {
 // Swap implements sort.Interface.
 func (fb *Feedback) Swap(i, j int) { fb.Items[i], fb.Items[j] = fb.Items[j], fb.Items[i] }
 
+// alfredVersion is the version of Alfred that JSON is generated for.
+// The zero value means the latest version.
+type alfredVersion struct {
+	major, minor int
+}
+
+// parseAlfredVersion parses the value of alfred_version. It returns
+// the zero alfredVersion if s is empty or invalid.
+func parseAlfredVersion(s string) alfredVersion {
+	parts := strings.SplitN(s, ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 1 {
+		return alfredVersion{}
+	}
+	v := alfredVersion{major: major}
+	if len(parts) > 1 {
+		v.minor, _ = strconv.Atoi(parts[1])
+	}
+	return v
+}
+
+// atLeast returns true if v is the given version or later.
+func (v alfredVersion) atLeast(major, minor int) bool {
+	if v.major == 0 || v.major > major {
+		return true
+	}
+	return v.major == major && v.minor >= minor
+}
+
+// arg returns the value of an Item's or Modifier's arg field. Alfred
+// 4.1 and later accept multiple values; older versions get the first.
+func (v alfredVersion) arg(arg []string) interface{} {
+	switch {
+	case len(arg) == 0:
+		return nil
+	case len(arg) == 1 || !v.atLeast(4, 1):
+		return arg[0]
+	default:
+		return arg
+	}
+}
+
+// String implements Stringer.
+func (v alfredVersion) String() string {
+	if v.major == 0 {
+		return "latest"
+	}
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
 // ArgVars lets you set workflow variables from Run Script actions.
 // It emits the arg and variables you set in the format required by Alfred.
 //
@@ -0,0 +1,368 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidFeedback is the error returned by Feedback.Validate if
+// the feedback doesn't conform to Alfred's Script Filter JSON format.
+type ErrInvalidFeedback struct {
+	Problems []string // Description of each problem
+}
+
+// Error implements error interface.
+func (err ErrInvalidFeedback) Error() string {
+	return "invalid feedback: " + strings.Join(err.Problems, "; ")
+}
+
+// Is returns true if target is of type ErrInvalidFeedback.
+func (err ErrInvalidFeedback) Is(target error) bool {
+	_, ok := target.(ErrInvalidFeedback)
+	return ok
+}
+
+// Validate checks that Feedback is valid Script Filter JSON, e.g. that
+// all Items have titles, modifier keys are valid and args are strings.
+// It returns an ErrInvalidFeedback error listing all problems found.
+//
+// Validate is mostly useful for checking feedback decoded from JSON, as
+// Feedback built with the API can only contain a few of these problems.
+func (fb *Feedback) Validate() error {
+	var problems []string
+	if fb.rerun != 0 && (fb.rerun < 0.1 || fb.rerun > 5) {
+		problems = append(problems, fmt.Sprintf("rerun %v not between 0.1 and 5", fb.rerun))
+	}
+	if fb.cache != nil {
+		d := fb.cache.Seconds
+		if d < int(minFeedbackCache.Seconds()) || d > int(maxFeedbackCache.Seconds()) {
+			problems = append(problems, fmt.Sprintf("cache seconds %d not between %v and %v",
+				d, minFeedbackCache.Seconds(), maxFeedbackCache.Seconds()))
+		}
+	}
+	for i, it := range fb.Items {
+		problems = append(problems, it.problems(fmt.Sprintf("items[%d]", i))...)
+	}
+	if len(problems) > 0 {
+		return ErrInvalidFeedback{problems}
+	}
+	return nil
+}
+
+// problems returns the validation problems of Item.
+func (it *Item) problems(path string) []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if it.title == "" {
+		add("missing title")
+	}
+	if it.argProblem != "" {
+		add("arg %s", it.argProblem)
+	}
+	switch it.typ {
+	case "", TypeDefault, TypeFile, TypeFileSkipCheck:
+	default:
+		add("invalid type %q", it.typ)
+	}
+	if s := iconProblem(it.icon); s != "" {
+		add(s)
+	}
+	for typ := range it.actions {
+		switch typ {
+		case "auto", TypeFile, TypeURL, TypeText:
+		default:
+			add("invalid action type %q", typ)
+		}
+	}
+	for k, m := range it.mods {
+		if s := modKeyProblem(k); s != "" {
+			add("mods: %s", s)
+		}
+		if m.argProblem != "" {
+			add("mods[%q]: arg %s", k, m.argProblem)
+		}
+		if s := iconProblem(m.icon); s != "" {
+			add("mods[%q]: %s", k, s)
+		}
+	}
+	return problems
+}
+
+// iconProblem returns a description of what is wrong with icon.
+func iconProblem(icon *Icon) string {
+	if icon == nil {
+		return ""
+	}
+	if icon.Value == "" {
+		return "icon has no path"
+	}
+	switch icon.Type {
+	case IconTypeImage, IconTypeFileIcon, IconTypeFileType:
+		return ""
+	default:
+		return fmt.Sprintf("invalid icon type %q", icon.Type)
+	}
+}
+
+// modKeyProblem returns a description of what is wrong with modifier key k.
+func modKeyProblem(k string) string {
+	if k == "" {
+		return "empty modifier key"
+	}
+	seen := map[string]bool{}
+	for _, s := range strings.Split(k, "+") {
+		switch s {
+		case ModAlt, ModCmd, ModCtrl, ModFn, ModShift:
+		default:
+			return fmt.Sprintf("invalid modifier key %q", k)
+		}
+		if seen[s] {
+			return fmt.Sprintf("duplicate modifier in key %q", k)
+		}
+		seen[s] = true
+	}
+	return ""
+}
+
+// UnmarshalJSON decodes Alfred's Script Filter JSON into Feedback.
+func (fb *Feedback) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Variables     map[string]string `json:"variables"`
+		Rerun         float64           `json:"rerun"`
+		Cache         *feedbackCache    `json:"cache"`
+		SkipKnowledge bool              `json:"skipknowledge"`
+		Items         []*Item           `json:"items"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Variables == nil {
+		v.Variables = map[string]string{}
+	}
+	if v.Items == nil {
+		v.Items = []*Item{}
+	}
+	fb.vars = v.Variables
+	fb.rerun = v.Rerun
+	fb.cache = v.Cache
+	fb.skipKB = v.SkipKnowledge
+	fb.Items = v.Items
+	return nil
+}
+
+// UnmarshalJSON decodes a Script Filter result into Item.
+//
+// As in Alfred, valid defaults to true. Args that aren't strings are
+// converted to strings, and the problem is reported by Feedback.Validate.
+// Null modifiers are dropped.
+func (it *Item) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Title     string               `json:"title"`
+		Subtitle  *string              `json:"subtitle"`
+		Match     *string              `json:"match"`
+		Auto      *string              `json:"autocomplete"`
+		Arg       json.RawMessage      `json:"arg"`
+		UID       *string              `json:"uid"`
+		Valid     *bool                `json:"valid"`
+		Type      string               `json:"type"`
+		Text      *itemText            `json:"text"`
+		Icon      *Icon                `json:"icon"`
+		Quicklook *string              `json:"quicklookurl"`
+		Variables map[string]string    `json:"variables"`
+		Mods      map[string]*Modifier `json:"mods"`
+		Actions   json.RawMessage      `json:"action"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var err error
+	*it = Item{
+		title:        v.Title,
+		subtitle:     v.Subtitle,
+		match:        v.Match,
+		uid:          v.UID,
+		autocomplete: v.Auto,
+		valid:        v.Valid == nil || *v.Valid,
+		typ:          v.Type,
+		ql:           v.Quicklook,
+		vars:         v.Variables,
+		mods:         v.Mods,
+		icon:         v.Icon,
+	}
+	it.arg, it.argProblem = decodeArg(v.Arg)
+	if it.actions, err = decodeActions(v.Actions); err != nil {
+		return err
+	}
+	if v.Text != nil {
+		it.copytext = v.Text.Copy
+		it.largetype = v.Text.Large
+	}
+	if it.vars == nil {
+		it.vars = map[string]string{}
+	}
+	for k, m := range it.mods {
+		if m == nil {
+			delete(it.mods, k)
+			continue
+		}
+		m.Key = k
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a modifier of a Script Filter result. Modifier.Key
+// is set by Item.UnmarshalJSON.
+func (m *Modifier) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Arg       json.RawMessage   `json:"arg"`
+		Subtitle  *string           `json:"subtitle"`
+		Valid     *bool             `json:"valid"`
+		Icon      *Icon             `json:"icon"`
+		Variables map[string]string `json:"variables"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = Modifier{
+		Key:      m.Key,
+		subtitle: v.Subtitle,
+		valid:    v.Valid,
+		icon:     v.Icon,
+		vars:     v.Variables,
+	}
+	m.arg, m.argProblem = decodeArg(v.Arg)
+	if m.vars == nil {
+		m.vars = map[string]string{}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes an icon. As well as Alfred's icon object, it
+// also accepts a string, which is treated as the path to an image.
+func (icon *Icon) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*icon = Icon{Value: s}
+		return nil
+	}
+	var v struct {
+		Value string   `json:"path"`
+		Type  IconType `json:"type"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*icon = Icon{Value: v.Value, Type: v.Type}
+	return nil
+}
+
+// UnmarshalJSON decodes the output of a Run Script action, which is
+// either a JSON string or an "alfredworkflow" object.
+func (a *ArgVars) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = ArgVars{arg: []string{s}, vars: map[string]string{}}
+		return nil
+	}
+
+	var v struct {
+		Root *struct {
+			Arg       json.RawMessage   `json:"arg"`
+			Variables map[string]string `json:"variables"`
+		} `json:"alfredworkflow"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Root == nil {
+		return errors.New(`missing "alfredworkflow" object`)
+	}
+	arg, problem := decodeArg(v.Root.Arg)
+	if problem != "" {
+		return fmt.Errorf("arg %s", problem)
+	}
+	*a = ArgVars{arg: arg, vars: v.Root.Variables}
+	if a.vars == nil {
+		a.vars = map[string]string{}
+	}
+	return nil
+}
+
+// decodeArg decodes an arg, which should be a string or an array of
+// strings. Other values are converted to strings, and a description
+// of the problem is returned.
+func decodeArg(data json.RawMessage) (arg []string, problem string) {
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, ""
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		// not an array
+		s, ok := rawString(data)
+		if !ok {
+			problem = fmt.Sprintf("is %s, not a string or array", data)
+		}
+		return []string{s}, problem
+	}
+
+	arg = make([]string, len(raw))
+	var bad bool
+	for i, r := range raw {
+		var ok bool
+		if arg[i], ok = rawString(r); !ok {
+			bad = true
+		}
+	}
+	if bad {
+		problem = "contains values that aren't strings"
+	}
+	return arg, problem
+}
+
+// rawString returns data as a string. If data isn't a JSON string,
+// it returns the JSON itself and false.
+func rawString(data json.RawMessage) (string, bool) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return string(data), false
+	}
+	return s, true
+}
+
+// decodeActions decodes the action field of an Item, which may be
+// a string, an array of strings, or an object mapping types to either.
+func decodeActions(data json.RawMessage) (map[string][]string, error) {
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		obj = map[string]json.RawMessage{"auto": data}
+	}
+
+	actions := make(map[string][]string, len(obj))
+	for typ, raw := range obj {
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, fmt.Errorf("invalid action %s", raw)
+			}
+			values = []string{s}
+		}
+		actions[typ] = values
+	}
+	return actions, nil
+}
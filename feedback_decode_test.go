@@ -0,0 +1,222 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Decoded feedback is re-encoded to the same JSON.
+func TestFeedback_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	golden, err := ioutil.ReadFile("testdata/feedback.json")
+	require.Nil(t, err, "read golden file")
+
+	fb := &Feedback{}
+	require.Nil(t, json.Unmarshal(golden, fb), "unmarshal Feedback failed")
+	require.Nil(t, fb.Validate(), "decoded feedback invalid")
+	require.Equal(t, 2, len(fb.Items), "unexpected item count")
+
+	it := fb.Items[0]
+	assert.Equal(t, "Desktop", it.title, "unexpected title")
+	assert.Equal(t, TypeFileSkipCheck, it.typ, "unexpected type")
+	assert.Equal(t, "alt+cmd", it.mods["alt+cmd"].Key, "unexpected modifier key")
+	assert.Equal(t, []string{"/Users/dean/Desktop", "/Users/dean/Documents"}, it.mods["cmd"].arg, "unexpected modifier arg")
+	assert.Equal(t, "bar", fb.Vars()["foo"], "unexpected variable")
+
+	data, err := MarshalIndent(fb, "", "  ")
+	require.Nil(t, err, "marshal Feedback failed")
+	assert.Equal(t, string(bytes.TrimSpace(golden)), string(bytes.TrimSpace(data)), "feedback changed")
+}
+
+// Feedback built with the API survives a round trip.
+func TestFeedback_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	fb := NewFeedback().Rerun(0.5).Cache(time.Minute, false).SkipKnowledge(true)
+	fb.Var("foo", "bar")
+	it := fb.NewItem("title").
+		Subtitle("subtitle").
+		Arg("one", "two").
+		UID("uid").
+		Valid(true).
+		IsFile(true).
+		Icon(IconInfo).
+		Action("action")
+	it.NewModifier("cmd", "shift").Arg("").Valid(false).Icon(IconWarning)
+	fb.NewItem("").Largetype("large")
+
+	x, err := json.Marshal(fb)
+	require.Nil(t, err, "marshal Feedback failed")
+
+	fb2 := &Feedback{}
+	require.Nil(t, json.Unmarshal(x, fb2), "unmarshal Feedback failed")
+	data, err := json.Marshal(fb2)
+	require.Nil(t, err, "marshal Feedback failed")
+	assert.Equal(t, string(x), string(data), "feedback changed")
+}
+
+func TestItem_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		arg     []string
+		actions map[string][]string
+		problem string
+	}{
+		{`{"title":"t"}`, nil, nil, ""},
+		{`{"title":"t","arg":""}`, []string{""}, nil, ""},
+		{`{"title":"t","arg":"one"}`, []string{"one"}, nil, ""},
+		{`{"title":"t","arg":["one","two"]}`, []string{"one", "two"}, nil, ""},
+		{`{"title":"t","arg":["one",2,true]}`, []string{"one", "2", "true"}, nil, "contains values that aren't strings"},
+		{`{"title":"t","arg":12}`, []string{"12"}, nil, "is 12, not a string or array"},
+		{`{"title":"t","action":"one"}`, nil, map[string][]string{"auto": {"one"}}, ""},
+		{`{"title":"t","action":["one","two"]}`, nil, map[string][]string{"auto": {"one", "two"}}, ""},
+		{`{"title":"t","action":{"url":"u","text":["a","b"]}}`, nil,
+			map[string][]string{"url": {"u"}, "text": {"a", "b"}}, ""},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.in, func(t *testing.T) {
+			t.Parallel()
+			it := &Item{}
+			require.Nil(t, json.Unmarshal([]byte(td.in), it), "unmarshal Item failed")
+			assert.Equal(t, td.arg, it.arg, "unexpected arg")
+			assert.Equal(t, td.actions, it.actions, "unexpected actions")
+			assert.Equal(t, td.problem, it.argProblem, "unexpected problem")
+		})
+	}
+
+	for in, x := range map[string]bool{
+		`{"title":"t"}`:               true,
+		`{"title":"t","valid":true}`:  true,
+		`{"title":"t","valid":false}`: false,
+	} {
+		it := &Item{}
+		require.Nil(t, json.Unmarshal([]byte(in), it), "unmarshal Item failed")
+		assert.Equal(t, x, it.valid, "unexpected valid for %s", in)
+	}
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"title":"t","action":{"url":1}}`), &Item{}), "accepted invalid action")
+
+	it := &Item{}
+	require.Nil(t, json.Unmarshal([]byte(`{"title":"t","mods":{"cmd":null,"alt":{}}}`), it), "unmarshal null modifier")
+	assert.Len(t, it.mods, 1, "null modifier not dropped")
+	assert.Contains(t, it.mods, "alt", "modifier dropped")
+}
+
+func TestIcon_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in string
+		x  Icon
+	}{
+		{`"icon.png"`, Icon{Value: "icon.png"}},
+		{`{"path":"icon.png"}`, Icon{Value: "icon.png"}},
+		{`{"path":"public.folder","type":"filetype"}`, Icon{"public.folder", IconTypeFileType}},
+	}
+
+	for _, td := range tests {
+		var icon Icon
+		require.Nil(t, json.Unmarshal([]byte(td.in), &icon), "unmarshal Icon failed")
+		assert.Equal(t, td.x, icon, "unexpected icon")
+	}
+}
+
+func TestArgVars_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in  string
+		arg []string
+		err bool
+	}{
+		{`""`, []string{""}, false},
+		{`"title"`, []string{"title"}, false},
+		{`{"alfredworkflow":{"arg":["one","two"]}}`, []string{"one", "two"}, false},
+		{`{"alfredworkflow":{"arg":"title","variables":{"foo":"bar"}}}`, []string{"title"}, false},
+		{`{"alfredworkflow":{"arg":["one",2]}}`, nil, true},
+		{`{"arg":"title"}`, nil, true},
+		{`12`, nil, true},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.in, func(t *testing.T) {
+			t.Parallel()
+			av := &ArgVars{}
+			err := json.Unmarshal([]byte(td.in), av)
+			if td.err {
+				assert.NotNil(t, err, "accepted invalid ArgVars")
+				return
+			}
+			require.Nil(t, err, "unmarshal ArgVars failed")
+			assert.Equal(t, td.arg, av.arg, "unexpected arg")
+
+			// round trip
+			data, err := json.Marshal(av)
+			require.Nil(t, err, "marshal ArgVars failed")
+			av2 := &ArgVars{}
+			require.Nil(t, json.Unmarshal(data, av2), "unmarshal ArgVars failed")
+			assert.Equal(t, av, av2, "ArgVars changed")
+		})
+	}
+}
+
+func TestFeedback_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in string
+		x  []string
+	}{
+		{`{"items":[{"title":"ok","mods":{"cmd+alt":{}}}]}`, nil},
+		{`{"items":[{}]}`, []string{"items[0]: missing title"}},
+		{`{"items":[{"title":"t","arg":["one",2]}]}`, []string{"items[0]: arg contains values that aren't strings"}},
+		{`{"items":[{"title":"t","mods":{"cmd+meta":{}}}]}`, []string{`items[0]: mods: invalid modifier key "cmd+meta"`}},
+		{`{"items":[{"title":"t","mods":{"cmd+cmd":{}}}]}`, []string{`items[0]: mods: duplicate modifier in key "cmd+cmd"`}},
+		{`{"items":[{"title":"t","mods":{"cmd":{"arg":{}}}}]}`, []string{`items[0]: mods["cmd"]: arg is {}, not a string or array`}},
+		{`{"items":[{"title":"t","type":"folder"}]}`, []string{`items[0]: invalid type "folder"`}},
+		{`{"items":[{"title":"t","icon":{"path":"x","type":"uti"}}]}`, []string{`items[0]: invalid icon type "uti"`}},
+		{`{"items":[{"title":"t","action":{"app":"x"}}]}`, []string{`items[0]: invalid action type "app"`}},
+		{`{"rerun":10,"cache":{"seconds":1},"items":[]}`, []string{
+			"rerun 10 not between 0.1 and 5",
+			"cache seconds 1 not between 5 and 86400",
+		}},
+		{`{"items":[{"title":"ok"},{"arg":3}]}`, []string{
+			"items[1]: missing title",
+			"items[1]: arg is 3, not a string or array",
+		}},
+	}
+
+	for i, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("Validate(%d)", i), func(t *testing.T) {
+			t.Parallel()
+			fb := &Feedback{}
+			require.Nil(t, json.Unmarshal([]byte(td.in), fb), "unmarshal Feedback failed")
+			err := fb.Validate()
+			if td.x == nil {
+				assert.Nil(t, err, "valid feedback rejected")
+				return
+			}
+			var v ErrInvalidFeedback
+			require.True(t, errors.As(err, &v), "unexpected error: %v", err)
+			assert.Equal(t, td.x, v.Problems, "unexpected problems")
+		})
+	}
+}
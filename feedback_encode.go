@@ -0,0 +1,126 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+)
+
+// Indentation of feedback JSON when it isn't compact.
+const (
+	fbIndent     = "  "
+	fbItemIndent = fbIndent + fbIndent
+)
+
+// feedbackEncoder writes Feedback JSON field by field.
+type feedbackEncoder struct {
+	w      *bufio.Writer
+	indent bool
+	buf    bytes.Buffer // for indenting values
+	n      int          // number of fields written
+}
+
+// encode writes Feedback's JSON to w, marshalling one Item at a time.
+// If indent is true, the output is the same as MarshalIndent(fb, "", "  ").
+func (fb *Feedback) encode(w io.Writer, indent bool) error {
+	e := &feedbackEncoder{w: bufio.NewWriter(w), indent: indent}
+
+	e.w.WriteByte('{')
+	if len(fb.vars) > 0 {
+		if err := e.field("variables", fb.vars); err != nil {
+			return err
+		}
+	}
+	if fb.rerun != 0 {
+		if err := e.field("rerun", fb.rerun); err != nil {
+			return err
+		}
+	}
+	// cache and skipknowledge were added in Alfred 5
+	if fb.version.atLeast(5, 0) {
+		if fb.cache != nil {
+			if err := e.field("cache", fb.cache); err != nil {
+				return err
+			}
+		}
+		if fb.skipKB {
+			if err := e.field("skipknowledge", true); err != nil {
+				return err
+			}
+		}
+	} else if fb.cache != nil || fb.skipKB {
+		log.Printf("[warning] Alfred %s doesn't support cache or skipknowledge", fb.version)
+	}
+
+	e.key("items")
+	e.w.WriteByte('[')
+	for i, it := range fb.Items {
+		data, err := it.marshal(fb.version)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		if e.indent {
+			e.w.WriteString("\n" + fbItemIndent)
+		}
+		if err := e.value(data, fbItemIndent); err != nil {
+			return err
+		}
+	}
+	if e.indent && len(fb.Items) > 0 {
+		e.w.WriteString("\n" + fbIndent)
+	}
+	e.w.WriteByte(']')
+	if e.indent {
+		e.w.WriteByte('\n')
+	}
+	e.w.WriteByte('}')
+	return e.w.Flush()
+}
+
+// field writes a top-level field.
+func (e *feedbackEncoder) field(key string, v interface{}) error {
+	data, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.key(key)
+	return e.value(data, fbIndent)
+}
+
+// key writes a top-level key, preceded by a separator if necessary.
+func (e *feedbackEncoder) key(key string) {
+	if e.n > 0 {
+		e.w.WriteByte(',')
+	}
+	e.n++
+	if e.indent {
+		e.w.WriteString("\n" + fbIndent)
+	}
+	e.w.WriteString(`"` + key + `":`)
+	if e.indent {
+		e.w.WriteByte(' ')
+	}
+}
+
+// value writes JSON data, indenting it with prefix if necessary.
+func (e *feedbackEncoder) value(data []byte, prefix string) error {
+	data = bytes.TrimSpace(data)
+	if !e.indent {
+		_, err := e.w.Write(data)
+		return err
+	}
+	e.buf.Reset()
+	if err := json.Indent(&e.buf, data, prefix, fbIndent); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
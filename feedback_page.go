@@ -0,0 +1,144 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// PageMarker separates the query from the page number in the query that
+// the "More results…" Item autocompletes to, e.g. "foo ›2". Workflow.Args
+// removes it from the query when the PageSize Option is set.
+const PageMarker = " ›"
+
+var rxPageMarker = regexp.MustCompile(regexp.QuoteMeta(PageMarker) + `(\d+)$`)
+
+// ParsePage splits a query into the query proper and the page number
+// added by a "More results…" Item. page is 1 if query has no page marker.
+func ParsePage(query string) (q string, page int) {
+	m := rxPageMarker.FindStringSubmatchIndex(query)
+	if m == nil {
+		return query, 1
+	}
+	page, err := strconv.Atoi(query[m[2]:m[3]])
+	if err != nil || page < 1 {
+		return query, 1
+	}
+	return query[:m[0]], page
+}
+
+// Section sets the name of the section Item belongs to. Feedback.Group
+// places Items with a section under a header Item.
+func (it *Item) Section(name string) *Item {
+	it.section = name
+	return it
+}
+
+// Paginate removes all Items not on page (starting from 1) of results
+// with size Items per page. If there are Items after page, a "More
+// results…" Item is added that autocompletes to query with the number of
+// the next page appended. Use ParsePage to split the query received from
+// Alfred.
+//
+// Call Paginate after sorting or filtering Feedback.
+func (fb *Feedback) Paginate(query string, page, size int) *Feedback {
+	if size < 1 {
+		return fb
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	var (
+		items = fb.results()
+		n     = len(items)
+		start = (page - 1) * size
+		end   = start + size
+	)
+	if start > n {
+		start = n
+	}
+	if end > n {
+		end = n
+	}
+	fb.Items = items[start:end]
+
+	if end < n {
+		it := fb.NewItem("More results…").
+			Subtitle(fmt.Sprintf("Showing %d–%d of %d", start+1, end, n)).
+			Autocomplete(fmt.Sprintf("%s%s%d", query, PageMarker, page+1)).
+			Icon(IconInfo).
+			Valid(false)
+		it.extra = extraMore
+	}
+	return fb
+}
+
+// Group groups Items by section, adding an invalid header Item titled
+// with the section's name above each group of Items. Sections appear in
+// the order of their first (i.e. highest-ranked) Item, and Items keep
+// their order within each section. Items without a section stay at the
+// top of the results, and the "More results…" Item added by Paginate
+// stays at the bottom.
+//
+// Group does nothing if no Items have a section. It is called
+// automatically by Workflow.SendFeedback.
+func (fb *Feedback) Group() *Feedback {
+	var (
+		items    = fb.results()
+		top      []*Item
+		more     []*Item
+		sections []string
+		grouped  = map[string][]*Item{}
+	)
+	for _, it := range fb.Items {
+		if it.extra == extraMore {
+			more = append(more, it)
+		}
+	}
+	for _, it := range items {
+		if it.section == "" {
+			top = append(top, it)
+			continue
+		}
+		if _, ok := grouped[it.section]; !ok {
+			sections = append(sections, it.section)
+		}
+		grouped[it.section] = append(grouped[it.section], it)
+	}
+	if len(sections) == 0 {
+		return fb
+	}
+
+	fb.Items = top
+	for _, name := range sections {
+		it := fb.NewItem(name).
+			Icon(IconGroup).
+			Valid(false)
+		it.extra = extraHeader
+		fb.Items = append(fb.Items, grouped[name]...)
+	}
+	fb.Items = append(fb.Items, more...)
+	return fb
+}
+
+// Kinds of Item added by Paginate and Group.
+const (
+	extraHeader = 1 // Section header
+	extraMore   = 2 // "More results…"
+)
+
+// results returns Items without any section headers or "More results…"
+// Items, so Group and Paginate only see "real" results.
+func (fb *Feedback) results() []*Item {
+	var items []*Item
+	for _, it := range fb.Items {
+		if it.extra == 0 {
+			items = append(items, it)
+		}
+	}
+	return items
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in    string
+		query string
+		page  int
+	}{
+		{"", "", 1},
+		{"foo", "foo", 1},
+		{"foo ›2", "foo", 2},
+		{" ›12", "", 12},
+		{"foo bar ›3", "foo bar", 3},
+		{"foo ›0", "foo ›0", 1},
+		{"foo ›", "foo ›", 1},
+		{"foo›2", "foo›2", 1},
+		{"foo ›2 bar", "foo ›2 bar", 1},
+	}
+
+	for _, td := range tests {
+		q, p := ParsePage(td.in)
+		assert.Equal(t, td.query, q, "unexpected query for %q", td.in)
+		assert.Equal(t, td.page, p, "unexpected page for %q", td.in)
+	}
+}
+
+// newPagedFeedback returns Feedback with n Items titled "1" to "n".
+func newPagedFeedback(n int) *Feedback {
+	fb := NewFeedback()
+	for i := 1; i <= n; i++ {
+		fb.NewItem(fmt.Sprint(i))
+	}
+	return fb
+}
+
+func TestFeedback_Paginate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		n, page, size int
+		x             []string
+		more          string // autocomplete of "More results…"
+	}{
+		{5, 1, 2, []string{"1", "2"}, "foo ›2"},
+		{5, 2, 2, []string{"3", "4"}, "foo ›3"},
+		{5, 3, 2, []string{"5"}, ""},
+		{5, 4, 2, nil, ""},
+		{5, 0, 2, []string{"1", "2"}, "foo ›2"},
+		{5, 1, 5, []string{"1", "2", "3", "4", "5"}, ""},
+		{5, 2, 0, []string{"1", "2", "3", "4", "5"}, ""},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%d/%d/%d", td.n, td.page, td.size), func(t *testing.T) {
+			t.Parallel()
+			fb := newPagedFeedback(td.n).Paginate("foo", td.page, td.size)
+			assert.Equal(t, td.x, feedbackOrder(&Feedback{Items: fb.results()}), "unexpected results")
+			if td.more == "" {
+				assert.Equal(t, len(td.x), len(fb.Items), "unexpected More item")
+				return
+			}
+			require.Equal(t, len(td.x)+1, len(fb.Items), "no More item")
+			it := fb.Items[len(fb.Items)-1]
+			assert.Equal(t, "More results…", it.title, "unexpected title")
+			assert.Equal(t, td.more, *it.autocomplete, "unexpected autocomplete")
+			assert.False(t, it.valid, "More item is valid")
+		})
+	}
+
+	// Paginate ignores previously-added More item
+	fb := newPagedFeedback(5).Paginate("foo", 1, 4).Paginate("foo", 2, 2)
+	assert.Equal(t, []string{"3", "4"}, feedbackOrder(fb), "unexpected results")
+}
+
+func TestFeedback_Group(t *testing.T) {
+	t.Parallel()
+
+	fb := NewFeedback()
+	fb.NewItem("apple").Section("Fruit")
+	fb.NewItem("carrot").Section("Vegetables")
+	fb.NewItem("bread")
+	fb.NewItem("banana").Section("Fruit")
+	fb.NewItem("bean").Section("Vegetables")
+	fb.Paginate("", 1, 4)
+	fb.Group()
+
+	x := []string{"bread", "Fruit", "apple", "banana", "Vegetables", "carrot", "More results…"}
+	assert.Equal(t, x, feedbackOrder(fb), "unexpected order")
+	for _, it := range fb.Items[1:] {
+		if it.title == "Fruit" || it.title == "Vegetables" {
+			assert.False(t, it.valid, "header is valid")
+			assert.Equal(t, IconGroup, it.icon, "unexpected header icon")
+		}
+	}
+
+	// regrouping doesn't duplicate headers
+	fb.Group()
+	assert.Equal(t, x, feedbackOrder(fb), "unexpected order")
+
+	// no sections, no headers
+	fb = newPagedFeedback(3).Group()
+	assert.Equal(t, []string{"1", "2", "3"}, feedbackOrder(fb), "unexpected order")
+}
+
+// Workflow removes page marker from query and paginates feedback.
+func TestPageSize(t *testing.T) {
+	origArgs := os.Args
+	defer func() {
+		os.Args = origArgs
+	}()
+
+	withTestWf(func(wf *Workflow) {
+		var (
+			buf bytes.Buffer
+			fb  = &Feedback{}
+		)
+		os.Args = []string{"blah", "foo ›2"}
+		wf.Configure(PageSize(2), Output(&buf))
+		assert.Equal(t, []string{"foo"}, wf.Args(), "unexpected args")
+		assert.Equal(t, []string{"blah", "foo ›2"}, os.Args, "os.Args modified")
+
+		for i := 1; i <= 5; i++ {
+			wf.NewItem(fmt.Sprint(i)).Section("odd" + fmt.Sprint(i%2))
+		}
+		wf.SendFeedback()
+		require.Nil(t, json.Unmarshal(buf.Bytes(), fb), "unmarshal feedback")
+		x := []string{"odd1", "3", "odd0", "4", "More results…"}
+		assert.Equal(t, x, feedbackOrder(fb), "unexpected items")
+		assert.Equal(t, "foo ›3", *fb.Items[4].autocomplete, "unexpected autocomplete")
+	})
+}
+
+// MaxResults doesn't truncate paginated results.
+func TestPageSize_maxResults(t *testing.T) {
+	origArgs := os.Args
+	defer func() {
+		os.Args = origArgs
+	}()
+
+	withTestWf(func(wf *Workflow) {
+		var (
+			buf bytes.Buffer
+			fb  = &Feedback{}
+		)
+		os.Args = []string{"blah", "foo ›2"}
+		wf.Configure(MaxResults(3), PageSize(2), Output(&buf))
+		assert.Equal(t, []string{"foo"}, wf.Args(), "unexpected args")
+
+		for i := 1; i <= 5; i++ {
+			wf.NewItem(fmt.Sprint(i))
+		}
+		wf.SendFeedback()
+		require.Nil(t, json.Unmarshal(buf.Bytes(), fb), "unmarshal feedback")
+		assert.Equal(t, []string{"3", "4", "More results…"}, feedbackOrder(fb), "unexpected items")
+		assert.Equal(t, "Showing 3–4 of 5", *fb.Items[2].subtitle, "unexpected subtitle")
+	})
+}
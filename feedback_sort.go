@@ -0,0 +1,205 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"sort"
+	"unicode"
+
+	"go.deanishe.net/fuzzy"
+	"golang.org/x/text/unicode/norm"
+)
+
+// FieldWeights are the weights of an Item's fields when Feedback sorts
+// Items by more than their match/title. Each field that matches the query
+// is scored separately and its score scaled by the field's weight, i.e.
+// the higher the weight, the better the score. An Item's score is that of
+// its best field. Fields with a weight of 0 are ignored.
+//
+// Set Feedback.Weights or use the SortFields Option to sort on multiple
+// fields.
+type FieldWeights struct {
+	Title    float64 // Weight of Item's title
+	Subtitle float64 // Weight of Item's subtitle
+	Match    float64 // Weight of Item's match field
+	Keywords float64 // Weight of keywords set with Item.Keywords
+}
+
+// DefaultFieldWeights prefer matches in titles and match fields to
+// matches in keywords and subtitles.
+var DefaultFieldWeights = FieldWeights{
+	Title:    1.0,
+	Subtitle: 0.5,
+	Match:    1.0,
+	Keywords: 0.8,
+}
+
+// Keywords sets additional terms Item is sorted on when Feedback.Weights
+// is set. They aren't sent to Alfred.
+func (it *Item) Keywords(kw ...string) *Item {
+	it.keywords = kw
+	return it
+}
+
+// Boost adds n to Item's score when it matches the query in Feedback.Sort
+// and Feedback.Filter. Use a negative value to move Item down the results.
+func (it *Item) Boost(n float64) *Item {
+	it.boost = n
+	return it
+}
+
+// sortWith sorts Items against query using Matcher m. If Feedback.Weights
+// is set, each of an Item's fields is matched separately.
+func (fb *Feedback) sortWith(m Matcher, query string) []*fuzzy.Result {
+	res := make([]*fuzzy.Result, len(fb.Items))
+	for i, it := range fb.Items {
+		if fb.Weights == nil {
+			r := m.Match(fb.Keywords(i), query)
+			res[i] = &r
+			continue
+		}
+
+		var (
+			w = fb.Weights
+			r = &fuzzy.Result{Query: query, SortKey: it.title}
+		)
+		try := func(text string, weight float64) {
+			if weight <= 0 || text == "" {
+				return
+			}
+			v := m.Match(text, query)
+			if !v.Match {
+				return
+			}
+			score := weightScore(v.Score, weight)
+			if !r.Match || score > r.Score {
+				r.Match, r.Score, r.SortKey = true, score, text
+			}
+		}
+
+		try(it.title, w.Title)
+		if it.subtitle != nil {
+			try(*it.subtitle, w.Subtitle)
+		}
+		if it.match != nil {
+			try(*it.match, w.Match)
+		}
+		for _, kw := range it.keywords {
+			try(kw, w.Keywords)
+		}
+		res[i] = r
+	}
+	sort.Stable(rankedItems{fb.Items, res})
+	return res
+}
+
+// weightScore scales fuzzy score n by weight. As fuzzy scores may be
+// negative, negative scores are divided by weight, so a higher weight
+// always means a higher score.
+func weightScore(n, weight float64) float64 {
+	if n < 0 {
+		return n / weight
+	}
+	return n * weight
+}
+
+// rankedItems sorts Items and their fuzzy results by result.
+type rankedItems struct {
+	items []*Item
+	res   []*fuzzy.Result
+}
+
+func (r rankedItems) Len() int { return len(r.items) }
+func (r rankedItems) Less(i, j int) bool {
+	a, b := r.res[i], r.res[j]
+	if a.Match != b.Match {
+		return a.Match
+	}
+	return a.Score > b.Score
+}
+func (r rankedItems) Swap(i, j int) {
+	r.items[i], r.items[j] = r.items[j], r.items[i]
+	r.res[i], r.res[j] = r.res[j], r.res[i]
+}
+
+// MatchPositions returns the indices of the runes in str that fuzzy
+// query matches, e.g. to highlight them. It returns nil if query doesn't
+// match str. opts are the same fuzzy Options passed to Feedback.Sort.
+//
+// Like the fuzzy sorter, it prefers letters at the start of words and
+// in camel-case positions.
+func MatchPositions(str, query string, opts ...fuzzy.Option) []int {
+	var (
+		s           = fuzzy.New(nil, opts...)
+		pattern     = []rune(query)
+		runes       = []rune(str)
+		pos         []int
+		pIdx        int
+		prevMatched bool
+		prevLower   bool
+		prevSep     = true
+		haveBest    bool
+		bestIdx     int
+		bestLower   rune
+		bestScore   float64
+	)
+	fold := func(r rune) rune {
+		if s.StripDiacritics {
+			if d := []rune(norm.NFD.String(string(r))); len(d) > 0 {
+				r = d[0]
+			}
+		}
+		return unicode.ToLower(r)
+	}
+
+	for i, c := range runes {
+		var pLower rune
+		havePattern := pIdx < len(pattern)
+		if havePattern {
+			pLower = fold(pattern[pIdx])
+		}
+		sLower := fold(c)
+
+		nextMatch := havePattern && pLower == sLower
+		rematch := haveBest && bestLower == sLower
+		if haveBest && (nextMatch || (havePattern && bestLower == pLower)) {
+			pos = append(pos, bestIdx)
+			haveBest, bestScore = false, 0
+		}
+
+		if nextMatch || rematch {
+			var score float64
+			if prevMatched {
+				score += s.AdjacencyBonus
+			}
+			if prevSep {
+				score += s.SeparatorBonus
+			}
+			if prevLower && unicode.IsUpper(c) {
+				score += s.CamelBonus
+			}
+			if nextMatch {
+				pIdx++
+			}
+			if score >= bestScore {
+				haveBest, bestIdx, bestLower, bestScore = true, i, sLower, score
+			}
+			prevMatched = true
+		} else {
+			prevMatched = false
+		}
+		prevLower = unicode.IsLower(c)
+		prevSep = c == '_' || c == ' ' || c == '-' || c == '.' || c == '/'
+	}
+	if haveBest {
+		pos = append(pos, bestIdx)
+	}
+	if pIdx < len(pattern) {
+		return nil
+	}
+	if pos == nil {
+		pos = []int{}
+	}
+	return pos
+}
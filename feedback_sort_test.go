@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.deanishe.net/fuzzy"
+)
+
+func feedbackOrder(fb *Feedback) []string {
+	var titles []string
+	for _, it := range fb.Items {
+		titles = append(titles, it.title)
+	}
+	return titles
+}
+
+// Items are sorted on multiple fields.
+func TestFeedback_SortWeighted(t *testing.T) {
+	t.Parallel()
+
+	newFeedback := func() *Feedback {
+		fb := NewFeedback()
+		fb.NewItem("Downloads").Subtitle("zeta")
+		fb.NewItem("Zeta").Subtitle("files")
+		fb.NewItem("Desktop").Keywords("screen", "zeta")
+		fb.NewItem("Documents").Match("docs zeta")
+		return fb
+	}
+
+	// only title/match by default
+	fb := newFeedback()
+	fb.Filter("zeta")
+	assert.Equal(t, []string{"Zeta", "Documents"}, feedbackOrder(fb), "unexpected results")
+
+	fb = newFeedback()
+	fb.Weights = &DefaultFieldWeights
+	res := fb.Filter("zeta")
+	assert.Equal(t, []string{"Zeta", "Desktop", "Downloads", "Documents"}, feedbackOrder(fb), "unexpected results")
+	assert.Equal(t, "Zeta", res[0].SortKey, "unexpected sort key")
+	assert.Equal(t, "zeta", res[1].SortKey, "unexpected sort key")
+
+	// fields with 0 weight are ignored
+	fb = newFeedback()
+	fb.Weights = &FieldWeights{Title: 1, Keywords: 1}
+	fb.Filter("scr")
+	assert.Equal(t, []string{"Desktop"}, feedbackOrder(fb), "unexpected results")
+
+	fb = newFeedback()
+	fb.Weights = &FieldWeights{Title: 1}
+	fb.Filter("scr")
+	assert.Empty(t, feedbackOrder(fb), "unexpected results")
+}
+
+// Boosted Items move up the results.
+func TestItem_Boost(t *testing.T) {
+	t.Parallel()
+
+	fb := NewFeedback()
+	fb.NewItem("test one")
+	fb.NewItem("test two").Boost(50)
+	fb.NewItem("test three").Boost(-50)
+	fb.NewItem("other").Boost(100)
+	res := fb.Sort("test")
+	assert.Equal(t, []string{"test two", "test one", "test three", "other"}, feedbackOrder(fb), "unexpected order")
+	assert.False(t, res[3].Match, "boosted item matches")
+}
+
+func TestMatchPositions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		str, query string
+		x          []int
+	}{
+		{"game of thrones", "got", []int{0, 5, 8}},
+		{"OmniFocus", "of", []int{0, 4}},
+		{"Safari", "safa", []int{0, 1, 2, 3}},
+		{"xa a", "a", []int{3}},
+		{"café", "cafe", []int{0, 1, 2, 3}},
+		{"Safari", "", []int{}},
+		{"Safari", "safx", nil},
+	}
+
+	for _, td := range tests {
+		assert.Equal(t, td.x, MatchPositions(td.str, td.query), "unexpected positions for %q in %q", td.query, td.str)
+	}
+
+	// positions are consistent with fuzzy sorter
+	assert.Nil(t, MatchPositions("café", "cafe", fuzzy.StripDiacritics(false)), "diacritics not respected")
+}
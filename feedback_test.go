@@ -4,12 +4,16 @@
 package aw
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.deanishe.net/env"
 )
 
 func TestItem_Icon(t *testing.T) {
@@ -22,6 +26,7 @@ func TestItem_Icon(t *testing.T) {
 }
 
 func p(s string) *string { return &s }
+func b(v bool) *bool     { return &v }
 
 // TestFeedback_IsEmpty verifies empty feedback.
 func TestFeedback_IsEmpty(t *testing.T) {
@@ -95,8 +100,11 @@ func TestItem_MarshalJSON(t *testing.T) {
 			icon: &Icon{Value: "public.folder", Type: "filetype"}},
 			x: `{"title":"title","valid":false,"icon":{"path":"public.folder","type":"filetype"}}`},
 		// With type = file
-		{in: &Item{title: "title", file: true},
+		{in: &Item{title: "title", typ: TypeFile},
 			x: `{"title":"title","valid":false,"type":"file"}`},
+		// With type = file:skipcheck
+		{in: &Item{title: "title", typ: TypeFileSkipCheck},
+			x: `{"title":"title","valid":false,"type":"file:skipcheck"}`},
 		// With copy text
 		{in: &Item{title: "title", copytext: p("copy")},
 			x: `{"title":"title","valid":false,"text":{"copy":"copy"}}`},
@@ -149,21 +157,23 @@ func TestModifier_MarshalJSON(t *testing.T) {
 		// With subtitle
 		{in: &Modifier{subtitle: p("sub here")}, x: `{"subtitle":"sub here"}`},
 		// valid
-		{in: &Modifier{valid: true}, x: `{"valid":true}`},
+		{in: &Modifier{valid: b(true)}, x: `{"valid":true}`},
+		// invalid
+		{in: &Modifier{valid: b(false)}, x: `{"valid":false}`},
 		// icon
 		{in: &Modifier{icon: &Icon{"icon.png", ""}}, x: `{"icon":{"path":"icon.png"}}`},
 		// With all
 		{in: &Modifier{
 			arg:      []string{"title"},
 			subtitle: p("sub here"),
-			valid:    true,
+			valid:    b(true),
 		},
 			x: `{"arg":"title","subtitle":"sub here","valid":true}`},
 		// With variable
 		{in: &Modifier{
 			arg:      []string{"title"},
 			subtitle: p("sub here"),
-			valid:    true,
+			valid:    b(true),
 			vars:     map[string]string{"foo": "bar"},
 		},
 			x: `{"arg":"title","subtitle":"sub here","valid":true,"variables":{"foo":"bar"}}`},
@@ -294,6 +304,165 @@ func TestFeedback_MarshalJSON(t *testing.T) {
 	assert.Equal(t, string(got), want, "unexpected value")
 }
 
+// Alfred 5 feedback fields
+func TestFeedback_Alfred5(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		d     time.Duration
+		loose bool
+		skip  bool
+		x     string
+	}{
+		{0, false, false, `{"items":[]}`},
+		{time.Minute, false, false, `{"cache":{"seconds":60},"items":[]}`},
+		{time.Minute, true, false, `{"cache":{"seconds":60,"loosereload":true},"items":[]}`},
+		{time.Second, false, false, `{"cache":{"seconds":5},"items":[]}`},
+		{48 * time.Hour, false, false, `{"cache":{"seconds":86400},"items":[]}`},
+		{0, false, true, `{"skipknowledge":true,"items":[]}`},
+	}
+
+	for _, td := range tests {
+		fb := NewFeedback().Cache(td.d, td.loose).SkipKnowledge(td.skip)
+		data, err := json.Marshal(fb)
+		require.Nil(t, err, "marshal Feedback failed")
+		assert.Equal(t, td.x, string(data), "unexpected JSON")
+	}
+}
+
+// Feedback is compatible with the running version of Alfred
+func TestFeedback_AlfredVersion(t *testing.T) {
+	t.Parallel()
+
+	newFeedback := func() *Feedback {
+		fb := NewFeedback().Cache(time.Minute, false).SkipKnowledge(true)
+		it := fb.NewItem("title").Arg("one", "two").Action("one")
+		it.NewModifier("cmd").Arg("three", "four")
+		it.NewModifier("cmd", "alt").Subtitle("combined")
+		return fb
+	}
+
+	tests := []struct {
+		version string
+		x       string
+	}{
+		{"", `{"cache":{"seconds":60},"skipknowledge":true,"items":[{"title":"title","arg":["one","two"],"valid":false,` +
+			`"mods":{"alt+cmd":{"subtitle":"combined"},"cmd":{"arg":["three","four"]}},"action":{"auto":["one"]}}]}`},
+		{"5.0.1", `{"cache":{"seconds":60},"skipknowledge":true,"items":[{"title":"title","arg":["one","two"],"valid":false,` +
+			`"mods":{"alt+cmd":{"subtitle":"combined"},"cmd":{"arg":["three","four"]}},"action":{"auto":["one"]}}]}`},
+		{"4.6", `{"items":[{"title":"title","arg":["one","two"],"valid":false,` +
+			`"mods":{"alt+cmd":{"subtitle":"combined"},"cmd":{"arg":["three","four"]}},"action":{"auto":["one"]}}]}`},
+		{"4.1", `{"items":[{"title":"title","arg":["one","two"],"valid":false,` +
+			`"mods":{"alt+cmd":{"subtitle":"combined"},"cmd":{"arg":["three","four"]}}}]}`},
+		{"4.0.9", `{"items":[{"title":"title","arg":"one","valid":false,` +
+			`"mods":{"alt+cmd":{"subtitle":"combined"},"cmd":{"arg":"three"}}}]}`},
+		{"3.8.1", `{"items":[{"title":"title","arg":"one","valid":false,"mods":{"cmd":{"arg":"three"}}}]}`},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.version, func(t *testing.T) {
+			t.Parallel()
+			fb := newFeedback()
+			fb.version = parseAlfredVersion(td.version)
+			data, err := json.Marshal(fb)
+			require.Nil(t, err, "marshal Feedback failed")
+			assert.Equal(t, td.x, string(data), "unexpected JSON")
+		})
+	}
+}
+
+func TestParseAlfredVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in string
+		x  alfredVersion
+	}{
+		{"", alfredVersion{}},
+		{"x", alfredVersion{}},
+		{"5", alfredVersion{5, 0}},
+		{"4.5", alfredVersion{4, 5}},
+		{"4.5.1", alfredVersion{4, 5}},
+		{"3.8.1", alfredVersion{3, 8}},
+	}
+
+	for _, td := range tests {
+		assert.Equal(t, td.x, parseAlfredVersion(td.in), "unexpected version for %q", td.in)
+	}
+}
+
+// Streamed feedback is the same as marshalled feedback
+func TestFeedback_send(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fb      *Feedback
+		compact bool
+	}{
+		{NewFeedback(), false},
+		{NewFeedback(), true},
+		{benchFeedback(1), false},
+		{benchFeedback(1), true},
+		{benchFeedback(20), false},
+		{benchFeedback(20), true},
+	}
+
+	for i, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("send(%d)", i), func(t *testing.T) {
+			t.Parallel()
+			var (
+				x   []byte
+				err error
+				buf bytes.Buffer
+			)
+			if td.compact {
+				x, err = Marshal(td.fb)
+			} else {
+				x, err = MarshalIndent(td.fb, "", "  ")
+			}
+			require.Nil(t, err, "marshal Feedback failed")
+
+			td.fb.compact = td.compact
+			require.Nil(t, td.fb.send(&buf), "send Feedback failed")
+			assert.Equal(t, string(bytes.TrimSpace(x))+"\n", buf.String(), "unexpected JSON")
+			assert.True(t, json.Valid(buf.Bytes()), "invalid JSON")
+
+			// only sent JSON ends with a newline
+			x, err = Marshal(td.fb)
+			require.Nil(t, err, "marshal Feedback failed")
+			data, err := td.fb.MarshalJSON()
+			require.Nil(t, err, "marshal Feedback failed")
+			assert.Equal(t, string(bytes.TrimSpace(x)), string(data), "unexpected JSON")
+		})
+	}
+}
+
+// Feedback is only indented when debugging if CompactJSON is set.
+func TestCompactJSON(t *testing.T) {
+	tests := []struct {
+		compact, debug bool
+		indented       bool
+	}{
+		{false, false, true},
+		{false, true, true},
+		{true, false, false},
+		{true, true, true},
+	}
+
+	for _, td := range tests {
+		withTestWf(func(wf *Workflow) {
+			var buf bytes.Buffer
+			wf.Configure(Output(&buf), CompactJSON(td.compact))
+			wf.Config = NewConfig(env.MapEnv{EnvVarDebug: fmt.Sprint(td.debug)})
+			wf.NewItem("title")
+			wf.SendFeedback()
+			assert.Equal(t, td.indented, bytes.Contains(buf.Bytes(), []byte("\n  ")), "unexpected indentation")
+		})
+	}
+}
+
 // Modifier inherits variables from parent Item
 func TestModifierInheritVars(t *testing.T) {
 	t.Parallel()
@@ -506,7 +675,7 @@ func TestModifier_methods(t *testing.T) {
 	assert.Equal(t, "", m.Key, "Non-empty key")
 	assert.Nil(t, m.arg, "Non-nil arg")
 	assert.Nil(t, m.subtitle, "Non-nil subtitle")
-	assert.False(t, m.valid, "Bad valid")
+	assert.Nil(t, m.valid, "Bad valid")
 	assert.Nil(t, m.icon, "Bad icon")
 
 	m.Key = key
@@ -518,7 +687,7 @@ func TestModifier_methods(t *testing.T) {
 	assert.Equal(t, key, m.Key, "Bad key")
 	assert.Equal(t, arg, m.arg, "Bad arg")
 	assert.Equal(t, subtitle, *m.subtitle, "Bad subtitle")
-	assert.Equal(t, valid, m.valid, "Bad valid")
+	assert.Equal(t, valid, *m.valid, "Bad valid")
 	assert.Equal(t, icon.Type, m.icon.Type, "Bad icon type")
 	assert.Equal(t, icon.Value, m.icon.Value, "Bad icon value")
 }
@@ -607,3 +776,49 @@ func TestFeedback_Filter(t *testing.T) {
 		}
 	}
 }
+
+// benchFeedback returns Feedback with n Items.
+func benchFeedback(n int) *Feedback {
+	fb := NewFeedback()
+	fb.Var("foo", "bar")
+	for i := 0; i < n; i++ {
+		s := fmt.Sprintf("item %d", i)
+		it := fb.NewItem(s).
+			Subtitle("subtitle of " + s).
+			Arg("/path/to/" + s).
+			UID(s).
+			Valid(true).
+			Icon(IconInfo)
+		it.Cmd().Subtitle("alternate " + s).Arg(s)
+	}
+	return fb
+}
+
+func benchmarkSend(b *testing.B, n int, compact bool) {
+	fb := benchFeedback(n)
+	fb.compact = compact
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fb.sent = false
+		if err := fb.send(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFeedback_Send100(b *testing.B)          { benchmarkSend(b, 100, false) }
+func BenchmarkFeedback_Send10000(b *testing.B)        { benchmarkSend(b, 10000, false) }
+func BenchmarkFeedback_SendCompact100(b *testing.B)   { benchmarkSend(b, 100, true) }
+func BenchmarkFeedback_SendCompact10000(b *testing.B) { benchmarkSend(b, 10000, true) }
+
+func BenchmarkFeedback_MarshalIndent10000(b *testing.B) {
+	fb := benchFeedback(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalIndent(fb, "", "  "); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
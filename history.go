@@ -0,0 +1,221 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"go.deanishe.net/fuzzy"
+
+	"github.com/ChicK00o/awgo/util"
+)
+
+// Default History settings.
+const (
+	DefaultHistoryHalfLife = 7 * 24 * time.Hour
+	DefaultHistoryWeight   = 10.0
+)
+
+// minHistoryScore is the score below which entries are forgotten.
+const minHistoryScore = 0.01
+
+// History remembers which results a user selects and ranks them by
+// "frecency" (a combination of frequency and recency).
+//
+// Each time a key (typically an Item's UID or arg) is recorded, its
+// score is increased by 1. Scores decay over time, halving every HalfLife.
+//
+// Set Feedback.History (or use the UseHistory Option) to blend frecency
+// scores into the results of Feedback.Sort and Feedback.Filter. Record
+// selections with History.Record or the "remember" magic action, e.g. by
+// calling your workflow with "workflow:remember {query}" from the Run
+// Script action connected to your Script Filter.
+type History struct {
+	// HalfLife is how long it takes for a score to halve.
+	// Defaults to DefaultHistoryHalfLife.
+	HalfLife time.Duration
+	// Weight is what a frecency score is multiplied by before it is
+	// added to an Item's fuzzy match score. Defaults to DefaultHistoryWeight.
+	Weight float64
+
+	cache   *Cache
+	name    string
+	entries map[string]historyEntry // nil until loaded
+}
+
+// historyEntry is the score of a key at a point in time.
+type historyEntry struct {
+	Score   float64   `json:"score"`
+	Count   int       `json:"count"`
+	Updated time.Time `json:"updated"`
+}
+
+// NewHistory creates a History that is saved in Cache c under name.
+func NewHistory(c *Cache, name string) *History {
+	return &History{
+		HalfLife: DefaultHistoryHalfLife,
+		Weight:   DefaultHistoryWeight,
+		cache:    c,
+		name:     name,
+	}
+}
+
+// History returns the workflow's selection history, which is saved in
+// the workflow's data directory.
+func (wf *Workflow) History() *History {
+	if wf.history == nil {
+		wf.history = NewHistory(NewCache(wf.awDataDir()), "history.json")
+	}
+	return wf.history
+}
+
+// Record increases the score of key by 1 and saves the History.
+func (h *History) Record(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty history key")
+	}
+	return h.update(func(entries map[string]historyEntry) {
+		now := time.Now()
+		e := entries[key]
+		e.Score = h.decay(e, now) + 1
+		e.Count++
+		e.Updated = now
+		entries[key] = e
+	})
+}
+
+// Forget removes key from the History.
+func (h *History) Forget(key string) error {
+	return h.update(func(entries map[string]historyEntry) { delete(entries, key) })
+}
+
+// Clear deletes the History.
+func (h *History) Clear() error {
+	h.entries = map[string]historyEntry{}
+	return h.cache.Store(h.name, nil)
+}
+
+// Score returns the current (decayed) score of key. It is 0 if key has
+// never been recorded.
+func (h *History) Score(key string) float64 {
+	if h.entries == nil {
+		if err := h.load(); err != nil {
+			return 0
+		}
+	}
+	e, ok := h.entries[key]
+	if !ok {
+		return 0
+	}
+	return h.decay(e, time.Now())
+}
+
+// Keys returns all keys in the History, highest score first.
+func (h *History) Keys() []string {
+	if h.entries == nil {
+		_ = h.load()
+	}
+	keys := make([]string, 0, len(h.entries))
+	for k := range h.entries {
+		keys = append(keys, k)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := h.Score(keys[i]), h.Score(keys[j])
+		if a == b {
+			return keys[i] < keys[j]
+		}
+		return a > b
+	})
+	return keys
+}
+
+// ItemScore returns the score of an Item, i.e. that of its UID or, if
+// the Item has no UID or the UID has no score, that of its (first) arg.
+func (h *History) ItemScore(it *Item) float64 {
+	if it.uid != nil && *it.uid != "" {
+		if n := h.Score(*it.uid); n > 0 {
+			return n
+		}
+	}
+	if len(it.arg) > 0 {
+		return h.Score(it.arg[0])
+	}
+	return 0
+}
+
+// decay returns the score of e at time t.
+func (h *History) decay(e historyEntry, t time.Time) float64 {
+	halfLife := h.HalfLife
+	if halfLife <= 0 {
+		halfLife = DefaultHistoryHalfLife
+	}
+	return e.Score * math.Pow(0.5, float64(t.Sub(e.Updated))/float64(halfLife))
+}
+
+// load reads the History from disk.
+func (h *History) load() error {
+	h.entries = map[string]historyEntry{}
+	if !h.cache.Exists(h.name) {
+		return nil
+	}
+	data, err := h.cache.Load(h.name)
+	if err != nil {
+		return fmt.Errorf("load history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.entries); err != nil {
+		return fmt.Errorf("unmarshal history: %w", err)
+	}
+	return nil
+}
+
+// update reloads the History, calls fn to modify it and saves it. The
+// file is locked while doing so, so concurrent updates aren't lost.
+func (h *History) update(fn func(entries map[string]historyEntry)) error {
+	lock := util.NewLockFile(h.cache.lockPath(h.name))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := lock.Lock(ctx); err != nil {
+		return fmt.Errorf("lock history: %w", err)
+	}
+	defer lock.Unlock()
+
+	if err := h.load(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fn(h.entries)
+
+	// forget entries whose scores have decayed to nothing
+	now := time.Now()
+	for k, e := range h.entries {
+		if h.decay(e, now) < minHistoryScore {
+			delete(h.entries, k)
+		}
+	}
+
+	data, err := json.MarshalIndent(h.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal history: %w", err)
+	}
+	return h.cache.Store(h.name, data)
+}
+
+// addScores adds the weighted frecency scores of matching items to
+// their fuzzy results.
+func (h *History) addScores(items []*Item, res []*fuzzy.Result) {
+	weight := h.Weight
+	if weight == 0 {
+		weight = DefaultHistoryWeight
+	}
+	for i, it := range items {
+		if res[i].Match {
+			res[i].Score += weight * h.ItemScore(it)
+		}
+	}
+}
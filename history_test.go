@@ -0,0 +1,127 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Recorded keys are scored, decay and can be removed.
+func TestHistory(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		h := wf.History()
+		assert.Equal(t, 0.0, h.Score("one"), "unrecorded key has score")
+		assert.NotNil(t, h.Record(""), "recorded empty key")
+
+		require.Nil(t, h.Record("one"), "record failed")
+		require.Nil(t, h.Record("one"), "record failed")
+		require.Nil(t, h.Record("two"), "record failed")
+		assert.InDelta(t, 2.0, h.Score("one"), 0.001, "unexpected score")
+		assert.InDelta(t, 1.0, h.Score("two"), 0.001, "unexpected score")
+		assert.Equal(t, []string{"one", "two"}, h.Keys(), "unexpected keys")
+
+		// History is persisted
+		h2 := NewHistory(wf.History().cache, "history.json")
+		assert.InDelta(t, 2.0, h2.Score("one"), 0.001, "history not saved")
+
+		// scores halve every HalfLife
+		require.Nil(t, h.update(func(entries map[string]historyEntry) {
+			e := entries["one"]
+			e.Updated = e.Updated.Add(-h.HalfLife)
+			entries["one"] = e
+		}), "update failed")
+		assert.InDelta(t, 1.0, h.Score("one"), 0.001, "score did not decay")
+
+		// decayed entries are forgotten
+		require.Nil(t, h.update(func(entries map[string]historyEntry) {
+			e := entries["two"]
+			e.Updated = e.Updated.Add(-10 * h.HalfLife)
+			entries["two"] = e
+		}), "update failed")
+		assert.Equal(t, []string{"one"}, h.Keys(), "decayed key not forgotten")
+
+		require.Nil(t, h.Forget("one"), "forget failed")
+		assert.Equal(t, 0.0, h.Score("one"), "key not forgotten")
+
+		require.Nil(t, h.Record("three"), "record failed")
+		require.Nil(t, h.Clear(), "clear failed")
+		assert.Empty(t, h.Keys(), "history not cleared")
+		assert.Empty(t, NewHistory(h.cache, h.name).Keys(), "saved history not cleared")
+	})
+}
+
+func TestHistory_decay(t *testing.T) {
+	t.Parallel()
+
+	var (
+		h   = &History{HalfLife: time.Hour}
+		now = time.Now()
+	)
+	tests := []struct {
+		age time.Duration
+		x   float64
+	}{
+		{0, 4},
+		{time.Hour, 2},
+		{2 * time.Hour, 1},
+		{30 * time.Minute, 4 / math.Sqrt2},
+	}
+
+	for _, td := range tests {
+		e := historyEntry{Score: 4, Updated: now.Add(-td.age)}
+		assert.InDelta(t, td.x, h.decay(e, now), 0.001, "unexpected score")
+	}
+}
+
+// Frecently-used Items are moved up the results.
+func TestHistory_Filter(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		titles := func() []string {
+			var s []string
+			for _, it := range wf.Feedback.Items {
+				s = append(s, it.title)
+			}
+			return s
+		}
+
+		items := func() {
+			wf.Feedback.Clear()
+			wf.NewItem("alpha").UID("a")
+			wf.NewItem("alphabet").UID("b")
+			wf.NewItem("alpha beta").Arg("c")
+			wf.NewItem("gamma").UID("g")
+		}
+
+		items()
+		wf.Filter("alpha")
+		assert.Equal(t, []string{"alpha", "alphabet", "alpha beta"}, titles(), "unexpected order")
+
+		require.Nil(t, wf.History().Record("c"), "record failed")
+		// non-matches aren't promoted
+		require.Nil(t, wf.History().Record("g"), "record failed")
+		wf.Configure(UseHistory(true))
+		items()
+		wf.Filter("alpha")
+		assert.Equal(t, []string{"alpha beta", "alpha", "alphabet"}, titles(), "history ignored")
+	})
+}
+
+// Magic actions record and clear history.
+func TestHistory_Magic(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		ma := wf.magicActions
+		_, v := ma.handleArgs([]string{"workflow:remember some key"}, DefaultMagicPrefix)
+		assert.True(t, v, "magic action not handled")
+		assert.InDelta(t, 1.0, wf.History().Score("some key"), 0.001, "key not recorded")
+
+		_, v = ma.handleArgs([]string{"workflow:delhistory"}, DefaultMagicPrefix)
+		assert.True(t, v, "magic action not handled")
+		assert.Empty(t, wf.History().Keys(), "history not cleared")
+	})
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+// Package testenv creates the throwaway directories of a test workflow
+// environment. It is shared by AwGo's own tests and package awtest, and
+// doesn't import aw, so it can be used by aw's internal tests.
+package testenv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// TempDir creates a new temporary directory whose name starts with prefix.
+// Symlinks in the returned path are resolved, as TempDir() returns a
+// symlink on macOS.
+func TempDir(prefix string) (string, error) {
+	tmp, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(tmp)
+	if err != nil {
+		os.RemoveAll(tmp) // nolint: errcheck
+		return "", err
+	}
+	return dir, nil
+}
+
+// CacheDir returns the path of the workflow cache directory in dir.
+func CacheDir(dir string) string { return filepath.Join(dir, "cache") }
+
+// DataDir returns the path of the workflow data directory in dir.
+func DataDir(dir string) string { return filepath.Join(dir, "data") }
+
+// Workdirs creates the workflow cache and data directories in dir and
+// returns their paths.
+func Workdirs(dir string) (cacheDir, dataDir string, err error) {
+	cacheDir, dataDir = CacheDir(dir), DataDir(dir)
+	if err = MkDirs(cacheDir, dataDir); err != nil {
+		return "", "", err
+	}
+	return cacheDir, dataDir, nil
+}
+
+// MkDirs creates directories and any missing parents. Empty paths are
+// ignored.
+func MkDirs(paths ...string) error {
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if err := os.MkdirAll(p, 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package testenv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkdirs(t *testing.T) {
+	t.Parallel()
+
+	dir, err := TempDir("awgo-")
+	require.Nil(t, err, "create tempdir")
+	defer func() { assert.Nil(t, os.RemoveAll(dir), "remove tempdir") }()
+
+	p, err := filepath.EvalSymlinks(dir)
+	require.Nil(t, err, "resolve tempdir")
+	assert.Equal(t, p, dir, "symlinks not resolved")
+
+	cacheDir, dataDir, err := Workdirs(dir)
+	require.Nil(t, err, "create workdirs")
+	assert.Equal(t, CacheDir(dir), cacheDir, "unexpected cache dir")
+	assert.Equal(t, DataDir(dir), dataDir, "unexpected data dir")
+	for _, p := range []string{cacheDir, dataDir} {
+		fi, err := os.Stat(p)
+		require.Nil(t, err, "stat workdir")
+		assert.True(t, fi.IsDir(), "not a directory")
+	}
+	assert.Nil(t, MkDirs(""), "empty path not ignored")
+}
@@ -0,0 +1,311 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/ChicK00o/awgo/util"
+)
+
+// JobState is the state of a background job.
+type JobState int
+
+// Valid JobState values.
+const (
+	JobNotFound  JobState = iota // Job has never been run
+	JobRunning                   // Job is currently running
+	JobSucceeded                 // Job exited with status 0
+	JobFailed                    // Job exited with a non-zero status or died
+	JobTimedOut                  // Job was killed because it exceeded its maximum runtime
+	JobKilled                    // Job was stopped with Kill
+)
+
+// String implements Stringer.
+func (s JobState) String() string {
+	switch s {
+	case JobNotFound:
+		return "not found"
+	case JobRunning:
+		return "running"
+	case JobSucceeded:
+		return "succeeded"
+	case JobFailed:
+		return "failed"
+	case JobTimedOut:
+		return "timed out"
+	case JobKilled:
+		return "killed"
+	default:
+		return fmt.Sprintf("JobState(%d)", int(s))
+	}
+}
+
+// JobStatus describes the current or last run of a background job.
+type JobStatus struct {
+	Name       string        // Name of job
+	State      JobState      // Current state of job
+	PID        int           // Process ID of job (0 if unknown)
+	Command    []string      // Command and its arguments
+	Started    time.Time     // When job was started
+	Finished   time.Time     // When job exited (zero if still running or unknown)
+	ExitCode   int           // Exit status of command. -1 if unknown.
+	MaxRuntime time.Duration // Job is killed if it runs longer than this. 0 = no limit.
+}
+
+// Runtime returns how long the job has been running, or how long it ran
+// for if it has finished.
+func (s JobStatus) Runtime() time.Duration {
+	if s.Started.IsZero() {
+		return 0
+	}
+	if s.Finished.IsZero() {
+		return time.Since(s.Started)
+	}
+	return s.Finished.Sub(s.Started)
+}
+
+// Job is a background job. Get one with Workflow.Job or Workflow.RunJob.
+type Job struct {
+	Name string // Name of job
+	wf   *Workflow
+}
+
+// jobRecord is saved when a job is started via RunJob.
+type jobRecord struct {
+	PID        int           `json:"pid"`
+	Command    []string      `json:"command"`
+	Started    time.Time     `json:"started"`
+	MaxRuntime time.Duration `json:"maxRuntime"`
+}
+
+// jobExit is saved when a job started via RunJob finishes.
+type jobExit struct {
+	ExitCode int       `json:"exitCode"`
+	Finished time.Time `json:"finished"`
+	TimedOut bool      `json:"timedOut"`
+	Killed   bool      `json:"killed"`
+}
+
+// jobWrapper runs a command, records its output and exit status, and
+// kills it if it runs longer than the given number of seconds.
+// Arguments: stdout file, stderr file, exit file, timeout, command...
+//
+// The wrapper leads the job's process group, which also contains the
+// command, any processes it starts, and the watchdog that enforces the
+// timeout. On timeout or Kill, SIGTERM is sent to the whole group. The
+// wrapper ignores it (via a no-op trap, which isn't inherited by the
+// command), sends SIGKILL to the command if it's still running 2 seconds
+// later, records the exit status, and then sends SIGKILL to the group to
+// stop any processes left behind.
+const jobWrapper = `out="$1"; err="$2"; exitf="$3"; timeout="$4"; shift 4
+trap : TERM
+"$@" >"$out" 2>"$err" </dev/null &
+pid=$!
+watchdog=
+if [ "$timeout" -gt 0 ]; then
+	(
+		trap 'kill "$s" 2>/dev/null; exit' TERM
+		sleep "$timeout" &
+		s=$!
+		wait "$s"
+		: >"$exitf.timedout"
+		kill -TERM 0
+	) &
+	watchdog=$!
+fi
+killer=
+while :; do
+	wait "$pid"
+	code=$?
+	kill -0 "$pid" 2>/dev/null || break
+	# wait was interrupted by SIGTERM
+	if [ -z "$killer" ]; then
+		( sleep 2; kill -KILL "$pid" 2>/dev/null ) &
+		killer=$!
+	fi
+done
+[ -n "$watchdog" ] && kill "$watchdog" 2>/dev/null
+timedout=false
+[ -e "$exitf.timedout" ] && timedout=true
+killed=false
+[ -e "$exitf.killed" ] && killed=true
+rm -f "$exitf.timedout" "$exitf.killed"
+printf '{"exitCode":%d,"finished":"%s","timedOut":%s,"killed":%s}\n' "$code" "$(date -u +%Y-%m-%dT%H:%M:%SZ)" "$timedout" "$killed" >"$exitf"
+if [ "$timedout" = true ] || [ "$killed" = true ]; then
+	kill -KILL 0
+fi
+`
+
+// Job returns the background job with the given name. The job need not
+// exist or be running.
+func (wf *Workflow) Job(name string) *Job { return &Job{Name: name, wf: wf} }
+
+// RunJob executes cmd in the background like RunInBackground, but also
+// records the job's output, start & finish times and exit status, which
+// are available via Job.Status, Job.Stdout and Job.Stderr.
+//
+// If maxRuntime is greater than 0, the job is killed (SIGTERM, then
+// SIGKILL) if it is still running after that time. Any processes the
+// job's command started are also killed.
+//
+// cmd is run via a /bin/sh wrapper, so its Stdin, Stdout and Stderr are
+// ignored, and cmd itself is not started (i.e. cmd.Process is nil).
+// Its Dir, Env and SysProcAttr are respected.
+//
+// It returns an ErrJobExists error if a job of the same name is already
+// running.
+func (wf *Workflow) RunJob(name string, cmd *exec.Cmd, maxRuntime time.Duration) (*Job, error) {
+	j := wf.Job(name)
+	if wf.IsRunning(name) {
+		pid, _ := wf.getPid(name)
+		return nil, ErrJobExists{name, pid}
+	}
+
+	argv := []string{cmd.Path}
+	if len(cmd.Args) > 1 {
+		argv = append(argv, cmd.Args[1:]...)
+	}
+	secs := 0
+	if maxRuntime > 0 {
+		secs = int(math.Ceil(maxRuntime.Seconds()))
+	}
+
+	for _, p := range []string{j.exitFile(), j.exitFile() + ".timedout", j.killedFile()} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("delete previous job status: %w", err)
+		}
+	}
+
+	args := append([]string{"-c", jobWrapper, "sh", j.StdoutFile(), j.StderrFile(), j.exitFile(), strconv.Itoa(secs)}, argv...)
+	wrapper := exec.Command("/bin/sh", args...)
+	wrapper.Dir = cmd.Dir
+	wrapper.Env = cmd.Env
+	wrapper.SysProcAttr = cmd.SysProcAttr
+
+	if err := wf.RunInBackground(name, wrapper); err != nil {
+		return nil, err
+	}
+	// Reap wrapper if this process is still running when it exits
+	go func() { _ = wrapper.Wait() }()
+
+	rec := jobRecord{
+		PID:        wrapper.Process.Pid,
+		Command:    cmd.Args,
+		Started:    time.Now(),
+		MaxRuntime: maxRuntime,
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal job record: %w", err)
+	}
+	if err := util.WriteFile(j.recordFile(), data, 0600); err != nil {
+		return nil, fmt.Errorf("save job record: %w", err)
+	}
+	return j, nil
+}
+
+// Status returns the status of the job's current or most recent run.
+//
+// Jobs started with RunInBackground are reported as JobRunning or
+// JobNotFound, as AwGo doesn't record their details.
+func (j *Job) Status() JobStatus {
+	st := JobStatus{Name: j.Name, ExitCode: -1}
+
+	running := j.wf.IsRunning(j.Name)
+	if running {
+		st.State = JobRunning
+		st.PID, _ = j.wf.getPid(j.Name)
+	}
+
+	var rec jobRecord
+	data, err := ioutil.ReadFile(j.recordFile())
+	if err != nil || json.Unmarshal(data, &rec) != nil {
+		return st
+	}
+	st.PID = rec.PID
+	st.Command = rec.Command
+	st.Started = rec.Started
+	st.MaxRuntime = rec.MaxRuntime
+	if running {
+		return st
+	}
+
+	var exit jobExit
+	data, err = ioutil.ReadFile(j.exitFile())
+	if err != nil || json.Unmarshal(data, &exit) != nil {
+		// Job died without recording its exit status
+		st.State = JobFailed
+		return st
+	}
+	st.ExitCode = exit.ExitCode
+	st.Finished = exit.Finished
+	switch {
+	case exit.Killed:
+		st.State = JobKilled
+	case exit.TimedOut:
+		st.State = JobTimedOut
+	case exit.ExitCode == 0:
+		st.State = JobSucceeded
+	default:
+		st.State = JobFailed
+	}
+	return st
+}
+
+// Stdout returns the job's recorded STDOUT.
+func (j *Job) Stdout() ([]byte, error) { return ioutil.ReadFile(j.StdoutFile()) }
+
+// Stderr returns the job's recorded STDERR.
+func (j *Job) Stderr() ([]byte, error) { return ioutil.ReadFile(j.StderrFile()) }
+
+// StdoutFile returns the path of the file the job's STDOUT is written to.
+func (j *Job) StdoutFile() string { return j.wf.jobFile(j.Name, ".stdout") }
+
+// StderrFile returns the path of the file the job's STDERR is written to.
+func (j *Job) StderrFile() string { return j.wf.jobFile(j.Name, ".stderr") }
+
+// Kill stops the job. See Workflow.Kill.
+func (j *Job) Kill() error { return j.wf.Kill(j.Name) }
+
+// recordKilled saves the exit status of a job stopped with Kill.
+func (j *Job) recordKilled() error {
+	if !util.PathExists(j.recordFile()) {
+		return nil
+	}
+	data, err := json.Marshal(jobExit{ExitCode: -1, Finished: time.Now(), Killed: true})
+	if err != nil {
+		return err
+	}
+	return util.WriteFile(j.exitFile(), data, 0600)
+}
+
+func (j *Job) recordFile() string { return j.wf.jobFile(j.Name, ".job") }
+func (j *Job) exitFile() string   { return j.wf.jobFile(j.Name, ".exit") }
+func (j *Job) killedFile() string { return j.exitFile() + ".killed" }
+
+// jobFile returns the path of a job-specific file.
+func (wf *Workflow) jobFile(jobName, ext string) string {
+	dir := util.MustExist(filepath.Join(wf.awCacheDir(), "jobs"))
+	return filepath.Join(dir, jobName+ext)
+}
+
+// killGroup sends SIGTERM to the process group led by pid, falling back
+// to the process itself. Used for jobs started with RunJob, whose wrapper
+// leads the job's process group.
+func killGroup(pid int) error {
+	if err := syscall.Kill(-pid, syscall.SIGTERM); err == nil {
+		return nil
+	}
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForJob waits until job is no longer running.
+func waitForJob(t *testing.T, j *Job, timeout time.Duration) JobStatus {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if st := j.Status(); st.State != JobRunning {
+			return st
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("job %q still running after %v", j.Name, timeout)
+	return JobStatus{}
+}
+
+// waitForGroup waits until process group pgid has no live processes.
+func waitForGroup(t *testing.T, pgid int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := exec.Command("ps", "-A", "-o", "pgid=,stat=").Output()
+		require.Nil(t, err, "list processes")
+		n := 0
+		for _, line := range strings.Split(string(out), "\n") {
+			f := strings.Fields(line)
+			if len(f) == 2 && f[0] == strconv.Itoa(pgid) && !strings.HasPrefix(f[1], "Z") {
+				n++
+			}
+		}
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d process(es) in group %d still running after %v", n, pgid, timeout)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Output and exit status of jobs are recorded.
+func TestWorkflow_RunJob(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		script string
+		state  JobState
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"success", "echo out; echo err >&2", JobSucceeded, 0, "out\n", "err\n"},
+		{"failure", "echo failed >&2; exit 3", JobFailed, 3, "", "failed\n"},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			t.Parallel()
+			withTestWf(func(wf *Workflow) {
+				assert.Equal(t, JobNotFound, wf.Job(td.name).Status().State, "unexpected state")
+
+				j, err := wf.RunJob(td.name, exec.Command("/bin/sh", "-c", td.script), 0)
+				require.Nil(t, err, "start job")
+
+				st := waitForJob(t, j, 5*time.Second)
+				assert.Equal(t, td.state, st.State, "unexpected state")
+				assert.Equal(t, td.code, st.ExitCode, "unexpected exit code")
+				assert.Equal(t, []string{"/bin/sh", "-c", td.script}, st.Command, "unexpected command")
+				assert.False(t, st.Started.IsZero(), "start time not set")
+				assert.False(t, st.Finished.IsZero(), "finish time not set")
+
+				data, err := j.Stdout()
+				require.Nil(t, err, "read stdout")
+				assert.Equal(t, td.stdout, string(data), "unexpected stdout")
+				data, err = j.Stderr()
+				require.Nil(t, err, "read stderr")
+				assert.Equal(t, td.stderr, string(data), "unexpected stderr")
+			})
+		})
+	}
+}
+
+// Jobs are killed after their maximum runtime.
+func TestWorkflow_RunJob_timeout(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		j, err := wf.RunJob("sleep", exec.Command("sleep", "10"), time.Second)
+		require.Nil(t, err, "start job")
+
+		st := j.Status()
+		assert.Equal(t, JobRunning, st.State, "job not running")
+		assert.Equal(t, time.Second, st.MaxRuntime, "unexpected max runtime")
+		assert.True(t, st.Runtime() > 0, "unexpected runtime")
+
+		_, err = wf.RunJob("sleep", exec.Command("sleep", "10"), 0)
+		assert.True(t, IsJobExists(err), "duplicate job started")
+
+		st = waitForJob(t, j, 5*time.Second)
+		assert.Equal(t, JobTimedOut, st.State, "job not timed out")
+		assert.True(t, st.Runtime() < 5*time.Second, "unexpected runtime")
+	})
+}
+
+// Killed jobs are recorded as such.
+func TestJob_Kill(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		j, err := wf.RunJob("sleep", exec.Command("sleep", "10"), 0)
+		require.Nil(t, err, "start job")
+		require.Equal(t, JobRunning, j.Status().State, "job not running")
+
+		require.Nil(t, j.Kill(), "kill job")
+		st := waitForJob(t, j, 5*time.Second)
+		assert.Equal(t, JobKilled, st.State, "job not killed")
+	})
+}
+
+// Processes started by a job's command are stopped with it, and the
+// watchdog doesn't outlive the job.
+func TestWorkflow_RunJob_processGroup(t *testing.T) {
+	t.Parallel()
+
+	// children, one of which ignores SIGTERM
+	script := "sleep 30 & (trap '' TERM; exec sleep 30) & wait"
+	tests := []struct {
+		name       string
+		script     string
+		maxRuntime time.Duration
+		kill       bool
+		state      JobState
+	}{
+		{"timeout", script, time.Second, false, JobTimedOut},
+		{"kill", script, 0, true, JobKilled},
+		{"watchdog", "sleep 0.5", 30 * time.Second, false, JobSucceeded},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			t.Parallel()
+			withTestWf(func(wf *Workflow) {
+				j, err := wf.RunJob(td.name, exec.Command("/bin/sh", "-c", td.script), td.maxRuntime)
+				require.Nil(t, err, "start job")
+				pgid := j.Status().PID
+				if td.kill {
+					time.Sleep(200 * time.Millisecond)
+					require.Nil(t, j.Kill(), "kill job")
+				}
+				st := waitForJob(t, j, 10*time.Second)
+				assert.Equal(t, td.state, st.State, "unexpected state")
+				waitForGroup(t, pgid, 5*time.Second)
+			})
+		})
+	}
+}
+
+// Jobs started with RunInBackground have limited status.
+func TestJob_Status_RunInBackground(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		require.Nil(t, wf.RunInBackground("sleep", exec.Command("sleep", "10")), "start job")
+		st := wf.Job("sleep").Status()
+		assert.Equal(t, JobRunning, st.State, "job not running")
+		assert.NotEqual(t, 0, st.PID, "PID not set")
+		require.Nil(t, wf.Kill("sleep"), "kill job")
+	})
+}
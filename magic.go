@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 )
 
 /*
@@ -46,6 +47,11 @@ default:
 	                    (usually Finder).
 	<prefix>deldata     Delete everything in the workflow's data directory.
 	<prefix>delcache    Delete everything in the workflow's cache directory.
+	<prefix>caches      List the entries in the workflow's cache with their
+	                    size and age. Actioning an entry deletes it.
+	<prefix>remember    Record the rest of the query (e.g. an Item's UID or arg)
+	                    in the workflow's selection History.
+	<prefix>delhistory  Delete the workflow's selection History.
 	<prefix>reset       Delete everything in the workflow's data and cache directories.
 	<prefix>help        Open help URL in default browser.
 	                    Only registered if you have set a HelpURL.
@@ -81,6 +87,15 @@ type MagicAction interface {
 	Run() error
 }
 
+// queryMagicAction is a MagicAction that also accepts a query after its
+// keyword, e.g. "workflow:caches foo". Instead of Run, RunQuery is called
+// (with an empty query if there is none), and the action sends its own
+// feedback.
+type queryMagicAction interface {
+	MagicAction
+	RunQuery(query string) error
+}
+
 // magicActions contains the registered magic actions. See the MagicAction
 // interface for full documentation.
 type magicActions struct {
@@ -112,8 +127,8 @@ func (ma *magicActions) args(args []string, prefix string) []string {
 	args, handled := ma.handleArgs(args, prefix)
 
 	if handled {
-		finishLog(false)
-		exitFunc(0)
+		ma.wf.finishLog(false)
+		ma.wf.exit(0)
 	}
 
 	return args
@@ -131,7 +146,18 @@ func (ma *magicActions) handleArgs(args []string, prefix string) ([]string, bool
 			query := arg[len(prefix):]
 			action := ma.actions[query]
 
-			if action != nil {
+			kw, rest := query, ""
+			if i := strings.Index(query, " "); i > 0 {
+				kw, rest = query[:i], strings.TrimSpace(query[i+1:])
+			}
+			if qa, ok := ma.actions[kw].(queryMagicAction); ok {
+				log.Print(qa.RunText())
+				if err := qa.RunQuery(rest); err != nil {
+					log.Printf("Error running magic arg `%s`: %s", qa.Description(), err)
+					ma.wf.finishLog(true)
+				}
+				handled = true
+			} else if action != nil {
 				log.Print(action.RunText())
 
 				ma.wf.NewItem(action.RunText()).
@@ -142,7 +168,7 @@ func (ma *magicActions) handleArgs(args []string, prefix string) ([]string, bool
 
 				if err := action.Run(); err != nil {
 					log.Printf("Error running magic arg `%s`: %s", action.Description(), err)
-					finishLog(true)
+					ma.wf.finishLog(true)
 				}
 
 				handled = true
@@ -209,6 +235,133 @@ func (a clearCacheMA) Description() string { return "Delete workflow's cached da
 func (a clearCacheMA) RunText() string     { return "Deleted workflow's cached data" }
 func (a clearCacheMA) Run() error          { return a.wf.ClearCache() }
 
+// cacheDeleteVar is the variable set on the Items of the "caches" magic
+// action. When an Item is actioned, the workflow is called with the Item's
+// arg and variable, and deletes the cache entry named by both. As Alfred
+// passes Item variables only to the following actions, typing a query
+// never deletes an entry.
+const cacheDeleteVar = "AW_CACHE_DELETE"
+
+// Lists entries in the workflow's cache and deletes the actioned one.
+type cacheListMA struct {
+	wf *Workflow
+}
+
+func (a cacheListMA) Keyword() string     { return "caches" }
+func (a cacheListMA) Description() string { return "List workflow's cached data" }
+func (a cacheListMA) RunText() string     { return "Listing workflow's cached data…" }
+func (a cacheListMA) Run() error          { return a.RunQuery("") }
+func (a cacheListMA) RunQuery(query string) error {
+	var (
+		wf     = a.wf
+		prefix = wf.getMagicPrefix() + a.Keyword()
+	)
+
+	entries, err := wf.Cache.List()
+	if err != nil {
+		return err
+	}
+
+	if name := wf.Config.Get(cacheDeleteVar); name != "" && name == query {
+		return a.delete(entries, name)
+	}
+
+	for _, e := range entries {
+		wf.NewItem(e.Name).
+			Subtitle(fmt.Sprintf("%s, %s old  ·  ↩ to delete", formatSize(e.Size), formatAge(e.Age()))).
+			Arg(prefix + " " + e.Name).
+			Var(cacheDeleteVar, e.Name).
+			Autocomplete(prefix + " " + e.Name).
+			Icon(IconTrash).
+			Valid(true)
+	}
+	if query != "" {
+		wf.Filter(query)
+		wf.WarnEmpty("No such entry", "Try another query?")
+	}
+	wf.WarnEmpty("No cached data", "")
+	wf.SendFeedback()
+	return nil
+}
+
+// delete removes the named cache entry.
+func (a cacheListMA) delete(entries []CacheEntry, name string) error {
+	wf := a.wf
+	for _, e := range entries {
+		if e.Name != name {
+			continue
+		}
+		if err := wf.Cache.remove(e); err != nil {
+			return err
+		}
+		log.Printf("deleted cache %q", name)
+		wf.NewItem(fmt.Sprintf("Deleted %q", name)).
+			Icon(IconTrash).
+			Valid(false)
+		wf.SendFeedback()
+		return nil
+	}
+	log.Printf("no cache entry named %q", name)
+	wf.NewWarningItem("No such entry", name)
+	wf.SendFeedback()
+	return nil
+}
+
+// formatSize returns a human-readable file size.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for i := n / unit; i >= unit; i /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatAge returns a human-readable duration rounded to a sensible unit.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return d.Round(time.Second).String()
+	case d < time.Hour:
+		return d.Round(time.Minute).String()
+	case d < 48*time.Hour:
+		return d.Round(time.Hour).String()
+	default:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+}
+
+// Records a selection in the workflow's History.
+type rememberMA struct {
+	wf *Workflow
+}
+
+func (a rememberMA) Keyword() string     { return "remember" }
+func (a rememberMA) Description() string { return "Record a selection in workflow's history" }
+func (a rememberMA) RunText() string     { return "Recording selection…" }
+func (a rememberMA) Run() error          { return a.RunQuery("") }
+func (a rememberMA) RunQuery(query string) error {
+	if err := a.wf.History().Record(query); err != nil {
+		return err
+	}
+	log.Printf("recorded selection %q", query)
+	return nil
+}
+
+// Deletes the workflow's selection history.
+type clearHistoryMA struct {
+	wf *Workflow
+}
+
+func (a clearHistoryMA) Keyword() string     { return "delhistory" }
+func (a clearHistoryMA) Description() string { return "Delete workflow's selection history" }
+func (a clearHistoryMA) RunText() string     { return "Deleted workflow's selection history" }
+func (a clearHistoryMA) Run() error          { return a.wf.History().Clear() }
+
 // Deletes the contents of the workflow's data directory.
 type clearDataMA struct {
 	wf *Workflow
@@ -4,12 +4,16 @@
 package aw
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.deanishe.net/env"
 )
 
 // Mock magic action
@@ -120,7 +124,7 @@ func TestMagicDefaults(t *testing.T) {
 		wf.Configure(HelpURL(helpURL))
 		ma := wf.magicActions
 
-		x := 7
+		x := 10
 		v := len(ma.actions)
 		if v != x {
 			t.Errorf("Bad MagicAction count. Expected=%d, Got=%d", x, v)
@@ -242,3 +246,88 @@ func TestMagicUpdate(t *testing.T) {
 	assert.True(t, u.updateAvailableCalled, "UpdateAvailable not called")
 	assert.True(t, u.installCalled, "Install not called")
 }
+
+// Test cache inspector lists and deletes entries.
+func TestMagicCacheList(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		var buf bytes.Buffer
+		wf.Configure(Output(&buf))
+		require.Nil(t, wf.Cache.Store("one.txt", []byte("1")), "store failed")
+		require.Nil(t, wf.Cache.Store("two.txt", []byte("22")), "store failed")
+
+		feedback := func() []string {
+			var fb struct {
+				Items []struct {
+					Title     string            `json:"title"`
+					Arg       string            `json:"arg"`
+					Valid     bool              `json:"valid"`
+					Variables map[string]string `json:"variables"`
+				} `json:"items"`
+			}
+			require.Nil(t, json.Unmarshal(buf.Bytes(), &fb), "invalid feedback")
+			buf.Reset()
+			wf.Feedback = &Feedback{}
+			var items []string
+			for _, it := range fb.Items {
+				items = append(items, fmt.Sprintf("%s|%s|%v|%s", it.Title, it.Arg, it.Valid, it.Variables[cacheDeleteVar]))
+			}
+			return items
+		}
+
+		ma := wf.magicActions
+		_, v := ma.handleArgs([]string{"workflow:caches"}, DefaultMagicPrefix)
+		assert.True(t, v, "magic action not handled")
+		assert.Equal(t, []string{
+			"one.txt|workflow:caches one.txt|true|one.txt",
+			"two.txt|workflow:caches two.txt|true|two.txt",
+		}, feedback(), "unexpected items")
+
+		_, v = ma.handleArgs([]string{"workflow:caches two"}, DefaultMagicPrefix)
+		assert.True(t, v, "magic action not handled")
+		assert.Equal(t, []string{"two.txt|workflow:caches two.txt|true|two.txt"}, feedback(), "unexpected items")
+
+		// typing a full name doesn't delete the entry
+		_, v = ma.handleArgs([]string{"workflow:caches two.txt"}, DefaultMagicPrefix)
+		assert.True(t, v, "magic action not handled")
+		assert.Equal(t, []string{"two.txt|workflow:caches two.txt|true|two.txt"}, feedback(), "unexpected items")
+		assert.True(t, wf.Cache.Exists("two.txt"), "cache deleted")
+
+		_, v = ma.handleArgs([]string{"workflow:caches nothing"}, DefaultMagicPrefix)
+		assert.True(t, v, "magic action not handled")
+		assert.Equal(t, []string{"No such entry||false|"}, feedback(), "unexpected items")
+
+		// actioning an item deletes its entry
+		cfg := wf.Config
+		defer func() { wf.Config = cfg }()
+		wf.Config = NewConfig(env.MapEnv{cacheDeleteVar: "two.txt"})
+		_, v = ma.handleArgs([]string{"workflow:caches two.txt"}, DefaultMagicPrefix)
+		assert.True(t, v, "magic action not handled")
+		assert.Equal(t, []string{`Deleted "two.txt"||false|`}, feedback(), "unexpected items")
+		assert.False(t, wf.Cache.Exists("two.txt"), "cache not deleted")
+		assert.True(t, wf.Cache.Exists("one.txt"), "wrong cache deleted")
+
+		// entry no longer exists
+		_, v = ma.handleArgs([]string{"workflow:caches two.txt"}, DefaultMagicPrefix)
+		assert.True(t, v, "magic action not handled")
+		assert.Equal(t, []string{"No such entry||false|"}, feedback(), "unexpected items")
+	})
+}
+
+func TestFormatSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in int64
+		x  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+	}
+
+	for _, td := range tests {
+		assert.Equal(t, td.x, formatSize(td.in), "unexpected size")
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"go.deanishe.net/fuzzy"
+)
+
+// Matcher matches a query against an Item's keywords. Set Feedback.Matcher
+// or use the UseMatcher Option to filter Items with something other than
+// the default fuzzy matching.
+//
+// The returned Result's Score must be higher for better matches. Scores
+// of the built-in Matchers are between 0 and 100. *fuzzy.Sorter is also
+// a Matcher.
+type Matcher interface {
+	Match(str, query string) fuzzy.Result
+}
+
+// ExactMatcher matches strings that are the same as the query,
+// ignoring case.
+type ExactMatcher struct{}
+
+// Match implements Matcher.
+func (m ExactMatcher) Match(str, query string) fuzzy.Result {
+	r := fuzzy.Result{Query: query, SortKey: str}
+	if strings.EqualFold(str, query) {
+		r.Match, r.Score = true, 100
+	}
+	return r
+}
+
+// PrefixMatcher matches strings that start with the query. Shorter
+// strings score higher.
+type PrefixMatcher struct {
+	CaseSensitive bool // Don't ignore case
+}
+
+// Match implements Matcher.
+func (m PrefixMatcher) Match(str, query string) fuzzy.Result {
+	r := fuzzy.Result{Query: query, SortKey: str}
+	s, q := foldCase(str, m.CaseSensitive), foldCase(query, m.CaseSensitive)
+	if strings.HasPrefix(s, q) {
+		r.Match, r.Score = true, lengthScore(q, s)
+	}
+	return r
+}
+
+// SubstringMatcher matches strings that contain the query. Matches
+// nearer the start of shorter strings score higher.
+type SubstringMatcher struct {
+	CaseSensitive bool // Don't ignore case
+}
+
+// Match implements Matcher.
+func (m SubstringMatcher) Match(str, query string) fuzzy.Result {
+	r := fuzzy.Result{Query: query, SortKey: str}
+	s, q := foldCase(str, m.CaseSensitive), foldCase(query, m.CaseSensitive)
+	if i := strings.Index(s, q); i >= 0 {
+		r.Match, r.Score = true, positionScore(lengthScore(q, s), s[:i])
+	}
+	return r
+}
+
+// InitialsMatcher matches strings whose word initials contain the
+// letters of the query in order, e.g. "gt" matches "Game of Thrones".
+// Words are separated by spaces and punctuation or start with an uppercase
+// letter (camel case). Queries that match the initials from the first
+// word score higher.
+type InitialsMatcher struct{}
+
+// Match implements Matcher.
+func (m InitialsMatcher) Match(str, query string) fuzzy.Result {
+	var (
+		r        = fuzzy.Result{Query: query, SortKey: str}
+		initials = []rune(initials(str))
+		q        = []rune(strings.ToLower(query))
+		i        int
+	)
+	for _, c := range initials {
+		if i < len(q) && c == q[i] {
+			i++
+		}
+	}
+	if i < len(q) {
+		return r
+	}
+	r.Match = true
+	if len(initials) > 0 {
+		r.Score = 100 * float64(len(q)) / float64(len(initials))
+		if len(q) > 0 && initials[0] != q[0] {
+			r.Score /= 2
+		}
+	}
+	return r
+}
+
+// initials returns the lowercase initials of the words in s.
+func initials(s string) string {
+	var (
+		b         strings.Builder
+		prevSep   = true
+		prevLower bool
+	)
+	for _, c := range s {
+		isSep := !unicode.IsLetter(c) && !unicode.IsDigit(c)
+		if !isSep && (prevSep || (prevLower && unicode.IsUpper(c))) {
+			b.WriteRune(unicode.ToLower(c))
+		}
+		prevSep = isSep
+		prevLower = unicode.IsLower(c)
+	}
+	return b.String()
+}
+
+// RegexpMatcher treats the query as a regular expression, and matches
+// strings that contain a match for it. Matching is case-insensitive
+// unless the query contains uppercase letters. Invalid expressions match
+// nothing. Use a pointer, i.e. &RegexpMatcher{}, as it caches the compiled
+// query.
+type RegexpMatcher struct {
+	mu    sync.Mutex
+	query string
+	rx    *regexp.Regexp
+}
+
+// Match implements Matcher.
+func (m *RegexpMatcher) Match(str, query string) fuzzy.Result {
+	r := fuzzy.Result{Query: query, SortKey: str}
+	rx := m.compile(query)
+	if rx == nil {
+		return r
+	}
+	if loc := rx.FindStringIndex(str); loc != nil {
+		r.Match = true
+		r.Score = positionScore(lengthScore(str[loc[0]:loc[1]], str), str[:loc[0]])
+	}
+	return r
+}
+
+// compile returns the compiled query, which is cached.
+func (m *RegexpMatcher) compile(query string) *regexp.Regexp {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rx != nil && query == m.query {
+		return m.rx
+	}
+	expr := query
+	if strings.ToLower(query) == query {
+		expr = "(?i)" + query
+	}
+	m.query = query
+	m.rx, _ = regexp.Compile(expr)
+	return m.rx
+}
+
+// TokenMatcher splits the query into words and matches strings that
+// match all the words in any order. Each word is matched with Matcher,
+// which defaults to a SubstringMatcher. The score is the average of the
+// words' scores.
+type TokenMatcher struct {
+	Matcher Matcher
+}
+
+// Match implements Matcher.
+func (m TokenMatcher) Match(str, query string) fuzzy.Result {
+	var (
+		r      = fuzzy.Result{Query: query, SortKey: str}
+		tokens = strings.Fields(query)
+		inner  = m.Matcher
+		total  float64
+	)
+	if inner == nil {
+		inner = SubstringMatcher{}
+	}
+	for _, tok := range tokens {
+		v := inner.Match(str, tok)
+		if !v.Match {
+			return r
+		}
+		total += v.Score
+	}
+	r.Match = true
+	if len(tokens) > 0 {
+		r.Score = total / float64(len(tokens))
+	}
+	return r
+}
+
+// foldCase returns s in lowercase unless caseSensitive is true.
+func foldCase(s string, caseSensitive bool) string {
+	if caseSensitive {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+// lengthScore returns the percentage of s matched by match.
+func lengthScore(match, s string) float64 {
+	n := len([]rune(s))
+	if n == 0 {
+		return 100
+	}
+	return 100 * float64(len([]rune(match))) / float64(n)
+}
+
+// positionScore reduces score by the length of the unmatched text
+// before the match.
+func positionScore(score float64, before string) float64 {
+	score -= float64(len([]rune(before)))
+	if score < 0 {
+		score = 0
+	}
+	return score
+}
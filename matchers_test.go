@@ -0,0 +1,115 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.deanishe.net/fuzzy"
+)
+
+// Built-in and fuzzy matchers implement Matcher
+var (
+	_ Matcher = ExactMatcher{}
+	_ Matcher = PrefixMatcher{}
+	_ Matcher = SubstringMatcher{}
+	_ Matcher = InitialsMatcher{}
+	_ Matcher = &RegexpMatcher{}
+	_ Matcher = TokenMatcher{}
+	_ Matcher = fuzzy.New(nil)
+)
+
+func TestMatchers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		m          Matcher
+		str, query string
+		match      bool
+		score      float64
+	}{
+		{ExactMatcher{}, "Safari", "safari", true, 100},
+		{ExactMatcher{}, "Safari", "safar", false, 0},
+
+		{PrefixMatcher{}, "PROJ-123", "proj-12", true, 87.5},
+		{PrefixMatcher{}, "PROJ-123", "PROJ-123", true, 100},
+		{PrefixMatcher{}, "PROJ-123", "", true, 0},
+		{PrefixMatcher{}, "PROJ-123", "roj", false, 0},
+		{PrefixMatcher{CaseSensitive: true}, "PROJ-123", "proj", false, 0},
+
+		{SubstringMatcher{}, "web1.example.com", "web1", true, 25},
+		{SubstringMatcher{}, "web1.example.com", "example", true, 38.75},
+		{SubstringMatcher{}, "web1.example.com", "exampel", false, 0},
+		{SubstringMatcher{CaseSensitive: true}, "web1.example.com", "Example", false, 0},
+
+		{InitialsMatcher{}, "Game of Thrones", "got", true, 100},
+		{InitialsMatcher{}, "Game of Thrones", "gt", true, 200.0 / 3},
+		{InitialsMatcher{}, "Game of Thrones", "ot", true, 100.0 / 3},
+		{InitialsMatcher{}, "Game of Thrones", "tg", false, 0},
+		{InitialsMatcher{}, "OmniFocus", "of", true, 100},
+		{InitialsMatcher{}, "awgo-fuzzy_sort", "afs", true, 100},
+
+		{&RegexpMatcher{}, "web12.example.com", `web\d+`, true, 100.0 * 5 / 17},
+		{&RegexpMatcher{}, "WEB12.example.com", `web\d+`, true, 100.0 * 5 / 17},
+		{&RegexpMatcher{}, "WEB12.example.com", `Web\d+`, false, 0},
+		{&RegexpMatcher{}, "web12.example.com", `example\.com$`, true, 100.0*11/17 - 6},
+		{&RegexpMatcher{}, "web12.example.com", `web(`, false, 0},
+
+		{TokenMatcher{}, "web1.example.com", "example web", true, (38.75 + 18.75) / 2},
+		{TokenMatcher{}, "web1.example.com", "example web2", false, 0},
+		{TokenMatcher{Matcher: PrefixMatcher{}}, "web1.example.com", "web example", false, 0},
+		{TokenMatcher{}, "web1.example.com", "", true, 0},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%T(%q, %q)", td.m, td.str, td.query), func(t *testing.T) {
+			t.Parallel()
+			r := td.m.Match(td.str, td.query)
+			assert.Equal(t, td.match, r.Match, "unexpected match")
+			assert.InDelta(t, td.score, r.Score, 0.001, "unexpected score")
+			assert.Equal(t, td.query, r.Query, "unexpected query")
+			assert.Equal(t, td.str, r.SortKey, "unexpected sort key")
+		})
+	}
+}
+
+// Filter uses Feedback.Matcher.
+func TestFeedback_FilterMatcher(t *testing.T) {
+	t.Parallel()
+
+	newFeedback := func(m Matcher) *Feedback {
+		fb := NewFeedback()
+		fb.Matcher = m
+		fb.NewItem("PROJ-1234")
+		fb.NewItem("PROJ-12")
+		fb.NewItem("OTHER-12").Keywords("proj")
+		fb.NewItem("Project Plan")
+		return fb
+	}
+
+	fb := newFeedback(nil)
+	fb.Filter("proj12")
+	assert.Equal(t, []string{"PROJ-12", "PROJ-1234"}, feedbackOrder(fb), "unexpected fuzzy results")
+
+	fb = newFeedback(PrefixMatcher{})
+	fb.Filter("proj-12")
+	assert.Equal(t, []string{"PROJ-12", "PROJ-1234"}, feedbackOrder(fb), "unexpected prefix results")
+
+	fb = newFeedback(PrefixMatcher{})
+	fb.Filter("proj12")
+	assert.Empty(t, feedbackOrder(fb), "unexpected prefix results")
+
+	fb = newFeedback(InitialsMatcher{})
+	fb.Filter("pp")
+	assert.Equal(t, []string{"Project Plan"}, feedbackOrder(fb), "unexpected initials results")
+
+	// Matcher is used for all fields
+	fb = newFeedback(PrefixMatcher{})
+	fb.Weights = &FieldWeights{Title: 1, Keywords: 0.5}
+	fb.Filter("proj")
+	assert.Equal(t, []string{"PROJ-12", "OTHER-12", "PROJ-1234", "Project Plan"}, feedbackOrder(fb), "unexpected weighted results")
+}
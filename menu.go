@@ -0,0 +1,262 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MenuSeparator separates the segments of a menu path, and the path from
+// the user's query, e.g. "projects/awgo/ search terms".
+const MenuSeparator = "/"
+
+// EnvVarMenuPath is the workflow variable Menu sets to the path of the
+// current menu. Link Items set it to the path they point to, so actions
+// connected to the Script Filter can tell where the user was.
+const EnvVarMenuPath = "AW_MENU_PATH"
+
+// matches MenuSeparator followed by whitespace, which starts the user's query
+var rxMenuQueryStart = regexp.MustCompile(regexp.QuoteMeta(MenuSeparator) + `\s`)
+
+// MenuFunc generates the Items for a menu.
+type MenuFunc func(r *MenuRequest) error
+
+// Menu implements drill-down menus, e.g. project → environment → action,
+// in a single Script Filter. Each menu has a path, such as "projects" or
+// "projects/awgo/envs", which is carried in the query: Items created with
+// MenuRequest.Link autocomplete to the path of a child menu, and the text
+// after the path is the user's query.
+//
+// Register a MenuFunc for each path with Handle. Segments of a path pattern
+// starting with ":" match any value, which is available via
+// MenuRequest.Param:
+//
+//	m := aw.NewMenu(wf)
+//	m.Handle("", showProjects)
+//	m.Handle("projects/:project", showEnvironments)
+//	m.Handle("projects/:project/:env", showActions)
+//	wf.Run(func() {
+//		if err := m.Run(); err != nil {
+//			wf.FatalError(err)
+//		}
+//	})
+//
+// Menu adds a "Back" Item, which opens the nearest ancestor menu with a
+// MenuFunc, to all menus except the top-level one.
+type Menu struct {
+	BackTitle string // Title of "Back" Item. Default: "Back"
+
+	wf     *Workflow
+	routes []menuRoute
+}
+
+type menuRoute struct {
+	pattern []string
+	fn      MenuFunc
+}
+
+// NewMenu creates a Menu for Workflow.
+func NewMenu(wf *Workflow) *Menu {
+	return &Menu{BackTitle: "Back", wf: wf}
+}
+
+// Handle registers fn for paths matching pattern. Use "" for the
+// top-level menu. If several patterns match a path, the first one
+// registered is used.
+func (m *Menu) Handle(pattern string, fn MenuFunc) *Menu {
+	m.routes = append(m.routes, menuRoute{splitMenuPath(pattern), fn})
+	return m
+}
+
+// Run calls Dispatch with the first of Workflow.Args and sends feedback
+// to Alfred.
+func (m *Menu) Run() error {
+	var query string
+	if args := m.wf.Args(); len(args) > 0 {
+		query = args[0]
+	}
+	if err := m.Dispatch(query); err != nil {
+		return err
+	}
+	m.wf.SendFeedback()
+	return nil
+}
+
+// Dispatch splits query into a menu path and the user's query and calls
+// the MenuFunc registered for the path. The path is the longest prefix of
+// query that has a MenuFunc, and the rest is the user's query, so queries
+// that contain MenuSeparator (e.g. URLs) still work. Text after a
+// MenuSeparator followed by whitespace is always part of the user's query.
+// If no prefix matches, the whole of query is passed to the top-level menu.
+//
+// Dispatch doesn't send feedback. It returns an error if no MenuFunc
+// matches or the MenuFunc fails.
+func (m *Menu) Dispatch(query string) error {
+	var (
+		r     *MenuRequest
+		ok    bool
+		path  string
+		q     string
+		paths = splitMenuQuery(query)
+	)
+	for _, p := range paths {
+		if r, ok = m.match(p.path); ok {
+			path, q = p.path, p.query
+			break
+		}
+	}
+	if !ok {
+		return fmt.Errorf("no menu for path %q", paths[0].path)
+	}
+	r.Path, r.Query = path, q
+
+	m.wf.Var(EnvVarMenuPath, path)
+	if err := r.fn(r); err != nil {
+		return fmt.Errorf("menu %q: %w", path, err)
+	}
+
+	if path != "" {
+		parent := m.parent(path)
+		sub := parent
+		if sub == "" {
+			sub = "Top-level menu"
+		}
+		back := m.wf.NewItem(m.BackTitle).
+			Subtitle(sub).
+			Autocomplete(menuAutocomplete(parent)).
+			Var(EnvVarMenuPath, parent).
+			Valid(false)
+		items := m.wf.Feedback.Items
+		copy(items[1:], items[:len(items)-1])
+		items[0] = back
+	}
+	return nil
+}
+
+// parent returns the path of the nearest ancestor of path that has a
+// MenuFunc, or "" for the top-level menu.
+func (m *Menu) parent(path string) string {
+	for path != "" {
+		path = parentMenuPath(path)
+		if _, ok := m.match(path); ok {
+			break
+		}
+	}
+	return path
+}
+
+// match returns a MenuRequest for the first route that matches path.
+func (m *Menu) match(path string) (*MenuRequest, bool) {
+	segments := splitMenuPath(path)
+	for _, rt := range m.routes {
+		if params, ok := matchMenuPath(rt.pattern, segments); ok {
+			return &MenuRequest{Params: params, fn: rt.fn, wf: m.wf}, true
+		}
+	}
+	return nil, false
+}
+
+// MenuRequest is passed to a MenuFunc.
+type MenuRequest struct {
+	Path   string            // Path of the current menu, e.g. "projects/awgo"
+	Query  string            // User's query without the path
+	Params map[string]string // Values of the ":name" segments of the pattern
+
+	fn MenuFunc
+	wf *Workflow
+}
+
+// Param returns the value of the ":name" segment of the current path.
+func (r *MenuRequest) Param(name string) string { return r.Params[name] }
+
+// Link adds an Item that opens the menu at path. path is relative to the
+// current menu unless it starts with MenuSeparator. The Item is not valid,
+// so actioning it autocompletes the query to path.
+func (r *MenuRequest) Link(title, path string) *Item {
+	if strings.HasPrefix(path, MenuSeparator) {
+		path = strings.Join(splitMenuPath(path), MenuSeparator)
+	} else {
+		path = strings.Join(append(splitMenuPath(r.Path), splitMenuPath(path)...), MenuSeparator)
+	}
+	return r.wf.NewItem(title).
+		Autocomplete(menuAutocomplete(path)).
+		Var(EnvVarMenuPath, path).
+		Valid(false)
+}
+
+// menuQuery is a menu path and the user's query.
+type menuQuery struct {
+	path, query string
+}
+
+// splitMenuQuery returns the ways query can be split at a MenuSeparator
+// into the path of a menu and the user's query, longest path first. The
+// last split is the top-level menu and the whole of query. Paths end at
+// the first MenuSeparator followed by whitespace.
+func splitMenuQuery(query string) []menuQuery {
+	var (
+		splits []menuQuery
+		end    = len(query)
+	)
+	if m := rxMenuQueryStart.FindStringIndex(query); m != nil {
+		end = m[0] + len(MenuSeparator)
+	}
+	for {
+		i := strings.LastIndex(query[:end], MenuSeparator)
+		if i < 0 {
+			break
+		}
+		path := strings.Join(splitMenuPath(query[:i]), MenuSeparator)
+		splits = append(splits, menuQuery{path, strings.TrimSpace(query[i+len(MenuSeparator):])})
+		end = i
+	}
+	return append(splits, menuQuery{"", strings.TrimSpace(query)})
+}
+
+// splitMenuPath returns the non-empty segments of path.
+func splitMenuPath(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, MenuSeparator) {
+		if s = strings.TrimSpace(s); s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// matchMenuPath matches path segments against pattern segments, returning
+// the values of ":name" segments.
+func matchMenuPath(pattern, path []string) (map[string]string, bool) {
+	if len(pattern) != len(path) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, p := range pattern {
+		if strings.HasPrefix(p, ":") {
+			params[p[1:]] = path[i]
+		} else if p != path[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// parentMenuPath returns the path of the parent of menu path.
+func parentMenuPath(path string) string {
+	segments := splitMenuPath(path)
+	if len(segments) < 2 {
+		return ""
+	}
+	return strings.Join(segments[:len(segments)-1], MenuSeparator)
+}
+
+// menuAutocomplete returns the query that opens the menu at path.
+func menuAutocomplete(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + MenuSeparator
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitMenuQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in string
+		x  []menuQuery
+	}{
+		{"", []menuQuery{{"", ""}}},
+		{"foo bar", []menuQuery{{"", "foo bar"}}},
+		{"projects/", []menuQuery{{"projects", ""}, {"", "projects/"}}},
+		{"projects/ aw", []menuQuery{{"projects", "aw"}, {"", "projects/ aw"}}},
+		{"projects/awgo/prod/dep", []menuQuery{
+			{"projects/awgo/prod", "dep"},
+			{"projects/awgo", "prod/dep"},
+			{"projects", "awgo/prod/dep"},
+			{"", "projects/awgo/prod/dep"},
+		}},
+		{"/projects//awgo/ ", []menuQuery{
+			{"projects/awgo", ""},
+			{"projects", "awgo/"},
+			{"projects", "/awgo/"},
+			{"", "projects//awgo/"},
+			{"", "/projects//awgo/"},
+		}},
+		// path ends at separator followed by whitespace
+		{"projects/awgo/ a/b", []menuQuery{
+			{"projects/awgo", "a/b"},
+			{"projects", "awgo/ a/b"},
+			{"", "projects/awgo/ a/b"},
+		}},
+	}
+
+	for _, td := range tests {
+		assert.Equal(t, td.x, splitMenuQuery(td.in), "unexpected splits for %q", td.in)
+	}
+}
+
+// testMenu creates a three-level Menu and records the requests it handles.
+func testMenu(wf *Workflow, reqs *[]*MenuRequest) *Menu {
+	m := NewMenu(wf)
+	m.Handle("", func(r *MenuRequest) error {
+		*reqs = append(*reqs, r)
+		r.Link("awgo", "projects/awgo")
+		r.Link("fuzzy", "projects/fuzzy")
+		wf.Filter(r.Query)
+		return nil
+	})
+	m.Handle("projects/:project", func(r *MenuRequest) error {
+		*reqs = append(*reqs, r)
+		r.Link("Production", "prod")
+		r.Link("Staging", "staging")
+		return nil
+	})
+	m.Handle("projects/:project/:env", func(r *MenuRequest) error {
+		*reqs = append(*reqs, r)
+		if r.Param("env") == "broken" {
+			return errors.New("broken")
+		}
+		wf.NewItem("Deploy " + r.Param("project") + " to " + r.Param("env")).Arg("deploy")
+		r.Link("All projects", "/")
+		return nil
+	})
+	return m
+}
+
+func TestMenu_Dispatch(t *testing.T) {
+	tests := []struct {
+		query  string
+		path   string
+		q      string
+		params map[string]string
+		titles []string
+		ac     []string // autocomplete of Items
+	}{
+		{"", "", "", map[string]string{},
+			[]string{"awgo", "fuzzy"},
+			[]string{"projects/awgo/", "projects/fuzzy/"}},
+		{"fuz", "", "fuz", map[string]string{},
+			[]string{"fuzzy"},
+			[]string{"projects/fuzzy/"}},
+		{"projects/awgo/ st", "projects/awgo", "st", map[string]string{"project": "awgo"},
+			[]string{"Back", "Production", "Staging"},
+			[]string{"", "projects/awgo/prod/", "projects/awgo/staging/"}},
+		{"projects/awgo/prod/", "projects/awgo/prod", "", map[string]string{"project": "awgo", "env": "prod"},
+			[]string{"Back", "Deploy awgo to prod", "All projects"},
+			[]string{"projects/awgo/", "", ""}},
+		// unknown path is passed to top-level menu
+		{"http://", "", "http://", map[string]string{},
+			nil, nil},
+		// queries containing separator stay in their menu
+		{"projects/awgo/ a/b", "projects/awgo", "a/b", map[string]string{"project": "awgo"},
+			[]string{"Back", "Production", "Staging"},
+			[]string{"", "projects/awgo/prod/", "projects/awgo/staging/"}},
+		{"projects/awgo/ http://x", "projects/awgo", "http://x", map[string]string{"project": "awgo"},
+			[]string{"Back", "Production", "Staging"},
+			[]string{"", "projects/awgo/prod/", "projects/awgo/staging/"}},
+		{"projects/awgo/prod/ a/b/c", "projects/awgo/prod", "a/b/c", map[string]string{"project": "awgo", "env": "prod"},
+			[]string{"Back", "Deploy awgo to prod", "All projects"},
+			[]string{"projects/awgo/", "", ""}},
+		{"fuzzy/ http://x", "", "fuzzy/ http://x", map[string]string{},
+			nil, nil},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%q", td.query), func(t *testing.T) {
+			withTestWf(func(wf *Workflow) {
+				var reqs []*MenuRequest
+				require.Nil(t, testMenu(wf, &reqs).Dispatch(td.query), "dispatch failed")
+				require.Equal(t, 1, len(reqs), "unexpected requests")
+				r := reqs[0]
+				assert.Equal(t, td.path, r.Path, "unexpected path")
+				assert.Equal(t, td.q, r.Query, "unexpected query")
+				assert.Equal(t, td.params, r.Params, "unexpected params")
+				assert.Equal(t, td.path, wf.Feedback.Vars()[EnvVarMenuPath], "unexpected path variable")
+
+				var titles, ac []string
+				for _, it := range wf.Feedback.Items {
+					titles = append(titles, it.title)
+					if it.autocomplete != nil {
+						ac = append(ac, *it.autocomplete)
+					} else {
+						ac = append(ac, "")
+					}
+				}
+				assert.Equal(t, td.titles, titles, "unexpected titles")
+				assert.Equal(t, td.ac, ac, "unexpected autocompletes")
+			})
+		})
+	}
+}
+
+func TestMenu_Items(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		var reqs []*MenuRequest
+		require.Nil(t, testMenu(wf, &reqs).Dispatch("projects/awgo/"), "dispatch failed")
+		require.Equal(t, 3, len(wf.Feedback.Items), "unexpected items")
+
+		back := wf.Feedback.Items[0]
+		assert.False(t, back.valid, "back is valid")
+		assert.Equal(t, "Top-level menu", *back.subtitle, "unexpected back subtitle")
+		assert.Equal(t, "", back.Vars()[EnvVarMenuPath], "unexpected back path")
+
+		link := wf.Feedback.Items[1]
+		assert.False(t, link.valid, "link is valid")
+		assert.Equal(t, "projects/awgo/prod", link.Vars()[EnvVarMenuPath], "unexpected link path")
+	})
+}
+
+func TestMenu_Errors(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		var reqs []*MenuRequest
+		err := testMenu(wf, &reqs).Dispatch("projects/awgo/broken/")
+		assert.NotNil(t, err, "handler error not returned")
+
+		err = NewMenu(wf).Handle("projects", func(r *MenuRequest) error { return nil }).Dispatch("other/")
+		assert.NotNil(t, err, "missing handler accepted")
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package query
+
+import (
+	"strings"
+
+	aw "github.com/ChicK00o/awgo"
+)
+
+// Key is a filter key known to a Parser.
+type Key struct {
+	Name        string   // What the user types before ":"
+	Description string   // Shown as subtitle of suggestions
+	Values      []string // Suggested values
+}
+
+// Parser parses queries that only contain known filter keys. Terms that
+// look like filters but have unknown keys, e.g. URLs, are free text.
+type Parser struct {
+	keys  map[string]*Key
+	order []*Key
+}
+
+// NewParser creates a Parser with no known keys.
+func NewParser() *Parser { return &Parser{keys: map[string]*Key{}} }
+
+// Key registers a known filter key. values are suggested by Suggest
+// when the user types "name:". Keys are case-insensitive.
+func (p *Parser) Key(name, description string, values ...string) *Parser {
+	k := &Key{Name: strings.ToLower(name), Description: description, Values: values}
+	if _, ok := p.keys[k.Name]; !ok {
+		p.order = append(p.order, k)
+	} else {
+		for i, o := range p.order {
+			if o.Name == k.Name {
+				p.order[i] = k
+			}
+		}
+	}
+	p.keys[k.Name] = k
+	return p
+}
+
+// Keys returns the known keys in the order they were registered.
+func (p *Parser) Keys() []*Key { return p.order }
+
+// Parse parses a query, only treating known keys as filters.
+func (p *Parser) Parse(s string) *Query { return parse(s, p.keys) }
+
+// Suggest adds Items to fb that complete the last term of q, and returns
+// the number of Items added. Actioning an Item autocompletes the query.
+//
+// If the last term is the start of a known key, the matching keys are
+// suggested. If it is a filter whose value is incomplete, the key's
+// values that start with it are suggested. Nothing is suggested if the
+// query ends with a space (i.e. the last term is complete).
+func (p *Parser) Suggest(fb *aw.Feedback, q *Query) int {
+	if len(q.Tokens) == 0 || strings.HasSuffix(q.Raw, " ") {
+		return 0
+	}
+	var (
+		t      = q.Tokens[len(q.Tokens)-1]
+		prefix = q.Raw[:t.Start]
+		neg    string
+		n      int
+	)
+	if t.Negated {
+		neg = "-"
+	}
+
+	switch t.Type {
+	case TypeText:
+		if t.Quoted {
+			return 0
+		}
+		for _, k := range p.order {
+			if !strings.HasPrefix(k.Name, strings.ToLower(t.Value)) {
+				continue
+			}
+			it := fb.NewItem(k.Name + ":").
+				Autocomplete(prefix + neg + k.Name + ":").
+				Valid(false)
+			if k.Description != "" {
+				it.Subtitle(k.Description)
+			}
+			n++
+		}
+
+	case TypeFilter:
+		k := p.keys[t.Key]
+		if k == nil {
+			return 0
+		}
+		for _, v := range k.Values {
+			if v == t.Value || !strings.HasPrefix(strings.ToLower(v), strings.ToLower(t.Value)) {
+				continue
+			}
+			s := v
+			if strings.ContainsAny(s, " \t") {
+				s = `"` + s + `"`
+			}
+			fb.NewItem(k.Name + ":" + v).
+				Subtitle(k.Description).
+				Autocomplete(prefix + neg + k.Name + ":" + s + " ").
+				Valid(false)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,332 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+/*
+Package query parses structured Script Filter queries.
+
+A query is split on whitespace into free text, "key:value" filters,
+"#tags" and "-exclusions". Double quotes group words into a single term,
+e.g. `"new york"` or `city:"new york"`, and a leading "-" negates a term
+or filter, e.g. "-draft" or "-status:closed".
+
+	q := query.Parse(`report status:open #work -draft "q3 results"`)
+	q.Text()          // "report q3 results"
+	q.Get("status")   // "open"
+	q.HasTag("work")  // true
+	q.Excluded()      // []string{"draft"}
+
+Use a Parser to only accept known keys (so that, e.g. URLs aren't treated
+as filters) and to suggest keys and values via Alfred's autocomplete:
+
+	p := query.NewParser()
+	p.Key("status", "Filter by status", "open", "closed")
+	q := p.Parse(wf.Args()[0])
+	if p.Suggest(wf.Feedback, q) > 0 {
+		wf.SendFeedback()
+		return
+	}
+*/
+package query
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TokenType is the type of a query Token.
+type TokenType int
+
+// Types of Token.
+const (
+	TypeText   TokenType = iota // Free text
+	TypeFilter                  // key:value filter
+	TypeTag                     // #tag
+)
+
+// String implements Stringer.
+func (t TokenType) String() string {
+	switch t {
+	case TypeText:
+		return "text"
+	case TypeFilter:
+		return "filter"
+	case TypeTag:
+		return "tag"
+	default:
+		return "TokenType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// Token is one term of a query.
+type Token struct {
+	Type    TokenType
+	Key     string // Key of a filter
+	Value   string // Text, tag (without "#") or value of a filter (without quotes)
+	Negated bool   // Term started with "-"
+	Quoted  bool   // Value was quoted
+	Start   int    // Byte offset of token in query
+	End     int    // Byte offset of end of token in query
+}
+
+// Query is a parsed query.
+type Query struct {
+	Raw    string  // The original query
+	Tokens []Token // Terms of the query in order
+}
+
+// Parse parses a query, accepting all keys.
+func Parse(s string) *Query { return parse(s, nil) }
+
+// parse parses a query. If keys is not nil, only the keys in it are
+// treated as filters.
+func parse(s string, keys map[string]*Key) *Query {
+	q := &Query{Raw: s}
+	for _, t := range split(s) {
+		q.Tokens = append(q.Tokens, newToken(s, t, keys))
+	}
+	return q
+}
+
+// span is the position of a term in a query.
+type span struct{ start, end int }
+
+// split splits s on whitespace outside double quotes.
+func split(s string) []span {
+	var (
+		spans   []span
+		start   = -1
+		inQuote bool
+	)
+	for i, c := range s {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+			if start < 0 {
+				start = i
+			}
+		case unicode.IsSpace(c) && !inQuote:
+			if start >= 0 {
+				spans = append(spans, span{start, i})
+				start = -1
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(s)})
+	}
+	return spans
+}
+
+// newToken creates a Token from a term of query s.
+func newToken(s string, sp span, keys map[string]*Key) Token {
+	var (
+		t    = Token{Type: TypeText, Start: sp.start, End: sp.end}
+		term = s[sp.start:sp.end]
+	)
+	if len(term) > 1 && term[0] == '-' {
+		t.Negated = true
+		term = term[1:]
+	}
+
+	if len(term) > 1 && term[0] == '#' && !strings.ContainsAny(term, `"`) {
+		t.Type = TypeTag
+		t.Value = term[1:]
+		return t
+	}
+
+	if i := strings.Index(term, ":"); i > 0 && isKey(term[:i]) {
+		key := strings.ToLower(term[:i])
+		if keys == nil || keys[key] != nil {
+			t.Type = TypeFilter
+			t.Key = key
+			t.Value, t.Quoted = unquote(term[i+1:])
+			return t
+		}
+	}
+
+	t.Value, t.Quoted = unquote(term)
+	return t
+}
+
+// isKey returns true if s is a valid filter key.
+func isKey(s string) bool {
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// unquote removes double quotes from s.
+func unquote(s string) (string, bool) {
+	if !strings.Contains(s, `"`) {
+		return s, false
+	}
+	return strings.ReplaceAll(s, `"`, ""), true
+}
+
+// Terms returns the free-text terms of the query, excluding negated ones.
+func (q *Query) Terms() []string {
+	var terms []string
+	for _, t := range q.Tokens {
+		if t.Type == TypeText && !t.Negated {
+			terms = append(terms, t.Value)
+		}
+	}
+	return terms
+}
+
+// Text returns the free-text terms of the query joined with spaces.
+func (q *Query) Text() string { return strings.Join(q.Terms(), " ") }
+
+// Tags returns the query's (non-negated) tags without leading "#".
+func (q *Query) Tags() []string {
+	var tags []string
+	for _, t := range q.Tokens {
+		if t.Type == TypeTag && !t.Negated {
+			tags = append(tags, t.Value)
+		}
+	}
+	return tags
+}
+
+// HasTag returns true if the query contains (non-negated) #tag.
+// Tags are compared case-insensitively.
+func (q *Query) HasTag(tag string) bool {
+	for _, s := range q.Tags() {
+		if strings.EqualFold(s, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Excluded returns the negated free-text terms and tags of the query,
+// e.g. "draft" for "-draft" and "#work" for "-#work".
+func (q *Query) Excluded() []string {
+	var terms []string
+	for _, t := range q.Tokens {
+		if !t.Negated {
+			continue
+		}
+		switch t.Type {
+		case TypeText:
+			terms = append(terms, t.Value)
+		case TypeTag:
+			terms = append(terms, "#"+t.Value)
+		}
+	}
+	return terms
+}
+
+// Has returns true if the query contains a (non-negated) filter for key.
+func (q *Query) Has(key string) bool { return len(q.GetAll(key)) > 0 }
+
+// GetAll returns the values of all (non-negated) filters for key.
+// Keys are case-insensitive.
+func (q *Query) GetAll(key string) []string {
+	return q.values(key, false)
+}
+
+// Not returns the values of all negated filters for key, e.g. "closed"
+// for "-status:closed".
+func (q *Query) Not(key string) []string {
+	return q.values(key, true)
+}
+
+// values returns the non-empty values of filters for key. Filters without
+// a value, e.g. "status:", are still being typed, so they are ignored.
+func (q *Query) values(key string, negated bool) []string {
+	var values []string
+	key = strings.ToLower(key)
+	for _, t := range q.Tokens {
+		if t.Type == TypeFilter && t.Key == key && t.Negated == negated && t.Value != "" {
+			values = append(values, t.Value)
+		}
+	}
+	return values
+}
+
+// Get returns the value of the last filter for key.
+// It accepts one optional "fallback" argument. If there is no filter
+// for key, returns fallback or an empty string.
+func (q *Query) Get(key string, fallback ...string) string {
+	values := q.GetAll(key)
+	if len(values) == 0 {
+		if len(fallback) > 0 {
+			return fallback[0]
+		}
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// GetInt returns the value of filter key as an int.
+// It accepts one optional "fallback" argument. If there is no filter
+// for key or its value isn't a number, returns fallback or 0.
+func (q *Query) GetInt(key string, fallback ...int) int {
+	if n, err := strconv.Atoi(q.Get(key)); err == nil {
+		return n
+	}
+	if len(fallback) > 0 {
+		return fallback[0]
+	}
+	return 0
+}
+
+// GetFloat returns the value of filter key as a float.
+// It accepts one optional "fallback" argument. If there is no filter
+// for key or its value isn't a number, returns fallback or 0.0.
+func (q *Query) GetFloat(key string, fallback ...float64) float64 {
+	if n, err := strconv.ParseFloat(q.Get(key), 64); err == nil {
+		return n
+	}
+	if len(fallback) > 0 {
+		return fallback[0]
+	}
+	return 0
+}
+
+// GetBool returns the value of filter key as a boolean.
+// It accepts one optional "fallback" argument. If there is no filter
+// for key or its value isn't a boolean, returns fallback or false.
+//
+// Values are parsed with strconv.ParseBool(), and "yes", "no", "on"
+// and "off" are also accepted.
+func (q *Query) GetBool(key string, fallback ...bool) bool {
+	switch strings.ToLower(q.Get(key)) {
+	case "yes", "on":
+		return true
+	case "no", "off":
+		return false
+	}
+	if b, err := strconv.ParseBool(q.Get(key)); err == nil {
+		return b
+	}
+	if len(fallback) > 0 {
+		return fallback[0]
+	}
+	return false
+}
+
+// GetDuration returns the value of filter key as a time.Duration.
+// It accepts one optional "fallback" argument. If there is no filter
+// for key or its value isn't a duration, returns fallback or 0.
+//
+// Values are parsed with time.ParseDuration().
+func (q *Query) GetDuration(key string, fallback ...time.Duration) time.Duration {
+	if d, err := time.ParseDuration(q.Get(key)); err == nil {
+		return d
+	}
+	if len(fallback) > 0 {
+		return fallback[0]
+	}
+	return 0
+}
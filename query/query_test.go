@@ -0,0 +1,197 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	aw "github.com/ChicK00o/awgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in string
+		x  []Token
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"foo bar", []Token{
+			{Type: TypeText, Value: "foo", Start: 0, End: 3},
+			{Type: TypeText, Value: "bar", Start: 4, End: 7},
+		}},
+		{`"new york" city`, []Token{
+			{Type: TypeText, Value: "new york", Quoted: true, Start: 0, End: 10},
+			{Type: TypeText, Value: "city", Start: 11, End: 15},
+		}},
+		{`City:"new york"`, []Token{
+			{Type: TypeFilter, Key: "city", Value: "new york", Quoted: true, Start: 0, End: 15},
+		}},
+		{"#work -#home", []Token{
+			{Type: TypeTag, Value: "work", Start: 0, End: 5},
+			{Type: TypeTag, Value: "home", Negated: true, Start: 6, End: 12},
+		}},
+		{`-draft -"old stuff" -status:closed`, []Token{
+			{Type: TypeText, Value: "draft", Negated: true, Start: 0, End: 6},
+			{Type: TypeText, Value: "old stuff", Negated: true, Quoted: true, Start: 7, End: 19},
+			{Type: TypeFilter, Key: "status", Value: "closed", Negated: true, Start: 20, End: 34},
+		}},
+		// incomplete terms
+		{`- # status: "unterminated quote`, []Token{
+			{Type: TypeText, Value: "-", Start: 0, End: 1},
+			{Type: TypeText, Value: "#", Start: 2, End: 3},
+			{Type: TypeFilter, Key: "status", Start: 4, End: 11},
+			{Type: TypeText, Value: "unterminated quote", Quoted: true, Start: 12, End: 31},
+		}},
+		// not filters
+		{":foo a/b:c", []Token{
+			{Type: TypeText, Value: ":foo", Start: 0, End: 4},
+			{Type: TypeText, Value: "a/b:c", Start: 5, End: 10},
+		}},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.in, func(t *testing.T) {
+			t.Parallel()
+			q := Parse(td.in)
+			assert.Equal(t, td.in, q.Raw, "unexpected Raw")
+			assert.Equal(t, td.x, q.Tokens, "unexpected tokens")
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	q := Parse(`report status:open #work -draft "q3 results" -status:closed Status:new -#home tag:`)
+	assert.Equal(t, []string{"report", "q3 results"}, q.Terms(), "unexpected terms")
+	assert.Equal(t, "report q3 results", q.Text(), "unexpected text")
+	assert.Equal(t, []string{"work"}, q.Tags(), "unexpected tags")
+	assert.True(t, q.HasTag("Work"), "tag not found")
+	assert.False(t, q.HasTag("home"), "negated tag found")
+	assert.Equal(t, []string{"draft", "#home"}, q.Excluded(), "unexpected exclusions")
+
+	assert.True(t, q.Has("STATUS"), "filter not found")
+	assert.False(t, q.Has("tag"), "empty filter found")
+	assert.Equal(t, []string{"open", "new"}, q.GetAll("status"), "unexpected values")
+	assert.Equal(t, "new", q.Get("status"), "unexpected value")
+	assert.Equal(t, "x", q.Get("other", "x"), "unexpected fallback")
+	assert.Equal(t, []string{"closed"}, q.Not("status"), "unexpected negated values")
+}
+
+func TestQuery_Typed(t *testing.T) {
+	t.Parallel()
+
+	q := Parse("n:12 f:2.5 b:yes b2:0 d:90m bad:x")
+
+	assert.Equal(t, 12, q.GetInt("n"), "unexpected int")
+	assert.Equal(t, 5, q.GetInt("bad", 5), "unexpected int fallback")
+	assert.Equal(t, 0, q.GetInt("missing"), "unexpected int default")
+
+	assert.Equal(t, 2.5, q.GetFloat("f"), "unexpected float")
+	assert.Equal(t, 12.0, q.GetFloat("n"), "unexpected float")
+	assert.Equal(t, 1.5, q.GetFloat("bad", 1.5), "unexpected float fallback")
+
+	assert.True(t, q.GetBool("b"), "unexpected bool")
+	assert.False(t, q.GetBool("b2", true), "unexpected bool")
+	assert.True(t, q.GetBool("bad", true), "unexpected bool fallback")
+	assert.False(t, q.GetBool("missing"), "unexpected bool default")
+
+	assert.Equal(t, 90*time.Minute, q.GetDuration("d"), "unexpected duration")
+	assert.Equal(t, time.Second, q.GetDuration("bad", time.Second), "unexpected duration fallback")
+}
+
+func TestParser(t *testing.T) {
+	t.Parallel()
+
+	p := NewParser().
+		Key("status", "Filter by status", "open", "closed").
+		Key("Owner", "Filter by owner")
+
+	q := p.Parse("see https://example.com status:open owner:me from:bob")
+	assert.Equal(t, "see https://example.com from:bob", q.Text(), "unexpected text")
+	assert.Equal(t, "open", q.Get("status"), "unexpected status")
+	assert.Equal(t, "me", q.Get("owner"), "unexpected owner")
+	assert.False(t, q.Has("from"), "unknown key parsed")
+
+	// re-registering replaces key
+	p.Key("status", "Status", "new")
+	require.Equal(t, 2, len(p.Keys()), "unexpected key count")
+	assert.Equal(t, "Status", p.Keys()[0].Description, "key not replaced")
+}
+
+type suggestion struct {
+	Title        string `json:"title"`
+	Subtitle     string `json:"subtitle"`
+	Autocomplete string `json:"autocomplete"`
+	Valid        bool   `json:"valid"`
+}
+
+// suggestions returns the Items added to fb.
+func suggestions(t *testing.T, fb *aw.Feedback) []suggestion {
+	data, err := json.Marshal(fb)
+	require.Nil(t, err, "marshal feedback")
+	var v struct{ Items []suggestion }
+	require.Nil(t, json.Unmarshal(data, &v), "unmarshal feedback")
+	if len(v.Items) == 0 {
+		return nil
+	}
+	return v.Items
+}
+
+func TestParser_Suggest(t *testing.T) {
+	t.Parallel()
+
+	p := NewParser().
+		Key("status", "Filter by status", "open", "closed", "on hold").
+		Key("size", "Filter by size").
+		Key("owner", "")
+
+	tests := []struct {
+		in string
+		x  []suggestion
+	}{
+		{"", nil},
+		{"foo ", nil},
+		{"x", nil},
+		{`"s`, nil},
+		{"foo s", []suggestion{
+			{"status:", "Filter by status", "foo status:", false},
+			{"size:", "Filter by size", "foo size:", false},
+		}},
+		{"foo -ST", []suggestion{
+			{"status:", "Filter by status", "foo -status:", false},
+		}},
+		{"status:", []suggestion{
+			{"status:open", "Filter by status", "status:open ", false},
+			{"status:closed", "Filter by status", "status:closed ", false},
+			{"status:on hold", "Filter by status", `status:"on hold" `, false},
+		}},
+		{"a  status:O", []suggestion{
+			{"status:open", "Filter by status", "a  status:open ", false},
+			{"status:on hold", "Filter by status", `a  status:"on hold" `, false},
+		}},
+		// complete
+		{"status:open", nil},
+		{"size:", nil},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%q", td.in), func(t *testing.T) {
+			t.Parallel()
+			fb := aw.NewFeedback()
+			n := p.Suggest(fb, p.Parse(td.in))
+			assert.Equal(t, len(td.x), n, "unexpected count")
+			assert.Equal(t, td.x, suggestions(t, fb), "unexpected suggestions")
+		})
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.deanishe.net/env"
+)
+
+// Workflow variables set by the Items of a Settings Script Filter.
+// Connect an action to the Script Filter that calls Settings.Save with
+// their values.
+const (
+	EnvVarSettingName  = "AW_SETTING"       // Variable name of setting
+	EnvVarSettingValue = "AW_SETTING_VALUE" // New value of setting
+)
+
+// Setting is one field of a Settings struct.
+type Setting struct {
+	Name        string   // Name of workflow variable
+	Title       string   // Shown in Alfred
+	Description string   // Shown in Alfred
+	Values      []string // Allowed values. Empty means any value.
+	Default     string   // Value if workflow variable isn't set
+	Type        string   // "string", "int", "uint", "float", "bool" or "duration"
+
+	index int // Index of field in struct
+}
+
+// Settings is a typed schema for workflow settings, declared as a tagged
+// struct. Fields are loaded from workflow variables with Config.To and
+// saved with Config.From, so the "env" tag sets the variable name. The
+// following tags are also understood:
+//
+//	title   - name shown in Alfred (default: field name)
+//	desc    - description shown in Alfred
+//	values  - comma-separated list of allowed values
+//	default - value of field if workflow variable isn't set
+//
+// For example:
+//
+//	type Prefs struct {
+//		Hostname string        `env:"HOST" desc:"Server hostname" default:"localhost"`
+//		Port     int           `desc:"Server port" default:"6000"`
+//		Mode     string        `desc:"Sync mode" values:"fast,safe" default:"safe"`
+//		Interval time.Duration `title:"Update interval" default:"5m"`
+//	}
+//
+// Supported field types are strings, bools, numbers and time.Duration.
+//
+// Settings.Run implements a Script Filter that lists the settings and lets
+// the user edit them. Its Items set the EnvVarSettingName and
+// EnvVarSettingValue variables, which an action connected to the Script
+// Filter should pass to Settings.Save:
+//
+//	prefs := &Prefs{}
+//	s, err := aw.NewSettings(wf, prefs)
+//	if err != nil {
+//		wf.FatalError(err)
+//	}
+//	if name := wf.Config.Get(aw.EnvVarSettingName); name != "" {
+//		if err := s.Save(name, wf.Config.Get(aw.EnvVarSettingValue)); err != nil {
+//			wf.FatalError(err)
+//		}
+//		return
+//	}
+//	s.Run(wf.Args()[0])
+type Settings struct {
+	wf       *Workflow
+	v        reflect.Value // struct
+	settings []*Setting
+}
+
+// NewSettings parses the schema of struct v, which must be a pointer, and
+// populates v with default values and values from the workflow's
+// variables.
+func NewSettings(wf *Workflow, v interface{}) (*Settings, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("not a pointer to a struct")
+	}
+	s := &Settings{wf: wf, v: rv.Elem()}
+
+	rt := s.v.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		name := strings.Split(sf.Tag.Get("env"), ",")[0]
+		if sf.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = env.EnvVarForField(sf.Name)
+		}
+		typ := settingType(sf.Type)
+		if typ == "" {
+			return nil, fmt.Errorf("setting %s: unsupported type %s", sf.Name, sf.Type)
+		}
+		st := &Setting{
+			Name:        name,
+			Title:       sf.Tag.Get("title"),
+			Description: sf.Tag.Get("desc"),
+			Default:     sf.Tag.Get("default"),
+			Type:        typ,
+			index:       i,
+		}
+		if st.Title == "" {
+			st.Title = fieldTitle(sf.Name)
+		}
+		if tag := sf.Tag.Get("values"); tag != "" {
+			for _, val := range strings.Split(tag, ",") {
+				st.Values = append(st.Values, strings.TrimSpace(val))
+			}
+		}
+		if st.Default != "" {
+			if err := s.set(st, st.Default); err != nil {
+				return nil, fmt.Errorf("setting %s: default: %w", sf.Name, err)
+			}
+		}
+		s.settings = append(s.settings, st)
+	}
+
+	if err := wf.Config.To(v); err != nil {
+		return nil, fmt.Errorf("load settings: %w", err)
+	}
+	return s, nil
+}
+
+// Settings returns the settings in the order of the struct's fields.
+func (s *Settings) Settings() []*Setting { return s.settings }
+
+// Setting returns the setting for workflow variable name. Names are
+// case-insensitive. It returns nil if there is no such setting.
+func (s *Settings) Setting(name string) *Setting {
+	for _, st := range s.settings {
+		if strings.EqualFold(st.Name, name) {
+			return st
+		}
+	}
+	return nil
+}
+
+// Value returns the current value of setting name as a string.
+func (s *Settings) Value(name string) string {
+	st := s.Setting(name)
+	if st == nil {
+		return ""
+	}
+	return fmt.Sprint(s.v.Field(st.index).Interface())
+}
+
+// Validate returns an error if value isn't valid for setting name.
+func (s *Settings) Validate(name, value string) error {
+	st := s.Setting(name)
+	if st == nil {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	return s.validate(st, value)
+}
+
+func (s *Settings) validate(st *Setting, value string) error {
+	_, err := s.parse(st, value)
+	return err
+}
+
+// parse checks value against setting's allowed values and converts it to
+// the type of setting's field.
+func (s *Settings) parse(st *Setting, value string) (reflect.Value, error) {
+	if len(st.Values) > 0 {
+		var ok bool
+		for _, v := range st.Values {
+			if v == value {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s must be one of: %s", st.Title, strings.Join(st.Values, ", "))
+		}
+	}
+	// bind to a copy of the struct to use the same conversion as Config.To
+	tmp := reflect.New(s.v.Type())
+	if err := env.Bind(tmp.Interface(), env.MapEnv{st.Name: value}); err != nil {
+		return reflect.Value{}, fmt.Errorf("%s must be a %s", st.Title, st.Type)
+	}
+	return tmp.Elem().Field(st.index), nil
+}
+
+// set sets setting's field to value.
+func (s *Settings) set(st *Setting, value string) error {
+	v, err := s.parse(st, value)
+	if err != nil {
+		return err
+	}
+	s.v.Field(st.index).Set(v)
+	return nil
+}
+
+// Save sets setting name to value, and saves all settings to the
+// workflow's configuration with Config.From.
+func (s *Settings) Save(name, value string) error {
+	st := s.Setting(name)
+	if st == nil {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	if err := s.set(st, value); err != nil {
+		return err
+	}
+	return s.wf.Config.From(s.v.Addr().Interface())
+}
+
+// Run generates Script Filter Items for query and sends them to Alfred.
+//
+// If query is empty or doesn't start with the name of a setting, Run lists
+// the settings filtered by query. Actioning one autocompletes its name.
+// If query is a setting's name followed by a space, Run shows the setting's
+// allowed values, and if it is followed by a value, Run shows an Item to
+// save the value or a warning if the value is invalid.
+func (s *Settings) Run(query string) {
+	var (
+		st    *Setting
+		value string
+	)
+	if i := strings.Index(query, " "); i > 0 {
+		st, value = s.Setting(query[:i]), strings.TrimSpace(query[i+1:])
+	}
+
+	if st == nil {
+		s.list(query)
+	} else {
+		s.edit(st, value)
+	}
+	s.wf.SendFeedback()
+}
+
+// list adds an Item for each setting.
+func (s *Settings) list(query string) {
+	for _, st := range s.settings {
+		sub := st.Description
+		if sub == "" {
+			sub = "↩ to edit"
+		}
+		s.wf.NewItem(fmt.Sprintf("%s: %s", st.Title, s.Value(st.Name))).
+			Subtitle(sub).
+			Autocomplete(st.Name + " ").
+			Match(st.Title + " " + st.Name).
+			Icon(IconSettings).
+			Valid(false)
+	}
+	if query = strings.TrimSpace(query); query != "" {
+		s.wf.Filter(query)
+	}
+	s.wf.WarnEmpty("No Matching Settings", "Try a different query?")
+}
+
+// edit adds Items to change a setting to value.
+func (s *Settings) edit(st *Setting, value string) {
+	values := st.Values
+	if len(values) == 0 && st.Type == "bool" {
+		values = []string{"true", "false"}
+	}
+
+	if value == "" {
+		s.wf.NewItem(fmt.Sprintf("%s: %s", st.Title, s.Value(st.Name))).
+			Subtitle("Current value").
+			Icon(IconSettings).
+			Valid(false)
+		for _, v := range values {
+			s.saveItem(st, v)
+		}
+		if len(values) == 0 {
+			s.wf.NewItem("Enter a new value").
+				Subtitle(st.Description).
+				Valid(false)
+		}
+		return
+	}
+
+	if err := s.validate(st, value); err != nil {
+		s.wf.NewWarningItem("Invalid value", err.Error())
+		return
+	}
+	s.saveItem(st, value)
+}
+
+// saveItem adds an Item that saves value.
+func (s *Settings) saveItem(st *Setting, value string) *Item {
+	return s.wf.NewItem(fmt.Sprintf("Set %s to “%s”", st.Title, value)).
+		Subtitle("↩ to save").
+		Arg(value).
+		Autocomplete(st.Name+" "+value).
+		Var(EnvVarSettingName, st.Name).
+		Var(EnvVarSettingValue, value).
+		Valid(true)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// settingType returns the name of the setting type for t or an empty
+// string if t isn't supported.
+func settingType(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "uint"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	}
+	return ""
+}
+
+// fieldTitle converts a field name to a title, e.g. "APIKey" to "API Key".
+func fieldTitle(name string) string {
+	var (
+		out []rune
+		rs  = []rune(name)
+	)
+	for i, r := range rs {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsLower(prev) || (unicode.IsUpper(prev) && nextLower) {
+				out = append(out, ' ')
+			}
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.deanishe.net/env"
+)
+
+type testPrefs struct {
+	Hostname string        `env:"HOST" desc:"Server hostname" default:"localhost"`
+	Port     int           `desc:"Server port" default:"6000"`
+	Mode     string        `desc:"Sync mode" values:"fast, safe" default:"safe"`
+	Interval time.Duration `title:"Update interval" default:"5m"`
+	APIKey   string
+	Verbose  bool
+	Ignored  string `env:"-"`
+	private  string
+}
+
+func testSettings(t *testing.T, wf *Workflow, e env.MapEnv) (*Settings, *testPrefs) {
+	p := &testPrefs{}
+	wf.Config = NewConfig(e)
+	s, err := NewSettings(wf, p)
+	require.Nil(t, err, "NewSettings failed")
+	return s, p
+}
+
+func TestNewSettings(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		s, p := testSettings(t, wf, env.MapEnv{"PORT": "8080", "VERBOSE": "true"})
+
+		var names, titles, types []string
+		for _, st := range s.Settings() {
+			names = append(names, st.Name)
+			titles = append(titles, st.Title)
+			types = append(types, st.Type)
+		}
+		assert.Equal(t, []string{"HOST", "PORT", "MODE", "INTERVAL", "API_KEY", "VERBOSE"}, names, "unexpected names")
+		assert.Equal(t, []string{"Hostname", "Port", "Mode", "Update interval", "API Key", "Verbose"}, titles, "unexpected titles")
+		assert.Equal(t, []string{"string", "int", "string", "duration", "string", "bool"}, types, "unexpected types")
+
+		mode := s.Setting("mode")
+		require.NotNil(t, mode, "setting not found")
+		assert.Equal(t, []string{"fast", "safe"}, mode.Values, "unexpected values")
+		assert.Equal(t, "Sync mode", mode.Description, "unexpected description")
+		assert.Nil(t, s.Setting("IGNORED"), "ignored field has setting")
+
+		// defaults and variables
+		assert.Equal(t, "localhost", p.Hostname, "unexpected default")
+		assert.Equal(t, 8080, p.Port, "variable not loaded")
+		assert.Equal(t, "safe", p.Mode, "unexpected default")
+		assert.Equal(t, 5*time.Minute, p.Interval, "unexpected default")
+		assert.True(t, p.Verbose, "variable not loaded")
+		assert.Equal(t, "5m0s", s.Value("INTERVAL"), "unexpected value")
+	})
+}
+
+func TestNewSettings_invalid(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		tests := []interface{}{
+			testPrefs{},
+			"string",
+			&struct{ List []string }{},
+			&struct {
+				Port int `default:"high"`
+			}{},
+			&struct {
+				Mode string `values:"a,b" default:"c"`
+			}{},
+		}
+		for _, v := range tests {
+			_, err := NewSettings(wf, v)
+			assert.NotNil(t, err, "accepted invalid struct %#v", v)
+		}
+	})
+}
+
+func TestSettings_Validate(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		s, _ := testSettings(t, wf, env.MapEnv{})
+		tests := []struct {
+			name, value string
+			ok          bool
+		}{
+			{"HOST", "example.com", true},
+			{"port", "80", true},
+			{"PORT", "eighty", false},
+			{"MODE", "fast", true},
+			{"MODE", "slow", false},
+			{"INTERVAL", "1h", true},
+			{"INTERVAL", "1 hour", false},
+			{"VERBOSE", "false", true},
+			{"VERBOSE", "maybe", false},
+			{"UNKNOWN", "", false},
+		}
+		for _, td := range tests {
+			err := s.Validate(td.name, td.value)
+			assert.Equal(t, td.ok, err == nil, "unexpected result for %s=%q: %v", td.name, td.value, err)
+		}
+	})
+}
+
+func TestSettings_Save(t *testing.T) {
+	orig := runJS
+	defer func() { runJS = orig }()
+	mj := &mockJSRunner{}
+	runJS = mj.Run
+
+	withTestWf(func(wf *Workflow) {
+		s, p := testSettings(t, wf, env.MapEnv{
+			EnvVarAlfredVersion: "4.0.4",
+			EnvVarBundleID:      "net.deanishe.awgo",
+		})
+		require.Nil(t, s.Save("MODE", "fast"), "save failed")
+		assert.Equal(t, "fast", p.Mode, "field not set")
+		assert.Contains(t, mj.script, `setConfiguration("MODE", {"exportable":false,"inWorkflow":"net.deanishe.awgo","toValue":"fast"})`, "setting not saved")
+		assert.Contains(t, mj.script, `setConfiguration("PORT", {"exportable":false,"inWorkflow":"net.deanishe.awgo","toValue":"6000"})`, "other setting not saved")
+
+		mj.script = ""
+		assert.NotNil(t, s.Save("MODE", "slow"), "invalid value saved")
+		assert.NotNil(t, s.Save("UNKNOWN", "x"), "unknown setting saved")
+		assert.Equal(t, "fast", p.Mode, "field changed")
+		assert.Equal(t, "", mj.script, "invalid settings saved")
+	})
+}
+
+func TestSettings_Run(t *testing.T) {
+	tests := []struct {
+		query  string
+		titles []string
+		valid  []bool
+	}{
+		{"", []string{"Hostname: localhost", "Port: 6000", "Mode: safe", "Update interval: 5m0s", "API Key: ", "Verbose: false"},
+			[]bool{false, false, false, false, false, false}},
+		{"upd", []string{"Update interval: 5m0s"}, []bool{false}},
+		{"zzz", []string{"No Matching Settings"}, []bool{false}},
+		{"MODE ", []string{"Mode: safe", "Set Mode to “fast”", "Set Mode to “safe”"}, []bool{false, true, true}},
+		{"verbose ", []string{"Verbose: false", "Set Verbose to “true”", "Set Verbose to “false”"}, []bool{false, true, true}},
+		{"PORT ", []string{"Port: 6000", "Enter a new value"}, []bool{false, false}},
+		{"PORT 8080", []string{"Set Port to “8080”"}, []bool{true}},
+		{"PORT x", []string{"Invalid value"}, []bool{false}},
+	}
+
+	for _, td := range tests {
+		withTestWf(func(wf *Workflow) {
+			var (
+				buf bytes.Buffer
+				fb  = &Feedback{}
+			)
+			wf.Configure(Output(&buf))
+			s, _ := testSettings(t, wf, env.MapEnv{})
+			s.Run(td.query)
+			require.Nil(t, json.Unmarshal(buf.Bytes(), fb), "unmarshal feedback")
+
+			var valid []bool
+			for _, it := range fb.Items {
+				valid = append(valid, it.valid)
+				if strings.HasPrefix(it.title, "Set ") {
+					assert.Equal(t, strings.ToUpper(strings.Fields(td.query)[0]), it.vars[EnvVarSettingName], "unexpected name variable")
+				}
+			}
+			assert.Equal(t, td.titles, feedbackOrder(fb), "unexpected titles for %q", td.query)
+			assert.Equal(t, td.valid, valid, "unexpected valid for %q", td.query)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// taskArgPrefix is the hidden argument that tells Workflow.Args to run
+// a task instead of returning the arguments. It is followed by the
+// task's name.
+const taskArgPrefix = "_awgo_task:"
+
+// TaskFunc is a function that Spawn runs in the background. args are
+// the arguments passed to Spawn.
+type TaskFunc func(args []string) error
+
+// RegisterTask registers fn as a background task called name. Previously
+// registered tasks with the same name are replaced.
+//
+// Start the task with Spawn. Tasks must be registered before Workflow.Args
+// is called, as it is Args that runs the task in the background process.
+func (wf *Workflow) RegisterTask(name string, fn TaskFunc) {
+	if wf.tasks == nil {
+		wf.tasks = map[string]TaskFunc{}
+	}
+	wf.tasks[name] = fn
+}
+
+// Spawn runs the task registered under name in the background by calling
+// the workflow's own executable (os.Executable) with a hidden argument.
+// Workflow.Args recognises this argument, calls the task function with
+// args and exits.
+//
+// Tasks are run via RunJob with the task name as job name, so only one
+// instance of each task runs at a time (Spawn returns an ErrJobExists
+// error otherwise), and the task's output and exit status are available
+// via Job(name).Status(). The process exits with status 1 if the task
+// function returns an error.
+func (wf *Workflow) Spawn(name string, args ...string) error {
+	cmd, err := wf.taskCmd(name, args...)
+	if err != nil {
+		return err
+	}
+	_, err = wf.RunJob(name, cmd, 0)
+	return err
+}
+
+// taskCmd returns the command that runs the named task.
+func (wf *Workflow) taskCmd(name string, args ...string) (*exec.Cmd, error) {
+	if _, ok := wf.tasks[name]; !ok {
+		return nil, fmt.Errorf("unknown task %q", name)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("find workflow executable: %w", err)
+	}
+	return exec.Command(exe, append([]string{taskArgPrefix + name}, args...)...), nil
+}
+
+// runTask runs a task and exits if args start with the hidden task argument.
+func (wf *Workflow) runTask(args []string) {
+	if len(args) == 0 || !strings.HasPrefix(args[0], taskArgPrefix) {
+		return
+	}
+
+	name := args[0][len(taskArgPrefix):]
+	fn, ok := wf.tasks[name]
+	if !ok {
+		log.Printf("[task] unknown task %q", name)
+		wf.finishLog(true)
+		return
+	}
+
+	log.Printf("[task] running %q ...", name)
+	if err := fn(args[1:]); err != nil {
+		log.Printf("[task] %q failed: %v", name, err)
+		wf.finishLog(true)
+		return
+	}
+	wf.finishLog(false)
+	wf.exit(0)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Spawn calls the workflow's executable with the hidden task argument.
+func TestWorkflow_taskCmd(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		_, err := wf.taskCmd("sync")
+		assert.NotNil(t, err, "unregistered task accepted")
+		assert.NotNil(t, wf.Spawn("sync"), "unregistered task spawned")
+
+		wf.RegisterTask("sync", func([]string) error { return nil })
+		cmd, err := wf.taskCmd("sync", "a", "b")
+		require.Nil(t, err, "build task command")
+
+		exe, err := os.Executable()
+		require.Nil(t, err, "find executable")
+		assert.Equal(t, exe, cmd.Path, "unexpected executable")
+		assert.Equal(t, []string{exe, "_awgo_task:sync", "a", "b"}, cmd.Args, "unexpected arguments")
+	})
+}
+
+// Args runs tasks and exits.
+func TestWorkflow_Args_task(t *testing.T) {
+	origArgs := os.Args
+	defer func() {
+		os.Args = origArgs
+		exitFunc = os.Exit
+	}()
+
+	tests := []struct {
+		name string
+		args []string
+		err  error
+		code int
+	}{
+		{"sync", []string{"a", "b"}, nil, 0},
+		{"sync", nil, errors.New("failed"), 1},
+		{"unknown", nil, nil, 1},
+	}
+
+	for _, td := range tests {
+		td := td
+		withTestWf(func(wf *Workflow) {
+			var called []string
+			wf.RegisterTask("sync", func(args []string) error {
+				called = append([]string{}, args...)
+				return td.err
+			})
+
+			me := &mockExit{code: -1}
+			exitFunc = me.Exit
+			os.Args = append([]string{"blah", "_awgo_task:" + td.name}, td.args...)
+			wf.Args()
+
+			assert.Equal(t, td.code, me.code, "unexpected exit code")
+			if td.name == "sync" {
+				assert.Equal(t, append([]string{}, td.args...), called, "unexpected task args")
+			} else {
+				assert.Nil(t, called, "task called")
+			}
+		})
+	}
+}
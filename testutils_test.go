@@ -5,13 +5,13 @@ package aw
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"go.deanishe.net/env"
+
+	"github.com/ChicK00o/awgo/internal/testenv"
 )
 
 var (
@@ -75,14 +75,11 @@ func panicOnErr(err error) {
 
 // create a temporary directory, call function fn, delete the directory.
 func withTempDir(fn func(dir string)) {
-	p, err := ioutil.TempDir("", "awgo-")
+	p, err := testenv.TempDir("awgo-")
 	if err != nil {
 		panic(err)
 	}
-	if p, err = filepath.EvalSymlinks(p); err != nil {
-		panic(err)
-	}
-	defer panicOnErr(os.RemoveAll(p))
+	defer func() { panicOnErr(os.RemoveAll(p)) }()
 	fn(p)
 }
 
@@ -111,41 +108,20 @@ func withTestEnv(fn func(e env.MapEnv)) {
 // Call function in a test workflow environment.
 func withTestWf(fn func(wf *Workflow)) {
 	withTestEnv(func(e env.MapEnv) {
-		var (
-			dir string
-			err error
-		)
-
-		if dir, err = ioutil.TempDir("", "awgo-"); err != nil {
+		dir, err := testenv.TempDir("awgo-")
+		if err != nil {
 			panic(err)
 		}
-		// TempDir() returns a symlink on my macOS :(
-		if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		defer func() { panicOnErr(os.RemoveAll(dir)) }()
+
+		// Create cache & data dirs and update env to point to them
+		cacheDir, dataDir, err := testenv.Workdirs(dir)
+		if err != nil {
 			panic(err)
 		}
-
-		defer func() {
-			if err := os.RemoveAll(dir); err != nil {
-				panic(err)
-			}
-		}()
-
-		var (
-			dataDir  = filepath.Join(dir, "data")
-			cacheDir = filepath.Join(dir, "cache")
-		)
-
-		// Update env to point to cache & data dirs
 		e[EnvVarCacheDir] = cacheDir
 		e[EnvVarDataDir] = dataDir
 
-		// Create test files & directories
-		for _, p := range []string{dataDir, cacheDir} {
-			if err := os.MkdirAll(p, os.ModePerm); err != nil {
-				panic(err)
-			}
-		}
-
 		// Create workflow for current environment and pass it to function.
 		var wf = NewFromEnv(e)
 		fn(wf)
@@ -0,0 +1,187 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	aw "github.com/ChicK00o/awgo"
+)
+
+// matches a rollout note in the description of a release, e.g. "Rollout: 25%"
+var rxRolloutNote = regexp.MustCompile(`(?im)^\s*rollout:(.*)$`)
+
+// Channel is a named release channel, such as "stable" or "beta".
+//
+// An Updater's channels are ordered from most to least stable. Users on
+// a channel are offered its releases and those of the channels before it,
+// so users on "beta" also get stable releases, but users on "stable" don't
+// get betas.
+type Channel struct {
+	Name string // Name of channel, e.g. "beta"
+	// Match matches the version numbers of the channel's releases,
+	// e.g. "2.0.0-beta.1". Versions are matched without a "v" prefix.
+	// If nil, the channel has no releases of its own.
+	Match *regexp.Regexp
+}
+
+// DefaultChannels are the release channels used by an Updater if
+// Updater.Channels is empty.
+var DefaultChannels = []Channel{
+	{Name: "stable"},
+	{Name: "beta", Match: regexp.MustCompile(`(?i)-(alpha|beta|pre|rc)`)},
+	{Name: "nightly", Match: regexp.MustCompile(`(?i)-(nightly|dev|snapshot)`)},
+}
+
+// ReleaseChannel is an UpdaterOption that sets Updater.Channel.
+func ReleaseChannel(name string) UpdaterOption {
+	return func(u *Updater) { u.Channel = name }
+}
+
+// ChannelFromConfig is an UpdaterOption that reads Updater.Channel from
+// the workflow variable name, so users can choose their channel in the
+// workflow's configuration. If the variable is empty, Updater.Prereleases
+// decides whether pre-releases are offered.
+func ChannelFromConfig(name string) UpdaterOption {
+	return func(u *Updater) { u.Channel = aw.NewConfig().Get(name) }
+}
+
+// Channels is an UpdaterOption that sets Updater.Channels. Channels must
+// be ordered from most to least stable.
+//
+//	update.Channels(
+//		update.Channel{Name: "stable"},
+//		update.Channel{Name: "testing", Match: regexp.MustCompile(`-test`)},
+//	)
+func Channels(channels ...Channel) UpdaterOption {
+	return func(u *Updater) { u.Channels = channels }
+}
+
+// channels returns the Updater's channels or DefaultChannels.
+func (u *Updater) channels() []Channel {
+	if len(u.Channels) == 0 {
+		return DefaultChannels
+	}
+	return u.Channels
+}
+
+// channelIndex returns the position of channel name in the Updater's
+// channels or -1 if there is no such channel.
+func (u *Updater) channelIndex(name string) int {
+	for i, c := range u.channels() {
+		if strings.EqualFold(c.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// channelOf returns the name of the channel dl belongs to. It is the
+// channel set by the Source, the first channel that matches dl's version,
+// the first channel if dl isn't a pre-release, and otherwise the second.
+func (u *Updater) channelOf(dl Download) string {
+	if dl.Channel != "" {
+		return dl.Channel
+	}
+	var (
+		channels = u.channels()
+		v        = dl.Version.String()
+	)
+	for _, c := range channels {
+		if c.Match != nil && c.Match.MatchString(v) {
+			return c.Name
+		}
+	}
+	if (dl.Prerelease || dl.Version.Prerelease != "") && len(channels) > 1 {
+		return channels[1].Name
+	}
+	return channels[0].Name
+}
+
+// inChannel returns true if dl is offered to users of the Updater's channel.
+// If no channel is set, only stable releases are offered, unless
+// Updater.Prereleases is true.
+func (u *Updater) inChannel(dl Download) bool {
+	if u.Channel == "" {
+		return !dl.Prerelease || u.Prereleases
+	}
+	name := u.channelOf(dl)
+	if strings.EqualFold(name, u.Channel) {
+		return true
+	}
+	i, max := u.channelIndex(name), u.channelIndex(u.Channel)
+	if max < 0 {
+		log.Printf("unknown update channel %q, using %q", u.Channel, u.channels()[0].Name)
+		max = 0
+	}
+	return i >= 0 && i <= max
+}
+
+// inRollout returns true if dl is rolled out to this installation. Which
+// installations get a staged release is decided by hashing the workflow's
+// UID and the release's version, so an installation stays eligible as the
+// rollout is increased.
+func (u *Updater) inRollout(dl Download) bool {
+	if dl.Rollout == nil || *dl.Rollout >= 1 {
+		return true
+	}
+	if *dl.Rollout <= 0 || u.WorkflowUID == "" {
+		return false
+	}
+	return rolloutBucket(u.WorkflowUID, dl.Version) < *dl.Rollout
+}
+
+// rolloutBucket returns a number between 0 and 1 that is unique to the
+// combination of uid and v.
+func rolloutBucket(uid string, v SemVer) float64 {
+	sum := sha256.Sum256([]byte(uid + "/" + v.String()))
+	// top 53 bits fit exactly in a float64
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+// parseRollout parses a rollout percentage ("25%") or fraction ("0.25").
+// It returns nil (i.e. all installations) if s is empty, and 0 (i.e. no
+// installations) if s is invalid, so a mistyped rollout withholds the
+// release instead of shipping it to everyone.
+func parseRollout(s string) *float64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	var (
+		f   float64
+		err error
+	)
+	if strings.HasSuffix(s, "%") {
+		if f, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64); err == nil {
+			f /= 100
+		}
+	} else {
+		f, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil || f < 0 || f > 1 {
+		log.Printf("invalid rollout %q: release withheld", s)
+		f = 0
+	}
+	return &f
+}
+
+// rolloutNote returns the rollout declared in the description of a release
+// by a line such as "Rollout: 25%", or nil if there is no such line.
+func rolloutNote(body string) *float64 {
+	if m := rxRolloutNote.FindStringSubmatch(body); len(m) == 2 {
+		if s := strings.TrimSpace(m[1]); s != "" {
+			return parseRollout(s)
+		}
+		log.Printf("invalid rollout %q: release withheld", m[0])
+		var f float64
+		return &f
+	}
+	return nil
+}
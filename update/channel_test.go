@@ -0,0 +1,281 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelOf(t *testing.T) {
+	t.Parallel()
+
+	custom := []Channel{
+		{Name: "stable"},
+		{Name: "testing", Match: regexp.MustCompile(`-test`)},
+	}
+
+	tests := []struct {
+		dl       Download
+		channels []Channel
+		x        string
+	}{
+		{Download{Version: mustVersion("1.0")}, nil, "stable"},
+		{Download{Version: mustVersion("1.0-beta")}, nil, "beta"},
+		{Download{Version: mustVersion("1.0-beta.2"), Prerelease: true}, nil, "beta"},
+		{Download{Version: mustVersion("1.0-RC1")}, nil, "beta"},
+		{Download{Version: mustVersion("1.0-alpha")}, nil, "beta"},
+		{Download{Version: mustVersion("1.0-nightly.20210401")}, nil, "nightly"},
+		{Download{Version: mustVersion("1.0-dev")}, nil, "nightly"},
+		// pre-release that matches no channel
+		{Download{Version: mustVersion("1.0"), Prerelease: true}, nil, "beta"},
+		{Download{Version: mustVersion("1.0-foo")}, nil, "beta"},
+		// set by Source
+		{Download{Version: mustVersion("1.0"), Channel: "nightly"}, nil, "nightly"},
+		{Download{Version: mustVersion("1.0-beta"), Channel: "internal"}, nil, "internal"},
+		// custom channels
+		{Download{Version: mustVersion("1.0-test1")}, custom, "testing"},
+		{Download{Version: mustVersion("1.0-beta")}, custom, "testing"},
+		{Download{Version: mustVersion("1.0")}, custom, "stable"},
+		{Download{Version: mustVersion("1.0"), Prerelease: true}, custom[:1], "stable"},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%v", td.dl.Version), func(t *testing.T) {
+			t.Parallel()
+			u := &Updater{Channels: td.channels}
+			assert.Equal(t, td.x, u.channelOf(td.dl), "unexpected channel")
+		})
+	}
+}
+
+func TestInChannel(t *testing.T) {
+	t.Parallel()
+
+	var (
+		stable  = Download{Version: mustVersion("1.0")}
+		beta    = Download{Version: mustVersion("1.1-beta"), Prerelease: true}
+		nightly = Download{Version: mustVersion("1.2-nightly"), Prerelease: true}
+		custom  = Download{Version: mustVersion("1.3"), Channel: "internal"}
+	)
+
+	tests := []struct {
+		channel     string
+		prereleases bool
+		dl          Download
+		x           bool
+	}{
+		// no channel: Prereleases decides
+		{"", false, stable, true},
+		{"", false, beta, false},
+		{"", true, beta, true},
+		{"", true, nightly, true},
+		{"", false, custom, true},
+
+		{"stable", false, stable, true},
+		{"stable", false, beta, false},
+		{"stable", true, beta, false},
+		{"stable", false, nightly, false},
+		{"beta", false, stable, true},
+		{"beta", false, beta, true},
+		{"Beta", false, beta, true},
+		{"beta", false, nightly, false},
+		{"nightly", false, stable, true},
+		{"nightly", false, beta, true},
+		{"nightly", false, nightly, true},
+		{"nightly", false, custom, false},
+
+		// channels not in Updater.Channels
+		{"internal", false, custom, true},
+		{"internal", false, stable, true},
+		{"internal", false, beta, false},
+		{"bob", false, stable, true},
+		{"bob", false, beta, false},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%q/%v", td.channel, td.dl.Version), func(t *testing.T) {
+			t.Parallel()
+			u := &Updater{Channel: td.channel, Prereleases: td.prereleases}
+			assert.Equal(t, td.x, u.inChannel(td.dl), "unexpected result")
+		})
+	}
+}
+
+// rollout returns a pointer to Download.Rollout f.
+func rollout(f float64) *float64 { return &f }
+
+func TestParseRollout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in string
+		x  *float64
+	}{
+		{"", nil},
+		{"25%", rollout(0.25)},
+		{" 12.5 % ", rollout(0.125)},
+		{"100%", rollout(1)},
+		{"0.3", rollout(0.3)},
+		{"1", rollout(1)},
+		{"0", rollout(0)},
+		{"0%", rollout(0)},
+		// invalid values withhold release
+		{"bob", rollout(0)},
+		{"%", rollout(0)},
+		{"150%", rollout(0)},
+		{"25", rollout(0)},
+		{"-0.5", rollout(0)},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.in, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, td.x, parseRollout(td.in), "unexpected rollout")
+		})
+	}
+
+	assert.Equal(t, rollout(0.1), rolloutNote("Bug fixes.\r\n\r\nRollout: 10%\r\n"), "unexpected rollout")
+	assert.Equal(t, rollout(0.5), rolloutNote("rollout: 0.5"), "unexpected rollout")
+	assert.Equal(t, rollout(0), rolloutNote("Rollout: 0%"), "unexpected rollout")
+	assert.Equal(t, rollout(0), rolloutNote("Rollout: 25"), "unexpected rollout")
+	assert.Equal(t, rollout(0), rolloutNote("Rollout: 150%"), "unexpected rollout")
+	assert.Equal(t, rollout(0), rolloutNote("Rollout: soon"), "unexpected rollout")
+	assert.Equal(t, rollout(0), rolloutNote("Rollout:"), "unexpected rollout")
+	assert.Nil(t, rolloutNote("Staged rollout: 10% per day"), "unexpected rollout")
+	assert.Nil(t, rolloutNote(""), "unexpected rollout")
+}
+
+func TestInRollout(t *testing.T) {
+	t.Parallel()
+
+	v := mustVersion("2.0")
+	u := &Updater{}
+	assert.True(t, u.inRollout(Download{Version: v}), "unstaged release not available")
+	assert.True(t, u.inRollout(Download{Version: v, Rollout: rollout(1)}), "complete rollout not available")
+	assert.False(t, u.inRollout(Download{Version: v, Rollout: rollout(0.99)}), "staged release available without UID")
+	u = &Updater{WorkflowUID: "user.workflow.test"}
+	assert.False(t, u.inRollout(Download{Version: v, Rollout: rollout(0)}), "0% rollout available")
+
+	// buckets are deterministic and roughly uniform
+	var (
+		n        = 2000
+		included = map[float64]int{}
+		rollouts = []float64{0.1, 0.5, 0.9}
+	)
+	for i := 0; i < n; i++ {
+		u := &Updater{WorkflowUID: fmt.Sprintf("user.workflow.%d", i)}
+		assert.Equal(t, rolloutBucket(u.WorkflowUID, v), rolloutBucket(u.WorkflowUID, v), "bucket not deterministic")
+		prev := false
+		for _, r := range rollouts {
+			ok := u.inRollout(Download{Version: v, Rollout: rollout(r)})
+			// increasing rollout doesn't exclude installations
+			assert.False(t, prev && !ok, "installation excluded by larger rollout")
+			prev = ok
+			if ok {
+				included[r]++
+			}
+		}
+	}
+	for _, r := range rollouts {
+		f := float64(included[r]) / float64(n)
+		assert.InDelta(t, r, f, 0.05, "rollout of %v reached %v of installations", r, f)
+	}
+}
+
+func TestUpdaterChannels(t *testing.T) {
+	src := &testSource{dls: []Download{
+		{Version: mustVersion("3.0.0-nightly.1"), Prerelease: true, Filename: "Dummy.alfredworkflow"},
+		{Version: mustVersion("2.1.0-beta"), Prerelease: true, Filename: "Dummy.alfredworkflow"},
+		{Version: mustVersion("2.0.0"), Rollout: rollout(0.000001), Filename: "Dummy.alfredworkflow"},
+		{Version: mustVersion("1.5.0"), Filename: "Dummy.alfredworkflow"},
+	}}
+
+	panicOnError(os.Setenv("TEST_UPDATE_CHANNEL", "beta"))
+	defer func() { panicOnError(os.Unsetenv("TEST_UPDATE_CHANNEL")) }()
+
+	tests := []struct {
+		opts []UpdaterOption
+		x    string // expected latest version
+	}{
+		{nil, "1.5.0"},
+		{[]UpdaterOption{ReleaseChannel("stable")}, "1.5.0"},
+		{[]UpdaterOption{ReleaseChannel("beta")}, "2.1.0-beta"},
+		{[]UpdaterOption{ChannelFromConfig("TEST_UPDATE_CHANNEL")}, "2.1.0-beta"},
+		{[]UpdaterOption{ChannelFromConfig("TEST_UNSET_VARIABLE")}, "1.5.0"},
+		{[]UpdaterOption{ReleaseChannel("nightly")}, "3.0.0-nightly.1"},
+		{[]UpdaterOption{Channels(Channel{Name: "stable"}, Channel{Name: "nightly", Match: regexp.MustCompile(`-nightly`)}), ReleaseChannel("nightly")}, "3.0.0-nightly.1"},
+		{[]UpdaterOption{Channels(Channel{Name: "stable"}, Channel{Name: "nightly", Match: regexp.MustCompile(`-nightly`)})}, "1.5.0"},
+		{[]UpdaterOption{ReleaseChannel("beta"), VersionConstraint(MustConstraint("<2.1"))}, "1.5.0"},
+	}
+
+	for i, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			// cache isn't used, as downloads are set
+			u, err := NewUpdater(src, "1.0", "unused")
+			require.Nil(t, err, "create updater")
+			for _, opt := range td.opts {
+				opt(u)
+			}
+			u.WorkflowUID = "user.workflow.test"
+			u.downloads = src.dls
+
+			dl := u.latest()
+			require.NotNil(t, dl, "no download")
+			assert.Equal(t, td.x, dl.Version.String(), "unexpected version")
+		})
+	}
+}
+
+func TestSourceRollout(t *testing.T) {
+	t.Parallel()
+
+	files, err := parseReleaseFiles([]byte(`[{
+		"tag_name": "v1.0",
+		"body": "Fixed a bug.\r\n\r\nRollout: 20%",
+		"assets": [{"name": "Dummy.alfredworkflow", "browser_download_url": "https://example.com/Dummy.alfredworkflow"}]
+	}]`))
+	require.Nil(t, err, "parse GitHub releases")
+	require.Equal(t, 1, len(files), "unexpected downloads")
+	assert.Equal(t, rollout(0.2), files[0].Rollout, "unexpected rollout")
+
+	// invalid rollout withholds release
+	files, err = parseReleaseFiles([]byte(`[{
+		"tag_name": "v1.0",
+		"body": "Rollout: 25",
+		"assets": [{"name": "Dummy.alfredworkflow", "browser_download_url": "https://example.com/Dummy.alfredworkflow"}]
+	}]`))
+	require.Nil(t, err, "parse GitHub releases")
+	require.Equal(t, 1, len(files), "unexpected downloads")
+	u := &Updater{WorkflowUID: "user.workflow.test"}
+	assert.False(t, u.inRollout(files[0].Download), "release with invalid rollout available")
+
+	files, err = parseGitLabReleases([]byte(`[{
+		"tag_name": "v1.0",
+		"description": "Rollout: 0.4",
+		"assets": {"links": [{"name": "Dummy.alfredworkflow", "url": "https://example.com/Dummy.alfredworkflow"}]}
+	}]`))
+	require.Nil(t, err, "parse GitLab releases")
+	require.Equal(t, 1, len(files), "unexpected downloads")
+	assert.Equal(t, rollout(0.4), files[0].Rollout, "unexpected rollout")
+
+	dl, err := parseMetadata([]byte(`{"alfredworkflow": {
+		"version": "1.0",
+		"downloadurl": "https://example.com/Dummy.alfredworkflow",
+		"channel": "beta",
+		"rollout": 0.3
+	}}`))
+	require.Nil(t, err, "parse metadata")
+	assert.Equal(t, "beta", dl.Channel, "unexpected channel")
+	assert.Equal(t, rollout(0.3), dl.Rollout, "unexpected rollout")
+}
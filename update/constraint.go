@@ -0,0 +1,341 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Constraint is a version constraint, such as ">=1.2, <2.0" or "^1.4".
+//
+// A constraint is one or more comparisons separated by commas or spaces,
+// all of which a version must satisfy. Alternative constraints are separated
+// by "||", e.g. "^1.4 || ^2.1". The supported comparisons are:
+//
+//	1.2.3, =1.2.3   - version is 1.2.3 ("1.2" is the same as "1.2.0")
+//	!=1.2.3         - version isn't 1.2.3
+//	>1.2.3, >=1.2.3 - version is greater than (or equal to) 1.2.3
+//	<1.2.3, <=1.2.3 - version is less than (or equal to) 1.2.3
+//	1.2.x, 1.2.*    - version is 1.2.0 or greater, but less than 1.3.0
+//	!=1.2.x         - version isn't in the range 1.2.x
+//	^1.2.3          - compatible version: >=1.2.3, <2.0.0
+//	~1.2.3          - patch version: >=1.2.3, <1.3.0
+//	*               - any version
+//
+// As with npm, caret ranges for 0.x versions don't change the left-most
+// non-zero number, so "^0.2.3" means ">=0.2.3, <0.3.0", and "^0.0.3" means
+// ">=0.0.3, <0.0.4", while "~1" means ">=1.0.0, <2.0.0".
+//
+// Pre-releases of a version don't satisfy a "less than" comparison with
+// that version, so "1.4.0-beta" doesn't satisfy "<1.4" or "~1.3".
+// Constraint doesn't otherwise treat pre-releases specially: whether
+// they are installed is set by Updater.Prereleases.
+//
+// The zero value (and the empty constraint) is satisfied by any version.
+type Constraint struct {
+	s    string
+	sets [][]comparison // alternative sets of comparisons
+}
+
+// NewConstraint parses a version constraint. See Constraint for the syntax.
+func NewConstraint(s string) (Constraint, error) {
+	c := Constraint{s: strings.TrimSpace(s)}
+	if c.s == "" {
+		return c, nil
+	}
+	for _, alt := range strings.Split(c.s, "||") {
+		terms, err := splitConstraint(alt)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+		}
+		var set []comparison
+		for _, term := range terms {
+			cmps, err := parseComparison(term)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			set = append(set, cmps...)
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+// MustConstraint is like NewConstraint, but panics if s is invalid.
+func MustConstraint(s string) Constraint {
+	c, err := NewConstraint(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// VersionConstraint is an UpdaterOption that sets Updater.Constraint.
+func VersionConstraint(c Constraint) UpdaterOption {
+	return func(u *Updater) { u.Constraint = c }
+}
+
+// Check returns true if v satisfies the constraint.
+func (c Constraint) Check(v SemVer) bool {
+	if len(c.sets) == 0 {
+		return true
+	}
+	for _, set := range c.sets {
+		ok := true
+		for _, cmp := range set {
+			if !cmp.check(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsZero returns true if Constraint is satisfied by any version.
+func (c Constraint) IsZero() bool { return len(c.sets) == 0 }
+
+// String returns the constraint as passed to NewConstraint.
+func (c Constraint) String() string { return c.s }
+
+// comparison operators
+const (
+	opEq       = "="
+	opNe       = "!="
+	opGt       = ">"
+	opGte      = ">="
+	opLt       = "<"
+	opLte      = "<="
+	opNotRange = "!range" // not in [v, max)
+)
+
+// comparison compares versions to v.
+type comparison struct {
+	op  string
+	v   SemVer
+	max SemVer // upper bound of opNotRange
+}
+
+func (cmp comparison) check(v SemVer) bool {
+	switch cmp.op {
+	case opEq:
+		return v.Eq(cmp.v)
+	case opNe:
+		return v.Ne(cmp.v)
+	case opGt:
+		return v.Gt(cmp.v)
+	case opGte:
+		return v.Gte(cmp.v)
+	case opLt:
+		return versionBefore(v, cmp.v)
+	case opLte:
+		return v.Lte(cmp.v)
+	case opNotRange:
+		return v.Lt(cmp.v) || !versionBefore(v, cmp.max)
+	}
+	return false
+}
+
+// versionBefore returns true if v < max and v isn't a pre-release of max.
+func versionBefore(v, max SemVer) bool {
+	if max.Prerelease == "" && v.Prerelease != "" &&
+		v.Major == max.Major && v.Minor == max.Minor && v.Patch == max.Patch {
+		return false
+	}
+	return v.Lt(max)
+}
+
+// operators in the order they must be matched
+var constraintOps = []string{"==", "!=", ">=", "<=", "=", ">", "<", "^", "~"}
+
+// splitConstraint splits a set of comparisons into terms. Terms are
+// separated by commas or whitespace, and whitespace is allowed between
+// an operator and its version.
+func splitConstraint(s string) ([]string, error) {
+	var terms []string
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			return nil, errors.New("empty comparison")
+		}
+		var op string
+		for _, f := range fields {
+			if isConstraintOp(f) {
+				if op != "" {
+					return nil, fmt.Errorf("missing version after %q", op)
+				}
+				op = f
+				continue
+			}
+			terms = append(terms, op+f)
+			op = ""
+		}
+		if op != "" {
+			return nil, fmt.Errorf("missing version after %q", op)
+		}
+	}
+	return terms, nil
+}
+
+func isConstraintOp(s string) bool {
+	for _, op := range constraintOps {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseComparison parses a term such as ">=1.2" or "^1.4" into one or
+// more comparisons.
+func parseComparison(term string) ([]comparison, error) {
+	var op string
+	for _, s := range constraintOps {
+		if strings.HasPrefix(term, s) {
+			op = s
+			break
+		}
+	}
+	// n is the number of version parts before any wildcard, e.g. 2 for
+	// "1.2" or "1.2.x"
+	v, n, wildcard, err := parseVersionRange(strings.TrimPrefix(term, op))
+	if err != nil {
+		return nil, err
+	}
+	if op == "==" {
+		op = opEq
+	}
+
+	switch op {
+	case "", opEq:
+		if n == 0 {
+			return nil, nil
+		}
+		if wildcard {
+			return []comparison{{op: opGte, v: v}, {op: opLt, v: nextVersion(v, n)}}, nil
+		}
+		return []comparison{{op: opEq, v: v}}, nil
+	case opNe:
+		if n == 0 {
+			return nil, errors.New("nothing matches !=*")
+		}
+		if wildcard {
+			return []comparison{{op: opNotRange, v: v, max: nextVersion(v, n)}}, nil
+		}
+		return []comparison{{op: opNe, v: v}}, nil
+	case opGt:
+		if wildcard {
+			if n == 0 {
+				return nil, errors.New("nothing matches >*")
+			}
+			return []comparison{{op: opGte, v: nextVersion(v, n)}}, nil
+		}
+		return []comparison{{op: opGt, v: v}}, nil
+	case opGte:
+		return []comparison{{op: opGte, v: v}}, nil
+	case opLt:
+		if n == 0 {
+			return nil, errors.New("nothing matches <*")
+		}
+		return []comparison{{op: opLt, v: v}}, nil
+	case opLte:
+		if wildcard {
+			if n == 0 {
+				return nil, nil
+			}
+			return []comparison{{op: opLt, v: nextVersion(v, n)}}, nil
+		}
+		return []comparison{{op: opLte, v: v}}, nil
+	case "^":
+		if n == 0 {
+			return nil, nil
+		}
+		// bump left-most non-zero part
+		i := 1
+		if v.Major == 0 && n > 1 {
+			i = 2
+			if v.Minor == 0 && n > 2 {
+				i = 3
+			}
+		}
+		return []comparison{{op: opGte, v: v}, {op: opLt, v: nextVersion(v, i)}}, nil
+	case "~":
+		if n == 0 {
+			return nil, nil
+		}
+		i := 2
+		if n == 1 {
+			i = 1
+		}
+		return []comparison{{op: opGte, v: v}, {op: opLt, v: nextVersion(v, i)}}, nil
+	}
+	return nil, fmt.Errorf("invalid operator in %q", term)
+}
+
+// parseVersionRange parses a version that may be partial or contain
+// wildcards, e.g. "1.2" or "1.2.x". It returns the lowest version in the
+// range, the number of parts that were specified before any wildcard, and
+// whether the version contains a wildcard.
+func parseVersionRange(s string) (v SemVer, n int, wildcard bool, err error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return SemVer{}, 0, false, errors.New("empty version")
+	}
+
+	var (
+		core   = s
+		suffix string
+	)
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		core, suffix = s[:i], s[i:]
+	}
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return SemVer{}, 0, false, fmt.Errorf("invalid version %q", s)
+	}
+
+	n = len(parts)
+	for i, p := range parts {
+		if p == "" {
+			return SemVer{}, 0, false, fmt.Errorf("invalid version %q", s)
+		}
+		if p == "x" || p == "X" || p == "*" {
+			if !wildcard {
+				n, wildcard = i, true
+			}
+			parts[i] = "0"
+		} else if wildcard {
+			return SemVer{}, 0, false, fmt.Errorf("invalid version %q: number after wildcard", s)
+		}
+	}
+	if wildcard && suffix != "" {
+		return SemVer{}, 0, false, fmt.Errorf("invalid version %q: wildcard with pre-release or build", s)
+	}
+	if n == 0 {
+		return SemVer{}, 0, true, nil
+	}
+
+	if v, err = NewSemVer(strings.Join(parts, ".") + suffix); err != nil {
+		return SemVer{}, 0, false, err
+	}
+	return v, n, wildcard, nil
+}
+
+// nextVersion returns v with part i (1 = major, 2 = minor, 3 = patch)
+// incremented and the following parts set to zero.
+func nextVersion(v SemVer, i int) SemVer {
+	switch i {
+	case 1:
+		return SemVer{Major: v.Major + 1}
+	case 2:
+		return SemVer{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		return SemVer{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+}
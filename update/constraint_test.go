@@ -0,0 +1,306 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConstraint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in    string
+		valid bool
+	}{
+		// valid constraints
+		{"", true},
+		{"*", true},
+		{"1.2.3", true},
+		{"v1.2", true},
+		{"=1.2.3", true},
+		{"==1.2.3", true},
+		{"!=1.2.3", true},
+		{">1.2", true},
+		{">=1.2", true},
+		{"<2", true},
+		{"<=2.0.0", true},
+		{"^1.4", true},
+		{"~2.3.1", true},
+		{"1.x", true},
+		{"1.2.*", true},
+		{"1.X.X", true},
+		{"!=3.1.x", true},
+		{">=1.2, <2.0", true},
+		{">=1.2 <2.0", true},
+		{">= 1.2, < 2.0", true},
+		{"^1.4, !=1.5.0, !=1.6.2", true},
+		{"^1.4 || ^2.1", true},
+		{">=1.0.0-beta, <1.0.0", true},
+		{"<=*", true},
+		{">=*", true},
+		// invalid constraints
+		{"bob", false},
+		{"1.2.3.4", false},
+		{"01.2", false},
+		{">=", false},
+		{">= >=1.0", false},
+		{">=1.2,", false},
+		{",<2", false},
+		{"^1.4 ||", false},
+		{"|| ^1.4", false},
+		{"=>1.2", false},
+		{"~>1.2", false},
+		{"!1.2", false},
+		{"1.x.2", false},
+		{"1.x-beta", false},
+		{"1.2 - 2.0", false},
+		{"<*", false},
+		{">*", false},
+		{"!=*", false},
+		{"^bob", false},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("Constraint(%q)", td.in), func(t *testing.T) {
+			t.Parallel()
+			c, err := NewConstraint(td.in)
+			if !td.valid {
+				assert.NotNil(t, err, "invalid constraint accepted")
+				return
+			}
+			require.Nil(t, err, "valid constraint rejected")
+			assert.Equal(t, td.in, c.String(), "unexpected string")
+		})
+	}
+}
+
+func TestConstraint_Check(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		constraint string
+		version    string
+		x          bool
+	}{
+		// any version
+		{"", "1.0", true},
+		{"", "0.0.1-alpha", true},
+		{"*", "5.2.1", true},
+		{"x", "5.2.1", true},
+		{">=*", "0.1", true},
+		{"<=*", "9999", true},
+
+		// exact
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "v1.2.3", true},
+		{"1.2.3", "1.2.3+build5", true},
+		{"1.2.3", "1.2.4", false},
+		{"1.2.3", "1.2.3-beta", false},
+		{"1.2", "1.2.0", true},
+		{"1.2", "1.2.1", false},
+		{"=1.2.3", "1.2.3", true},
+		{"==1.2.3", "1.2.3", true},
+		{"=1.2.3-beta", "1.2.3-beta", true},
+		{"=1.2.3-beta", "1.2.3", false},
+
+		// exclusions
+		{"!=1.2.3", "1.2.3", false},
+		{"!=1.2.3", "1.2.4", true},
+		{"!=1.2.3", "1.2.3-beta", true},
+		{"!=3.1.x", "3.1.0", false},
+		{"!=3.1.x", "3.1.9", false},
+		{"!=3.1.x", "3.0.9", true},
+		{"!=3.1.x", "3.2.0", true},
+		{"!=3.1.x", "3.2.0-beta", true},
+		{"!=3.x", "3.9.9", false},
+		{"!=3.x", "4.0.0", true},
+
+		// comparisons
+		{">1.2", "1.2.0", false},
+		{">1.2", "1.2.1", true},
+		{">1.2", "1.3.0-beta", true},
+		{">=1.2", "1.2.0", true},
+		{">=1.2", "1.1.9", false},
+		{">=1.2", "1.2.0-beta", false},
+		{"<2", "1.9.9", true},
+		{"<2", "2.0.0", false},
+		{"<2", "2.0.0-beta", false},
+		{"<2.0.0-rc", "2.0.0-beta", true},
+		{"<2.0.0-rc", "2.0.0-rc", false},
+		{"<=2", "2.0.0", true},
+		{"<=2", "2.0.1", false},
+		{"<=2", "2.0.0-beta", true},
+
+		// wildcard comparisons
+		{">1.2.x", "1.2.9", false},
+		{">1.2.x", "1.3.0", true},
+		{">=1.2.x", "1.2.0", true},
+		{"<1.2.x", "1.1.9", true},
+		{"<1.2.x", "1.2.0", false},
+		{"<=1.2.x", "1.2.9", true},
+		{"<=1.2.x", "1.3.0", false},
+
+		// ranges
+		{">=1.2, <2.0", "1.2.0", true},
+		{">=1.2, <2.0", "1.9.9", true},
+		{">=1.2, <2.0", "2.0.0", false},
+		{">=1.2, <2.0", "2.0.0-beta", false},
+		{">=1.2, <2.0", "1.1.0", false},
+		{">=1.2 <2.0", "1.5", true},
+		{">= 1.2, < 2.0", "2.1", false},
+		{">=1.0.0-beta, <1.0.0-rc", "1.0.0-beta.2", true},
+		{">=1.0.0-beta, <1.0.0", "1.0.0-rc", false}, // pre-release of upper bound
+		// examples in Constraint docs
+		{"<1.4", "1.4.0-beta", false},
+		{"~1.3", "1.4.0-beta", false},
+		{"^1.3", "1.4.0-beta", true},
+		{">=1.0.0-beta, <1.0.0", "1.0.0", false},
+
+		// wildcards
+		{"1.x", "1.0.0", true},
+		{"1.x", "1.99.99", true},
+		{"1.x", "2.0.0", false},
+		{"1.x", "0.9.0", false},
+		{"1.x", "2.0.0-beta", false},
+		{"1.2.*", "1.2.0", true},
+		{"1.2.*", "1.2.7", true},
+		{"1.2.*", "1.3.0", false},
+		{"1.X.X", "1.5.5", true},
+		{"1.*.*", "2.0.0", false},
+
+		// caret
+		{"^1.4", "1.4.0", true},
+		{"^1.4", "1.9.9", true},
+		{"^1.4", "1.3.9", false},
+		{"^1.4", "2.0.0", false},
+		{"^1.4", "2.0.0-beta", false},
+		{"^1.4", "1.5.0-beta", true},
+		{"^1.2.3", "1.2.3", true},
+		{"^1.2.3", "1.2.2", false},
+		{"^1", "1.9.0", true},
+		{"^1", "2.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0.0", "0.0.9", true},
+		{"^0.0", "0.1.0", false},
+		{"^0", "0.9.9", true},
+		{"^0", "1.0.0", false},
+		{"^0.x", "0.5.0", true},
+		{"^1.4.0-beta", "1.4.0-rc", true},
+		{"^1.4.0-beta", "1.4.0-alpha", false},
+		{"^*", "3.0.0", true},
+
+		// tilde
+		{"~2.3.1", "2.3.1", true},
+		{"~2.3.1", "2.3.9", true},
+		{"~2.3.1", "2.3.0", false},
+		{"~2.3.1", "2.4.0", false},
+		{"~2.3", "2.3.0", true},
+		{"~2.3", "2.4.0", false},
+		{"~2", "2.9.0", true},
+		{"~2", "3.0.0", false},
+		{"~0.1", "0.1.5", true},
+		{"~0.1", "0.2.0", false},
+		{"~1.2.x", "1.2.5", true},
+		{"~1.2.x", "1.3.0", false},
+
+		// combined with exclusions
+		{"^1.4, !=1.5.0", "1.5.0", false},
+		{"^1.4, !=1.5.0", "1.5.1", true},
+		{"^3, !=3.1.0", "3.1.0", false},
+		{"^3, !=3.1.0", "3.0.9", true},
+		{"^3, !=3.1.x", "3.1.5", false},
+		{"^3, !=3.1.x", "3.2.0", true},
+
+		// alternatives
+		{"^1.4 || ^2.1", "1.4.0", true},
+		{"^1.4 || ^2.1", "2.0.0", false},
+		{"^1.4 || ^2.1", "2.1.0", true},
+		{"^1.4 || ^2.1", "3.0.0", false},
+		{"1.2.3 || >=2", "1.2.3", true},
+		{"1.2.3 || >=2", "1.2.4", false},
+		{"1.2.3 || >=2", "5.0", true},
+		{"<1 || *", "7.0", true},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%q.Check(%q)", td.constraint, td.version), func(t *testing.T) {
+			t.Parallel()
+			c, err := NewConstraint(td.constraint)
+			require.Nil(t, err, "parse constraint")
+			v, err := NewSemVer(td.version)
+			require.Nil(t, err, "parse version")
+			assert.Equal(t, td.x, c.Check(v), "unexpected result")
+		})
+	}
+}
+
+func TestConstraint_IsZero(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, Constraint{}.IsZero(), "zero Constraint is not zero")
+	assert.True(t, Constraint{}.Check(mustVersion("1.0")), "zero Constraint doesn't match")
+	assert.True(t, MustConstraint("").IsZero(), "empty Constraint is not zero")
+	assert.False(t, MustConstraint("^1").IsZero(), "Constraint is zero")
+	assert.Panics(t, func() { MustConstraint("bob") }, "invalid Constraint didn't panic")
+}
+
+func TestUpdaterConstraint(t *testing.T) {
+	t.Parallel()
+
+	src := &testSource{dls: []Download{
+		{Version: mustVersion("3.0.0"), Filename: "Dummy.alfredworkflow"},
+		{Version: mustVersion("2.5.0-beta"), Prerelease: true, Filename: "Dummy.alfredworkflow"},
+		{Version: mustVersion("2.4.1"), Filename: "Dummy.alfredworkflow"},
+		{Version: mustVersion("2.4.0"), Filename: "Dummy.alfredworkflow"},
+		{Version: mustVersion("2.1.0"), Filename: "Dummy.alfredworkflow"},
+	}}
+
+	tests := []struct {
+		constraint  string
+		prereleases bool
+		x           string // expected latest version or "" for none
+	}{
+		{"", false, "3.0.0"},
+		{"^2", false, "2.4.1"},
+		{"^2", true, "2.5.0-beta"},
+		{"^2, !=2.4.1", false, "2.4.0"},
+		{"~2.1", false, "2.1.0"},
+		{"<2", false, ""},
+		{">=2.2, <3, !=2.4.x", false, ""},
+		{">=2.2, <3, !=2.4.x", true, "2.5.0-beta"},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%q", td.constraint), func(t *testing.T) {
+			t.Parallel()
+			// cache isn't used, as downloads are set
+			u, err := NewUpdater(src, "2.0", "unused")
+			require.Nil(t, err, "create updater")
+			VersionConstraint(MustConstraint(td.constraint))(u)
+			u.Prereleases = td.prereleases
+			u.downloads = src.dls
+
+			dl := u.latest()
+			if td.x == "" {
+				assert.Nil(t, dl, "unexpected download")
+				assert.False(t, u.UpdateAvailable(), "unexpected update")
+				return
+			}
+			require.NotNil(t, dl, "no download")
+			assert.Equal(t, td.x, dl.Version.String(), "unexpected version")
+			assert.True(t, u.UpdateAvailable(), "no update")
+		})
+	}
+}
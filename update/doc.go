@@ -5,7 +5,8 @@
 Package update implements an API for fetching workflow updates from remote servers.
 
 It is the "backend" for aw.Workflow's update API, and provides concrete updaters for
-GitHub and Gitea releases, and Alfred metadata.json files (as aw.Options). Updater
+GitHub, Gitea and GitLab releases, Alfred metadata.json files, and JSON, RSS and
+Atom feeds (as aw.Options). Updater
 implements aw.Updater and you can create a custom Updater to use with
 aw.Workflow/aw.Update() by passing a custom implementation of Source to NewUpdater().
 
@@ -16,6 +17,40 @@ Updater is also Alfred-version-aware, and ignores incompatible workflow version,
 e.g. workflow files with the extension ".alfred4workflow" are ignored
 when Updater is run in Alfred 3.
 
+Downloads may carry a SHA-256 digest, read from a checksums file in a
+GitHub/Gitea release or the metadata.json file, and an ed25519 signature.
+Install refuses to install a file that doesn't match its digest, and if
+Updater.PublicKey is set (e.g. with the PublicKey option), any file that
+isn't signed with the corresponding private key:
+
+	wf := aw.New(update.GitHub("deanishe/alfred-ssh", update.PublicKey(key)))
+
+To update from private repos, pass a token with the Token, TokenFromConfig
+or TokenFromKeychain options, and custom headers with the Header option.
+Credentials are only sent to the host of the release list, unless other
+hosts are added with the CredentialHosts option.
+Release lists are cached and fetched with conditional requests, so checking
+for an unchanged list doesn't count against API rate limits.
+
+To restrict updates to a range of versions, e.g. to stay on the current
+major version, set Updater.Constraint with the VersionConstraint option:
+
+	wf := aw.New(update.GitHub("deanishe/alfred-ssh",
+		update.VersionConstraint(update.MustConstraint("^2.1"))))
+
+Releases are published in channels, by default "stable", "beta" and
+"nightly", which are derived from pre-release version numbers (e.g.
+"2.0-beta.1") or set by the Source. Use ChannelFromConfig to let users
+choose their channel with a workflow variable, and Channels to define
+your own. A release may be rolled out to a fraction of installations,
+e.g. with a "Rollout: 25%" line in the description of a GitHub release.
+A rollout of 0% or an invalid rollout withholds the release from all
+installations. Which installations get it is decided by the workflow's
+UID, so each installation gets the same answer every time it checks:
+
+	wf := aw.New(update.GitHub("deanishe/alfred-ssh",
+		update.ChannelFromConfig("UPDATE_CHANNEL")))
+
 See ../_examples/update for one possible way to using the updater API.
 */
 package update
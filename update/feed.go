@@ -0,0 +1,289 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	aw "github.com/ChicK00o/awgo"
+)
+
+// Feed is a Workflow Option. It sets a Workflow Updater that reads releases
+// from a FeedSource.
+//
+//	wf := aw.New(update.Feed(&update.FeedSource{URL: "https://example.com/releases.json"}))
+func Feed(src *FeedSource, opts ...UpdaterOption) aw.Option {
+	return newOption(src, opts...)
+}
+
+// FeedSource is a Source that reads releases from a JSON feed, such as a
+// static file on a web server, or from an RSS or Atom feed, such as a
+// Sparkle appcast. The type of feed is detected from its contents.
+//
+// A JSON feed is an array of releases, one per workflow file. The fields of
+// FeedSource are the paths of the corresponding values in the JSON. A path
+// is a sequence of keys separated by dots, e.g. "data.releases". The
+// default feed looks like this:
+//
+//	[
+//		{
+//			"version": "1.2.0",
+//			"url": "https://example.com/Workflow-1.2.0.alfredworkflow",
+//			"prerelease": false,
+//			"sha256": "(optional) hex-encoded SHA-256 digest of file",
+//			"signature": "(optional) base64-encoded ed25519 signature of file",
+//			"channel": "(optional) release channel, e.g. beta",
+//			"rollout": "(optional) staged rollout, e.g. 25% or 0.25"
+//		}
+//	]
+//
+// In an RSS or Atom feed, the workflow file is an item's enclosure. Its
+// version is read from Sparkle's shortVersionString or version, or
+// the item's title, and its signature from Sparkle's edSignature. Items in
+// a Sparkle channel are pre-releases in that release channel.
+//
+// In both kinds of feed, releases with a pre-release version number
+// (e.g. "1.2.0-beta") are also pre-releases.
+type FeedSource struct {
+	URL string // Location of feed
+
+	// Paths of values in a JSON feed
+	Releases   string // Array of releases. Default: top-level array
+	Version    string // Version number. Default: "version"
+	Download   string // URL of workflow file. Default: "url"
+	Prerelease string // Whether release is a pre-release. Default: "prerelease"
+	SHA256     string // Digest of workflow file. Default: "sha256"
+	Signature  string // Signature of workflow file. Default: "signature"
+	Channel    string // Release channel. Default: "channel"
+	Rollout    string // Staged rollout. Default: "rollout"
+
+	src     *source
+	fetcher *fetcher
+}
+
+// setFetcher implements httpSource.
+func (f *FeedSource) setFetcher(ft *fetcher) {
+	ft.trust(f.URL)
+	f.fetcher = ft
+}
+
+// Downloads implements Source.
+func (f *FeedSource) Downloads() ([]Download, error) {
+	if f.src == nil {
+		f.src = &source{URL: f.URL, fetch: getURL, parse: f.parse}
+		if f.fetcher != nil {
+			f.src.setFetcher(f.fetcher)
+		}
+	}
+	return f.src.Downloads()
+}
+
+// resolve implements resolver.
+func (f *FeedSource) resolve(dl Download) (Download, error) {
+	if f.src == nil {
+		return dl, nil
+	}
+	return f.src.resolve(dl)
+}
+
+// feedItem is a workflow file in a feed.
+type feedItem struct {
+	version    string
+	URL        string
+	prerelease bool
+	sha256     string
+	signature  string
+	channel    string
+	rollout    *float64
+}
+
+// parse JSON or XML feed.
+func (f *FeedSource) parse(data []byte) ([]releaseFile, error) {
+	var (
+		items []feedItem
+		err   error
+	)
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '<' {
+		items, err = parseXMLFeed(data)
+	} else {
+		items, err = f.parseJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return feedReleaseFiles(items), nil
+}
+
+// parseJSON reads items from a JSON feed.
+func (f *FeedSource) parseJSON(js []byte) ([]feedItem, error) {
+	var root interface{}
+	if err := json.Unmarshal(js, &root); err != nil {
+		return nil, err
+	}
+	v, _ := jsonValue(root, f.Releases)
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no array of releases at %q", f.Releases)
+	}
+
+	var items []feedItem
+	for _, obj := range a {
+		it := feedItem{
+			version:   jsonString(obj, fieldPath(f.Version, "version")),
+			URL:       jsonString(obj, fieldPath(f.Download, "url")),
+			sha256:    jsonString(obj, fieldPath(f.SHA256, "sha256")),
+			signature: jsonString(obj, fieldPath(f.Signature, "signature")),
+			channel:   jsonString(obj, fieldPath(f.Channel, "channel")),
+			rollout:   parseRollout(jsonString(obj, fieldPath(f.Rollout, "rollout"))),
+		}
+		it.prerelease, _ = strconv.ParseBool(jsonString(obj, fieldPath(f.Prerelease, "prerelease")))
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// data model of RSS and Atom feeds. Sparkle's elements and attributes are
+// matched regardless of namespace.
+type xmlFeed struct {
+	Items   []xmlItem `xml:"channel>item"` // RSS
+	Entries []xmlItem `xml:"entry"`        // Atom
+}
+
+type xmlItem struct {
+	Title        string `xml:"title"`
+	Version      string `xml:"version"`
+	ShortVersion string `xml:"shortVersionString"`
+	Channel      string `xml:"channel"`
+	Enclosure    struct {
+		URL          string `xml:"url,attr"`
+		Version      string `xml:"version,attr"`
+		ShortVersion string `xml:"shortVersionString,attr"`
+		Signature    string `xml:"edSignature,attr"`
+	} `xml:"enclosure"`
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+}
+
+// parseXMLFeed reads items from an RSS or Atom feed.
+func parseXMLFeed(data []byte) ([]feedItem, error) {
+	var feed xmlFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, err
+	}
+
+	var items []feedItem
+	for _, x := range append(feed.Items, feed.Entries...) {
+		it := feedItem{
+			URL:        x.Enclosure.URL,
+			signature:  x.Enclosure.Signature,
+			channel:    strings.TrimSpace(x.Channel),
+			prerelease: x.Channel != "",
+		}
+		for _, l := range x.Links {
+			if it.URL == "" && l.Rel == "enclosure" {
+				it.URL = l.Href
+			}
+		}
+		for _, s := range []string{x.Enclosure.ShortVersion, x.ShortVersion, x.Enclosure.Version, x.Version} {
+			if s = strings.TrimSpace(s); s != "" {
+				it.version = s
+				break
+			}
+		}
+		// e.g. "Version 1.2.0"
+		if fields := strings.Fields(x.Title); it.version == "" && len(fields) > 0 {
+			it.version = fields[len(fields)-1]
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// feedReleaseFiles groups items by version and returns the workflow files
+// of valid releases.
+func feedReleaseFiles(items []feedItem) []releaseFile {
+	var (
+		rels  []release
+		index = map[string]int{}
+	)
+	for _, it := range items {
+		if it.version == "" || it.URL == "" {
+			log.Printf("ignored feed item: missing version or URL: %+v", it)
+			continue
+		}
+		i, ok := index[it.version]
+		if !ok {
+			i = len(rels)
+			index[it.version] = i
+			rels = append(rels, release{Tag: it.version})
+			if v, err := NewSemVer(it.version); err == nil {
+				rels[i].Prerelease = v.Prerelease != ""
+			}
+		}
+		rels[i].Prerelease = rels[i].Prerelease || it.prerelease
+		if it.channel != "" {
+			rels[i].Channel = it.channel
+		}
+		if it.rollout != nil {
+			rels[i].Rollout = it.rollout
+		}
+		rels[i].Assets = append(rels[i].Assets, asset{
+			Name:      assetName("", it.URL),
+			URL:       it.URL,
+			SHA256:    it.sha256,
+			Signature: it.signature,
+		})
+	}
+	return releaseFiles(rels)
+}
+
+// fieldPath returns path or the default path.
+func fieldPath(path, fallback string) string {
+	if path == "" {
+		return fallback
+	}
+	return path
+}
+
+// jsonValue returns the value at path in decoded JSON v.
+func jsonValue(v interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// jsonString returns the value at path in decoded JSON v as a string.
+func jsonString(v interface{}, path string) string {
+	v, ok := jsonValue(v, path)
+	if !ok {
+		return ""
+	}
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	aw "github.com/ChicK00o/awgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedSource(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		file string
+		src  FeedSource
+		x    []Download
+	}{
+		{
+			"JSON",
+			"testdata/feed.json",
+			FeedSource{Releases: "data.releases", Version: "tag", Download: "file.url", Prerelease: "beta"},
+			[]Download{
+				{URL: "https://example.com/Dummy-2.0.0-beta.alfredworkflow", Filename: "Dummy-2.0.0-beta.alfredworkflow", Version: mustVersion("2.0.0-beta"), Prerelease: true},
+				{URL: "https://example.com/Dummy-1.1.alfred4workflow", Filename: "Dummy-1.1.alfred4workflow", Version: mustVersion("1.1"), Prerelease: true},
+				{URL: "https://example.com/Dummy-1.0.alfred4workflow", Filename: "Dummy-1.0.alfred4workflow", Version: mustVersion("1.0")},
+				{URL: "https://example.com/Dummy-1.0.alfredworkflow", Filename: "Dummy-1.0.alfredworkflow", Version: mustVersion("1.0")},
+			},
+		},
+		{
+			"RSS",
+			"testdata/appcast.xml",
+			FeedSource{},
+			[]Download{
+				{URL: "https://example.com/Dummy-3.0b1.alfredworkflow", Filename: "Dummy-3.0b1.alfredworkflow", Version: mustVersion("3.0.0-b1"), Prerelease: true, Channel: "beta"},
+				{URL: "https://example.com/Dummy-2.1.alfredworkflow", Filename: "Dummy-2.1.alfredworkflow", Version: mustVersion("2.1")},
+				{URL: "https://example.com/Dummy-2.0.alfredworkflow", Filename: "Dummy-2.0.alfredworkflow", Version: mustVersion("2.0")},
+			},
+		},
+		{
+			"Atom",
+			"testdata/releases.atom",
+			FeedSource{},
+			[]Download{
+				{URL: "https://example.com/downloads/Dummy-2.0-rc1.alfredworkflow", Filename: "Dummy-2.0-rc1.alfredworkflow", Version: mustVersion("v2.0-rc1"), Prerelease: true},
+				{URL: "https://example.com/downloads/Dummy-1.5.alfredworkflow", Filename: "Dummy-1.5.alfredworkflow", Version: mustVersion("v1.5")},
+			},
+		},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			t.Parallel()
+			ts, _ := serveFile(td.file)
+			defer ts.Close()
+
+			src := td.src
+			src.URL = ts.URL
+			dls, err := src.Downloads()
+			require.Nil(t, err, "read feed")
+			assert.Equal(t, td.x, dls, "unexpected downloads")
+		})
+	}
+}
+
+func TestFeedSourceDefaults(t *testing.T) {
+	t.Parallel()
+
+	var (
+		digest = testDigest(testFileData)
+		sig    = testSignature(testPrivateKey, testFileData)
+		js     = fmt.Sprintf(`[
+			{"version": "1.0", "url": "https://example.com/Dummy.alfredworkflow", "sha256": %q, "signature": %q},
+			{"version": "2.0", "url": "https://example.com/Dummy-2.alfredworkflow", "prerelease": true, "channel": "nightly", "rollout": "25%%"},
+			{"version": "3.0", "url": "https://example.com/Dummy-3.alfredworkflow", "sha256": "invalid"}
+		]`, digest, sig)
+		f = &FeedSource{}
+	)
+
+	files, err := f.parse([]byte(js))
+	require.Nil(t, err, "parse feed")
+	require.Equal(t, 2, len(files), "unexpected downloads")
+	assert.Equal(t, mustVersion("2.0"), files[0].Version, "unexpected version")
+	assert.True(t, files[0].Prerelease, "not a pre-release")
+	assert.Equal(t, "nightly", files[0].Channel, "unexpected channel")
+	assert.Equal(t, rollout(0.25), files[0].Rollout, "unexpected rollout")
+	assert.Equal(t, digest, files[1].SHA256, "unexpected digest")
+	assert.Equal(t, sig, files[1].Signature, "unexpected signature")
+
+	// signature in appcast
+	xml := fmt.Sprintf(`<rss><channel><item><title>1.0</title>
+		<enclosure url="https://example.com/Dummy.alfredworkflow" edSignature="%s"/>
+		</item></channel></rss>`, sig)
+	files, err = f.parse([]byte(xml))
+	require.Nil(t, err, "parse appcast")
+	require.Equal(t, 1, len(files), "unexpected downloads")
+	_, err = base64.StdEncoding.DecodeString(files[0].Signature)
+	assert.Nil(t, err, "invalid signature")
+	assert.Equal(t, sig, files[0].Signature, "unexpected signature")
+
+	for _, s := range []string{`{}`, `{"releases": []}`, `[`, `<rss>`} {
+		_, err := f.parse([]byte(s))
+		assert.NotNil(t, err, "invalid feed accepted: %s", s)
+	}
+}
+
+// Feed configures Workflow to read releases from a feed with the Updater's
+// headers. The type of feed is detected from its contents.
+func TestFeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		src    FeedSource
+		latest string
+	}{
+		{"JSON", "testdata/feed.json", FeedSource{Releases: "data.releases", Version: "tag", Download: "file.url", Prerelease: "beta"}, "2.0.0-beta"},
+		{"RSS", "testdata/appcast.xml", FeedSource{}, "3.0.0-b1"},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(td.file)
+			require.Nil(t, err, "read feed")
+
+			var (
+				mu      sync.Mutex
+				headers []http.Header
+			)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				headers = append(headers, r.Header)
+				mu.Unlock()
+				// content type doesn't match either kind of feed
+				w.Header().Set("Content-Type", "application/octet-stream")
+				w.Write(data) // nolint: errcheck
+			}))
+			defer ts.Close()
+
+			src := td.src
+			src.URL = ts.URL + "/feed"
+			aw.New(Feed(&src, Header("X-Test", td.name)))
+			require.NotNil(t, src.fetcher, "fetcher not set")
+
+			dls, err := src.Downloads()
+			require.Nil(t, err, "read feed")
+			require.NotEqual(t, 0, len(dls), "no downloads")
+			assert.Equal(t, td.latest, dls[0].Version.String(), "unexpected latest version")
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.Equal(t, 1, len(headers), "unexpected requests")
+			assert.Equal(t, td.name, headers[0].Get("X-Test"), "header not sent")
+		})
+	}
+}
+
+// Configure Workflow to update from a JSON feed.
+func ExampleFeed() {
+	wf := aw.New(Feed(&FeedSource{URL: "https://example.com/releases.json"}))
+	// Is a check for a newer version due?
+	fmt.Println(wf.UpdateCheckDue())
+	// Output:
+	// true
+}
@@ -21,25 +21,56 @@ var rxWorkflowFile = regexp.MustCompile(`\.alfred(\d+)?workflow$`)
 
 // GitHub is a Workflow Option. It sets a Workflow Updater for the specified GitHub repo.
 // Repo name should be of the form "username/repo", e.g. "deanishe/alfred-ssh".
-func GitHub(repo string) aw.Option {
+//
+// If a release contains a checksums file (e.g. "checksums.txt" or
+// "SHA256SUMS" in the format of sha256sum) or a "<file>.sha256" file, the
+// Download's SHA256 is read from it. Its Signature is read from a
+// "<file>.sig" file, which contains a raw or base64-encoded ed25519
+// signature. These files are only fetched for the Download that is
+// installed.
+//
+// A release is rolled out to a fraction of installations if its
+// description contains a line such as "Rollout: 25%".
+func GitHub(repo string, opts ...UpdaterOption) aw.Option {
 	return newOption(&source{
 		URL:   "https://api.github.com/repos/" + repo + "/releases",
 		fetch: getURL,
-	})
+	}, opts...)
 }
 
+// UpdaterOption configures the Updater created by GitHub, Gitea or
+// Metadata.
+type UpdaterOption func(u *Updater)
+
 // create new Updater option from Source.
-func newOption(src Source) aw.Option {
+func newOption(src Source, opts ...UpdaterOption) aw.Option {
 	return func(wf *aw.Workflow) aw.Option {
 		u, _ := NewUpdater(src, wf.Version(), filepath.Join(wf.CacheDir(), "_aw/update"))
+		if u != nil {
+			for _, opt := range opts {
+				opt(u)
+			}
+			if s, ok := src.(httpSource); ok {
+				s.setFetcher(u.fetcher)
+			}
+		}
 		return aw.Update(u)(wf)
 	}
 }
 
 type source struct {
-	URL   string
-	dls   []Download
-	fetch func(URL string) ([]byte, error)
+	URL     string
+	dls     []Download
+	fetch   func(URL string) ([]byte, error)
+	parse   func(js []byte) ([]releaseFile, error) // Default: parseReleaseFiles
+	fetcher *fetcher                               // Set by newOption
+}
+
+// setFetcher implements httpSource.
+func (src *source) setFetcher(f *fetcher) {
+	f.trust(src.URL)
+	src.fetcher = f
+	src.fetch = f.get
 }
 
 // Downloads implements Source.
@@ -48,37 +79,82 @@ func (src *source) Downloads() ([]Download, error) {
 		return src.dls, nil
 	}
 
-	src.dls = []Download{}
 	js, err := src.fetch(src.URL)
 	if err != nil {
 		return nil, err
 	}
-	if src.dls, err = parseReleases(js); err != nil {
+	parse := src.parse
+	if parse == nil {
+		parse = parseReleaseFiles
+	}
+	files, err := parse(js)
+	if err != nil {
 		return nil, err
 	}
+	dls := make([]Download, len(files))
+	for i, f := range files {
+		dls[i] = f.Download
+		// "browser" URLs don't accept credentials
+		if f.apiURL != "" && src.fetcher.authenticated(f.apiURL) {
+			dls[i].URL = f.apiURL
+		}
+		// fetched by resolve when the Download is installed
+		if f.checksums.URL != "" {
+			dls[i].ChecksumsURL = src.assetURL(f.checksums)
+		}
+		if f.signature.URL != "" {
+			dls[i].SignatureURL = src.assetURL(f.signature)
+		}
+	}
+	src.dls = dls
 
 	return src.dls, nil
 }
 
-// parse GitHub/Gitea releases JSON.
-func parseReleases(js []byte) ([]Download, error) {
-	var (
-		dls  = []Download{}
-		rels = []struct {
-			Name       string `json:"name"`
-			Prerelease bool   `json:"prerelease"`
-			Assets     []struct {
-				Name             string `json:"name"`
-				URL              string `json:"browser_download_url"`
-				MinAlfredVersion SemVer `json:"-"`
-			} `json:"assets"`
-			Tag string `json:"tag_name"`
-		}{}
-	)
+// releaseFile is a workflow file in a GitHub/Gitea release and the
+// release's files that contain its digest and signature.
+type releaseFile struct {
+	Download
+	apiURL    string // API URL of workflow file
+	checksums asset  // Checksums file or "<file>.sha256"
+	signature asset  // "<file>.sig"
+}
+
+// release is a release of a workflow with one or more files. It is also
+// the data model of GitHub/Gitea releases JSON.
+type release struct {
+	Tag        string   `json:"tag_name"`
+	Prerelease bool     `json:"prerelease"`
+	Body       string   `json:"body"`
+	Assets     []asset  `json:"assets"`
+	Channel    string   `json:"-"` // Release channel, if Source provides it
+	Rollout    *float64 `json:"-"` // Staged rollout, if Source provides it
+}
 
+// asset is a file in a release.
+type asset struct {
+	Name      string `json:"name"`
+	URL       string `json:"browser_download_url"`
+	APIURL    string `json:"url"` // GitHub only
+	SHA256    string `json:"-"`   // Digest of file, if Source provides it
+	Signature string `json:"-"`   // Signature of file, if Source provides it
+}
+
+// parse GitHub/Gitea releases JSON.
+func parseReleaseFiles(js []byte) ([]releaseFile, error) {
+	rels := []release{}
 	if err := json.Unmarshal(js, &rels); err != nil {
 		return nil, err
 	}
+	for i, r := range rels {
+		rels[i].Rollout = rolloutNote(r.Body)
+	}
+	return releaseFiles(rels), nil
+}
+
+// releaseFiles returns the workflow files in valid releases, newest first.
+func releaseFiles(rels []release) []releaseFile {
+	var files []releaseFile
 	for _, r := range rels {
 		if len(r.Assets) == 0 {
 			continue
@@ -88,11 +164,18 @@ func parseReleases(js []byte) ([]Download, error) {
 			log.Printf("ignored release %s: not semantic: %v", r.Tag, err)
 			continue
 		}
-		var all []Download
+		var (
+			all       []Download
+			checksums asset
+			assets    = map[string]asset{}
+		)
 		for _, a := range r.Assets {
+			assets[a.Name] = a
+			if rxChecksumsFile.MatchString(a.Name) {
+				checksums = a
+			}
 			m := rxWorkflowFile.FindStringSubmatch(a.Name)
 			if len(m) != 2 {
-				log.Printf("ignored release %s: no workflow files", r.Tag)
 				continue
 			}
 			w := Download{
@@ -100,17 +183,55 @@ func parseReleases(js []byte) ([]Download, error) {
 				Filename:   a.Name,
 				Version:    v,
 				Prerelease: r.Prerelease,
+				Channel:    r.Channel,
+				Rollout:    r.Rollout,
+			}
+			if a.SHA256 != "" {
+				if w.SHA256, err = parseDigest(a.SHA256); err != nil {
+					log.Printf("ignored file %s: %v", a.Name, err)
+					continue
+				}
+			}
+			if a.Signature != "" {
+				if w.Signature, err = parseSignature([]byte(a.Signature)); err != nil {
+					log.Printf("ignored file %s: %v", a.Name, err)
+					continue
+				}
 			}
 			all = append(all, w)
 		}
+		if len(all) == 0 {
+			log.Printf("ignored release %s: no workflow files", r.Tag)
+			continue
+		}
 		if err := isValidRelease(all); err != nil {
 			log.Printf("ignored release %s: %v", r.Tag, err)
 			continue
 		}
-		dls = append(dls, all...)
+		for _, dl := range all {
+			f := releaseFile{
+				Download:  dl,
+				apiURL:    assets[dl.Filename].APIURL,
+				checksums: checksums,
+				signature: assets[dl.Filename+".sig"],
+			}
+			if a, ok := assets[dl.Filename+".sha256"]; ok {
+				f.checksums = a
+			}
+			if dl.SHA256 != "" {
+				f.checksums = asset{}
+			}
+			if dl.Signature != "" {
+				f.signature = asset{}
+			}
+			files = append(files, f)
+		}
 	}
-	sort.Sort(sort.Reverse(byVersion(dls)))
-	return dls, nil
+	// newest first
+	sort.SliceStable(files, func(i, j int) bool {
+		return versionLess(files[j].Download, files[i].Download)
+	})
+	return files
 }
 
 // Reject releases that are empty or contain multiple files with the same extension.
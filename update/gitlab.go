@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+
+	aw "github.com/ChicK00o/awgo"
+)
+
+// GitLab is a Workflow Option. It sets a Workflow Updater for the specified GitLab project.
+// Project name should be the URL of the project, e.g. "gitlab.com/deanishe/alfred-ssh".
+// Projects in subgroups, e.g. "gitlab.example.com/group/subgroup/project", are supported.
+//
+// Workflow files are read from the release's asset links. As GitLab releases
+// have no pre-release flag, releases with a pre-release version number
+// (e.g. "v1.0-beta") are pre-releases, and upcoming releases are ignored.
+// Checksums, signatures and rollouts are read as for GitHub.
+func GitLab(project string, opts ...UpdaterOption) aw.Option {
+	return newOption(&source{URL: gitlabURL(project), fetch: getURL, parse: parseGitLabReleases}, opts...)
+}
+
+func gitlabURL(project string) string {
+	if project == "" {
+		return ""
+	}
+	u, err := url.Parse(project)
+	if err != nil {
+		return ""
+	}
+	// If no scheme is specified, assume HTTPS and re-parse URL.
+	if u.Scheme == "" {
+		u, err = url.Parse("https://" + project)
+		if err != nil {
+			return ""
+		}
+	}
+	if u.Host == "" {
+		return ""
+	}
+	p := strings.Trim(u.Path, "/")
+	if len(strings.Split(p, "/")) < 2 {
+		return ""
+	}
+
+	// project path is a single, URL-encoded segment
+	return fmt.Sprintf("%s://%s/api/v4/projects/%s/releases", u.Scheme, u.Host, url.PathEscape(p))
+}
+
+// parse GitLab releases JSON.
+func parseGitLabReleases(js []byte) ([]releaseFile, error) {
+	var (
+		rels []release
+		data = []struct {
+			Tag         string `json:"tag_name"`
+			Upcoming    bool   `json:"upcoming_release"`
+			Description string `json:"description"`
+			Assets      struct {
+				Links []struct {
+					Name      string `json:"name"`
+					URL       string `json:"url"`
+					DirectURL string `json:"direct_asset_url"`
+				} `json:"links"`
+			} `json:"assets"`
+		}{}
+	)
+	if err := json.Unmarshal(js, &data); err != nil {
+		return nil, err
+	}
+
+	for _, r := range data {
+		if r.Upcoming {
+			log.Printf("ignored release %s: upcoming", r.Tag)
+			continue
+		}
+		rel := release{Tag: r.Tag, Rollout: rolloutNote(r.Description)}
+		if v, err := NewSemVer(r.Tag); err == nil {
+			rel.Prerelease = v.Prerelease != ""
+		}
+		for _, l := range r.Assets.Links {
+			URL := l.DirectURL
+			if URL == "" {
+				URL = l.URL
+			}
+			rel.Assets = append(rel.Assets, asset{Name: assetName(l.Name, URL), URL: URL})
+		}
+		rels = append(rels, rel)
+	}
+	return releaseFiles(rels), nil
+}
+
+// assetName returns the filename of an asset. If name isn't a workflow,
+// checksums or signature file, the filename is read from URL.
+func assetName(name, URL string) string {
+	if isAssetFile(name) {
+		return name
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		return name
+	}
+	if s := path.Base(u.Path); isAssetFile(s) {
+		return s
+	}
+	return name
+}
+
+// isAssetFile returns true if name is a file that releaseFiles reads.
+func isAssetFile(name string) bool {
+	return rxWorkflowFile.MatchString(name) ||
+		rxChecksumsFile.MatchString(name) ||
+		strings.HasSuffix(name, ".sig") ||
+		strings.HasSuffix(name, ".sha256")
+}
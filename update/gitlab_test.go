@@ -0,0 +1,129 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	aw "github.com/ChicK00o/awgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 2 valid releases: v10.0-beta and v9.0 (Alfred 4+ only)
+// v11.0 is upcoming, v8.0 has two .alfredworkflow files and "latest" isn't semantic
+var testGitLabDownloads = []Download{
+	{
+		URL:        "https://gitlab.com/deanishe/alfred-workflow-dummy/-/releases/v10.0-beta/downloads/Dummy-10.0-beta.alfredworkflow",
+		Filename:   "Dummy-10.0-beta.alfredworkflow",
+		Version:    mustVersion("v10.0-beta"),
+		Prerelease: true,
+	},
+	{
+		URL:        "https://gitlab.com/deanishe/alfred-workflow-dummy/uploads/abc123/Dummy-9.0.alfred4workflow",
+		Filename:   "Dummy-9.0.alfred4workflow",
+		Version:    mustVersion("v9.0"),
+		Prerelease: false,
+	},
+	{
+		URL:        "https://gitlab.com/deanishe/alfred-workflow-dummy/uploads/def456/Dummy-9.0.alfredworkflow",
+		Filename:   "Dummy-9.0.alfredworkflow",
+		Version:    mustVersion("v9.0"),
+		Prerelease: false,
+	},
+}
+
+// serveFile returns a test server that serves file and the URL path of the last request.
+func serveFile(file string) (*httptest.Server, *string) {
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.RequestURI()
+		http.ServeFile(w, r, file)
+	}))
+	return ts, &path
+}
+
+func TestParseGitLab(t *testing.T) {
+	t.Parallel()
+
+	ts, path := serveFile("testdata/gitlab-releases.json")
+	defer ts.Close()
+
+	src := &source{URL: gitlabURL(ts.URL + "/deanishe/alfred-workflow-dummy"), fetch: getURL, parse: parseGitLabReleases}
+	dls, err := src.Downloads()
+	require.Nil(t, err, "parse GitLab JSON")
+	assert.Equal(t, "/api/v4/projects/deanishe%2Falfred-workflow-dummy/releases", *path, "unexpected API path")
+	assert.Equal(t, testGitLabDownloads, dls, "GitLab downloads not equal")
+
+	src = &source{URL: ts.URL, fetch: func(URL string) ([]byte, error) { return []byte("[]"), nil }, parse: parseGitLabReleases}
+	dls, err = src.Downloads()
+	require.Nil(t, err, "parse empty JSON")
+	assert.Equal(t, 0, len(dls), "downloads in empty JSON")
+
+	src = &source{URL: ts.URL, fetch: func(URL string) ([]byte, error) { return []byte("{"), nil }, parse: parseGitLabReleases}
+	_, err = src.Downloads()
+	assert.NotNil(t, err, "invalid JSON accepted")
+}
+
+func TestGitLabURL(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		project string
+		url     string
+	}{
+		// Invalid input
+		{"", ""},
+		{"gitlab.com/deanishe", ""},
+		{"https://gitlab.com", ""},
+		// Valid URLs
+		{"gitlab.com/deanishe/alfred-ssh", "https://gitlab.com/api/v4/projects/deanishe%2Falfred-ssh/releases"},
+		{"https://gitlab.com/deanishe/alfred-ssh/", "https://gitlab.com/api/v4/projects/deanishe%2Falfred-ssh/releases"},
+		{"http://gitlab.example.com:8080/group/sub/project", "http://gitlab.example.com:8080/api/v4/projects/group%2Fsub%2Fproject/releases"},
+	}
+
+	for _, td := range data {
+		td := td
+		t.Run(td.project, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, td.url, gitlabURL(td.project), "unexpected URL")
+		})
+	}
+}
+
+func TestAssetName(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		name, url, x string
+	}{
+		{"Dummy.alfredworkflow", "https://example.com/download", "Dummy.alfredworkflow"},
+		{"Workflow", "https://example.com/downloads/Dummy.alfred4workflow", "Dummy.alfred4workflow"},
+		{"SHA-256 digests", "https://example.com/downloads/SHA256SUMS", "SHA256SUMS"},
+		{"Signature", "https://example.com/downloads/Dummy.alfredworkflow.sig", "Dummy.alfredworkflow.sig"},
+		{"Source code", "https://example.com/downloads/source.zip", "Source code"},
+		{"", "https://example.com/downloads/Dummy.alfredworkflow?token=x", "Dummy.alfredworkflow"},
+	}
+
+	for _, td := range data {
+		td := td
+		t.Run(td.url, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, td.x, assetName(td.name, td.url), "unexpected name")
+		})
+	}
+}
+
+// Configure Workflow to update from a GitLab project.
+func ExampleGitLab() {
+	// Set source project using GitLab Option
+	wf := aw.New(GitLab("gitlab.com/deanishe/alfred-ssh"))
+	// Is a check for a newer version due?
+	fmt.Println(wf.UpdateCheckDue())
+	// Output:
+	// true
+}
@@ -19,14 +19,12 @@ import (
 // URL is the location of the `metadata.json` file. Note: You *must*
 // set `downloadurl` in the `metadata.json` file to the URL
 // of your .alfredworkflow (or .alfred4workflow etc.) file.
-func Metadata(url string) aw.Option {
-	return func(wf *aw.Workflow) aw.Option {
-		u, _ := NewUpdater(&metadataSource{url: url, fetch: getURL},
-			wf.Version(),
-			filepath.Join(wf.CacheDir(), "_aw/update"),
-		)
-		return aw.Update(u)(wf)
-	}
+//
+// The optional `sha256` and `signature` fields set the Download's hex-encoded
+// SHA-256 digest and base64-encoded ed25519 signature, `channel` its release
+// channel, and `rollout` its staged rollout (e.g. "25%" or 0.25).
+func Metadata(url string, opts ...UpdaterOption) aw.Option {
+	return newOption(&metadataSource{url: url, fetch: getURL}, opts...)
 }
 
 type metadataSource struct {
@@ -35,6 +33,12 @@ type metadataSource struct {
 	fetch func(URL string) ([]byte, error)
 }
 
+// setFetcher implements httpSource.
+func (src *metadataSource) setFetcher(f *fetcher) {
+	f.trust(src.url)
+	src.fetch = f.get
+}
+
 // Downloads implements Source.
 func (src *metadataSource) Downloads() ([]Download, error) {
 	if src.dl == nil {
@@ -57,8 +61,12 @@ func (src *metadataSource) Downloads() ([]Download, error) {
 // data model for metadata.json JSON.
 type metadataRelease struct {
 	Data struct {
-		URL     string `json:"downloadurl"`
-		Version string `json:"version"`
+		URL       string      `json:"downloadurl"`
+		Version   string      `json:"version"`
+		SHA256    string      `json:"sha256"`
+		Signature string      `json:"signature"`
+		Channel   string      `json:"channel"`
+		Rollout   interface{} `json:"rollout"` // Number or percentage string
 	} `json:"alfredworkflow"`
 }
 
@@ -95,6 +103,18 @@ func parseMetadata(data []byte) (Download, error) {
 	if len(m) != 2 {
 		return dl, errors.New("not a workflow file")
 	}
+	if rel.Data.SHA256 != "" {
+		if dl.SHA256, err = parseDigest(rel.Data.SHA256); err != nil {
+			return dl, err
+		}
+	}
+	if rel.Data.Signature != "" {
+		if dl.Signature, err = parseSignature([]byte(rel.Data.Signature)); err != nil {
+			return dl, err
+		}
+	}
+	dl.Channel = rel.Data.Channel
+	dl.Rollout = parseRollout(jsonString(rel.Data.Rollout, ""))
 
 	return dl, nil
 }
@@ -0,0 +1,310 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	aw "github.com/ChicK00o/awgo"
+	"github.com/ChicK00o/awgo/keychain"
+	"github.com/ChicK00o/awgo/util"
+)
+
+// subdirectory of Updater's cache directory for HTTP responses.
+const responseCacheDir = "_responses"
+
+// Token is an UpdaterOption that sends token as a bearer token with requests
+// for releases and workflow files. Like custom headers, the token is only
+// sent to the Source's host and any hosts added with CredentialHosts.
+//
+// With a token, the GitHub source downloads files via the API, as
+// "browser" download URLs don't accept tokens.
+func Token(token string) UpdaterOption {
+	return func(u *Updater) {
+		u.fetcher.token = func() (string, error) { return token, nil }
+	}
+}
+
+// TokenFromConfig is an UpdaterOption that reads the bearer token from
+// the workflow variable name when a request is made. No token is sent if the
+// variable is empty. See Token.
+func TokenFromConfig(name string) UpdaterOption {
+	return func(u *Updater) {
+		u.fetcher.token = func() (string, error) { return aw.NewConfig().Get(name), nil }
+	}
+}
+
+// TokenFromKeychain is an UpdaterOption that reads the bearer token from
+// the workflow's Keychain entry for account when a request is made. No token
+// is sent if there is no such entry. See Token.
+func TokenFromKeychain(account string) UpdaterOption {
+	return func(u *Updater) {
+		u.fetcher.token = func() (string, error) {
+			kc := keychain.New(aw.NewConfig().Get(aw.EnvVarBundleID))
+			s, err := kc.Get(account)
+			if errors.Is(err, keychain.ErrNotFound) {
+				return "", nil
+			}
+			return s, err
+		}
+	}
+}
+
+// Header is an UpdaterOption that adds an HTTP header to requests for
+// releases and workflow files. Headers may contain credentials, so they
+// are only sent to the same hosts as the token. See Token.
+func Header(key, value string) UpdaterOption {
+	return func(u *Updater) {
+		if u.fetcher.header == nil {
+			u.fetcher.header = http.Header{}
+		}
+		u.fetcher.header.Add(key, value)
+	}
+}
+
+// CredentialHosts is an UpdaterOption that also sends the token and
+// custom headers to hosts, e.g. a server that hosts the workflow files
+// listed by a feed. Hosts are matched exactly, including any port.
+func CredentialHosts(hosts ...string) UpdaterOption {
+	return func(u *Updater) {
+		for _, h := range hosts {
+			u.fetcher.trustHost(h)
+		}
+	}
+}
+
+// httpSource is a Source that fetches releases via HTTP. newOption passes
+// it the Updater's fetcher.
+type httpSource interface {
+	setFetcher(f *fetcher)
+}
+
+// fetcher makes the HTTP requests of an Updater and its Source. It adds
+// headers and a bearer token to requests to trusted hosts, and caches
+// responses with an ETag, so unchanged release lists are fetched with a
+// conditional request.
+type fetcher struct {
+	header   http.Header            // Added to requests to trusted hosts
+	token    func() (string, error) // Returns bearer token
+	hosts    map[string]bool        // Trusted hosts
+	cacheDir string                 // Where responses are cached
+}
+
+// trust adds the host of URL to the hosts that are sent credentials.
+// httpSources call it with their URL.
+func (f *fetcher) trust(URL string) {
+	if u, err := url.Parse(URL); err == nil && u.Host != "" {
+		f.trustHost(u.Host)
+	}
+}
+
+// trustHost adds host to the hosts that are sent credentials.
+func (f *fetcher) trustHost(host string) {
+	if f.hosts == nil {
+		f.hosts = map[string]bool{}
+	}
+	f.hosts[strings.ToLower(host)] = true
+}
+
+// trusted returns true if credentials may be sent to URL.
+func (f *fetcher) trusted(URL *url.URL) bool {
+	return f.hosts[strings.ToLower(URL.Host)]
+}
+
+// configured returns true if fetcher has custom headers or a token.
+func (f *fetcher) configured() bool {
+	return f != nil && (f.token != nil || len(f.header) > 0)
+}
+
+// authenticated returns true if fetcher sends credentials to URL, i.e.
+// URL's host is trusted and there is a token or Authorization header.
+func (f *fetcher) authenticated(URL string) bool {
+	if f == nil {
+		return false
+	}
+	u, err := url.Parse(URL)
+	if err != nil || !f.trusted(u) {
+		return false
+	}
+	if f.header.Get("Authorization") != "" {
+		return true
+	}
+	if f.token == nil {
+		return false
+	}
+	token, err := f.token()
+	return err == nil && token != ""
+}
+
+// newRequest creates a GET request. Fetcher's headers and token are only
+// added if the URL's host is trusted.
+func (f *fetcher) newRequest(URL string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !f.trusted(req.URL) {
+		return req, nil
+	}
+	for k, v := range f.header {
+		req.Header[k] = append([]string{}, v...)
+	}
+	if f.token != nil {
+		token, err := f.token()
+		if err != nil {
+			return nil, fmt.Errorf("get token: %w", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	return req, nil
+}
+
+// cachedResponse is a response saved by fetcher.
+type cachedResponse struct {
+	ETag string
+	Data []byte
+}
+
+// get returns the contents of URL. If the response to a previous request
+// had an ETag, the request is conditional, and the previous response is
+// returned if the server says it is unchanged.
+func (f *fetcher) get(URL string) ([]byte, error) {
+	req, err := f.newRequest(URL)
+	if err != nil {
+		return nil, err
+	}
+	cached := f.cached(URL)
+	if cached != nil {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	res, err := f.open(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		log.Printf("not modified: %s", URL)
+		return cached.Data, nil
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if etag := res.Header.Get("ETag"); etag != "" {
+		f.cache(URL, cachedResponse{ETag: etag, Data: data})
+	}
+	return data, nil
+}
+
+// getAsset returns the contents of a release file. See openAsset.
+func (f *fetcher) getAsset(URL string) ([]byte, error) {
+	res, err := f.openAsset(URL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return ioutil.ReadAll(res.Body)
+}
+
+// download saves a release file to path. See openAsset.
+func (f *fetcher) download(URL, path string) error {
+	res, err := f.openAsset(URL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return saveBody(res.Body, path)
+}
+
+// openAsset requests a release file. Unlike get, it asks for the file's
+// contents, not its metadata, if URL is an API URL.
+func (f *fetcher) openAsset(URL string) (*http.Response, error) {
+	req, err := f.newRequest(URL)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	return f.open(req)
+}
+
+// open sends req with a client that removes fetcher's headers and token
+// from requests redirected to untrusted hosts. Go's client only removes
+// Authorization and Cookie headers, so custom headers such as GitLab's
+// PRIVATE-TOKEN would otherwise be sent to the new host.
+func (f *fetcher) open(req *http.Request) (*http.Response, error) {
+	if client == nil {
+		client = makeHTTPClient()
+	}
+	c := *client
+	c.CheckRedirect = f.checkRedirect
+	return openRequestWith(&c, req)
+}
+
+// checkRedirect implements http.Client.CheckRedirect.
+func (f *fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !f.trusted(req.URL) {
+		for k := range f.header {
+			req.Header.Del(k)
+		}
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// cachePath returns the path of the cached response for URL.
+func (f *fetcher) cachePath(URL string) string {
+	return filepath.Join(f.cacheDir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(URL))))
+}
+
+// cached returns the cached response for URL or nil.
+func (f *fetcher) cached(URL string) *cachedResponse {
+	if f.cacheDir == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(f.cachePath(URL))
+	if err != nil {
+		return nil
+	}
+	var r cachedResponse
+	if err := json.Unmarshal(data, &r); err != nil {
+		log.Printf("error: load cached response for %s: %v", URL, err)
+		return nil
+	}
+	if r.ETag == "" {
+		return nil
+	}
+	return &r
+}
+
+// cache saves the response for URL.
+func (f *fetcher) cache(URL string, r cachedResponse) {
+	if f.cacheDir == "" {
+		return
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		log.Printf("error: cache response for %s: %v", URL, err)
+		return
+	}
+	util.MustExist(f.cacheDir)
+	if err := ioutil.WriteFile(f.cachePath(URL), data, 0600); err != nil {
+		log.Printf("error: cache response for %s: %v", URL, err)
+	}
+}
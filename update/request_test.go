@@ -0,0 +1,292 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer records the headers of requests.
+type testServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests map[string][]http.Header // URL path -> request headers
+}
+
+func (ts *testServer) headers(path string) []http.Header {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.requests[path]
+}
+
+// newTestServer serves a GitHub-style release list with ETag "v1" and
+// its workflow file, which requires bearer token "secret" via the API URL.
+func newTestServer() *testServer {
+	ts := &testServer{requests: map[string][]http.Header{}}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		ts.requests[r.URL.Path] = append(ts.requests[r.URL.Path], r.Header)
+		ts.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/releases":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprintf(w, `[{"tag_name": "v2.0", "assets": [{
+				"name": "Dummy-2.0.alfredworkflow",
+				"url": "%[1]s/assets/1",
+				"browser_download_url": "%[1]s/download/Dummy-2.0.alfredworkflow"
+			}]}]`, ts.URL)
+		case "/assets/1":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if r.Header.Get("Accept") != "application/octet-stream" {
+				fmt.Fprintln(w, `{"name": "Dummy-2.0.alfredworkflow"}`)
+				return
+			}
+			w.Write(testFileData) // nolint: errcheck
+		case "/download/Dummy-2.0.alfredworkflow":
+			w.Write(testFileData) // nolint: errcheck
+		case "/redirect":
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return ts
+}
+
+// newTestUpdater returns an Updater for a GitHub source at URL.
+func newTestUpdater(t *testing.T, URL, dir string, opts ...UpdaterOption) *Updater {
+	src := &source{URL: URL, fetch: getURL}
+	u, err := NewUpdater(src, "1.0", dir)
+	require.Nil(t, err, "create updater failed")
+	for _, opt := range opts {
+		opt(u)
+	}
+	src.setFetcher(u.fetcher)
+	return u
+}
+
+func TestFetcher(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer()
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "aw-")
+	require.Nil(t, err, "create tempdir")
+	defer func() { panicOnError(os.RemoveAll(dir)) }()
+
+	u := newTestUpdater(t, ts.URL+"/releases", dir, Token("secret"), Header("X-Test", "1"), Header("X-Test", "2"))
+	f := u.fetcher
+	assert.True(t, f.configured(), "fetcher not configured")
+	assert.True(t, f.authenticated(ts.URL+"/assets/1"), "fetcher not authenticated")
+	assert.False(t, f.authenticated("https://example.com/assets/1"), "fetcher authenticated for other host")
+
+	data, err := f.get(ts.URL + "/releases")
+	require.Nil(t, err, "fetch releases")
+	data2, err := f.get(ts.URL + "/releases")
+	require.Nil(t, err, "fetch releases again")
+	assert.Equal(t, data, data2, "cached response differs")
+
+	headers := ts.headers("/releases")
+	require.Equal(t, 2, len(headers), "unexpected requests")
+	assert.Equal(t, "", headers[0].Get("If-None-Match"), "first request is conditional")
+	assert.Equal(t, `"v1"`, headers[1].Get("If-None-Match"), "second request isn't conditional")
+	for _, h := range headers {
+		assert.Equal(t, "Bearer secret", h.Get("Authorization"), "unexpected token")
+		assert.Equal(t, []string{"1", "2"}, h["X-Test"], "unexpected custom header")
+	}
+
+	// asset contents, not metadata
+	data, err = f.getAsset(ts.URL + "/assets/1")
+	require.Nil(t, err, "fetch asset")
+	assert.Equal(t, testFileData, data, "unexpected asset")
+
+	// credentials aren't sent to other hosts
+	other := newTestServer()
+	defer other.Close()
+	_, err = f.get(other.URL + "/releases")
+	require.Nil(t, err, "fetch releases from other host")
+	headers = other.headers("/releases")
+	require.Equal(t, 1, len(headers), "unexpected requests")
+	assert.Equal(t, "", headers[0].Get("Authorization"), "token sent to other host")
+	assert.Nil(t, headers[0]["X-Test"], "custom header sent to other host")
+
+	// empty token
+	f.token = func() (string, error) { return "", nil }
+	assert.False(t, f.authenticated(ts.URL+"/assets/1"), "fetcher authenticated without token")
+
+	// token errors are returned
+	f.token = func() (string, error) { return "", errors.New("locked") }
+	_, err = f.get(ts.URL + "/releases")
+	assert.NotNil(t, err, "token error ignored")
+}
+
+func TestAuthenticatedInstall(t *testing.T) {
+	origRun := runCommand
+	defer func() { runCommand = origRun }()
+	me := &mockExec{}
+	runCommand = me.Run
+
+	ts := newTestServer()
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "aw-")
+	require.Nil(t, err, "create tempdir")
+	defer func() { panicOnError(os.RemoveAll(dir)) }()
+
+	// without a token, the browser URL is used
+	u := newTestUpdater(t, ts.URL+"/releases", dir)
+	dls, err := u.Source.Downloads()
+	require.Nil(t, err, "fetch releases")
+	require.Equal(t, 1, len(dls), "unexpected downloads")
+	assert.Equal(t, ts.URL+"/download/Dummy-2.0.alfredworkflow", dls[0].URL, "unexpected URL")
+
+	u = newTestUpdater(t, ts.URL+"/releases", dir, TokenFromConfig("TEST_UPDATE_TOKEN"))
+	panicOnError(os.Setenv("TEST_UPDATE_TOKEN", "secret"))
+	defer func() { panicOnError(os.Unsetenv("TEST_UPDATE_TOKEN")) }()
+
+	require.Nil(t, u.CheckForUpdate(), "check for update")
+	require.True(t, u.UpdateAvailable(), "no update available")
+	assert.Equal(t, ts.URL+"/assets/1", u.latest().URL, "unexpected URL")
+	require.Nil(t, u.Install(), "install update")
+	assert.Equal(t, "open", me.name, "wrong command called")
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "Dummy-2.0.alfredworkflow"))
+	require.Nil(t, err, "read download")
+	assert.Equal(t, testFileData, data, "unexpected download")
+
+	// cached response survives clearing the cache
+	require.Nil(t, u.CheckForUpdate(), "check for update again")
+	headers := ts.headers("/releases")
+	assert.Equal(t, `"v1"`, headers[len(headers)-1].Get("If-None-Match"), "request isn't conditional")
+	assert.True(t, u.UpdateAvailable(), "no update available")
+}
+
+// Credentials are removed from requests redirected to untrusted hosts.
+func TestFetcherRedirect(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer()
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "aw-")
+	require.Nil(t, err, "create tempdir")
+	defer func() { panicOnError(os.RemoveAll(dir)) }()
+
+	tests := []struct {
+		name    string
+		trusted bool
+	}{
+		{"untrusted host", false},
+		{"trusted host", true},
+	}
+
+	for _, td := range tests {
+		t.Run(td.name, func(t *testing.T) {
+			other := newTestServer()
+			defer other.Close()
+
+			opts := []UpdaterOption{Token("secret"), Header("X-Test", "1")}
+			if td.trusted {
+				opts = append(opts, CredentialHosts(other.Listener.Addr().String()))
+			}
+			u := newTestUpdater(t, ts.URL+"/releases", dir, opts...)
+			data, err := u.fetcher.getAsset(ts.URL + "/redirect?to=" +
+				url.QueryEscape(other.URL+"/download/Dummy-2.0.alfredworkflow"))
+			require.Nil(t, err, "fetch redirected asset")
+			assert.Equal(t, testFileData, data, "unexpected asset")
+
+			headers := ts.headers("/redirect")
+			require.NotEqual(t, 0, len(headers), "no request")
+			assert.Equal(t, "Bearer secret", headers[len(headers)-1].Get("Authorization"), "token not sent")
+
+			headers = other.headers("/download/Dummy-2.0.alfredworkflow")
+			require.Equal(t, 1, len(headers), "unexpected requests")
+			if td.trusted {
+				assert.Equal(t, "Bearer secret", headers[0].Get("Authorization"), "token not sent to trusted host")
+				assert.Equal(t, "1", headers[0].Get("X-Test"), "custom header not sent to trusted host")
+			} else {
+				assert.Equal(t, "", headers[0].Get("Authorization"), "token sent to untrusted host")
+				assert.Nil(t, headers[0]["X-Test"], "custom header sent to untrusted host")
+			}
+		})
+	}
+}
+
+// Credentials are only sent to the workflow file's host if it's trusted.
+func TestCredentialHosts(t *testing.T) {
+	origRun := runCommand
+	defer func() { runCommand = origRun }()
+	runCommand = (&mockExec{}).Run
+
+	files := newTestServer()
+	defer files.Close()
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `[{"version": "2.0", "url": "%s/download/Dummy-2.0.alfredworkflow"}]`, files.URL)
+	}))
+	defer feed.Close()
+
+	tests := []struct {
+		name string
+		opts []UpdaterOption
+		x    string // expected Authorization header
+	}{
+		{"source host", nil, ""},
+		{"trusted host", []UpdaterOption{CredentialHosts(files.Listener.Addr().String())}, "Bearer secret"},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "aw-")
+			require.Nil(t, err, "create tempdir")
+			defer func() { panicOnError(os.RemoveAll(dir)) }()
+
+			src := &FeedSource{URL: feed.URL}
+			u, err := NewUpdater(src, "1.0", dir)
+			require.Nil(t, err, "create updater")
+			Token("secret")(u)
+			Header("X-Test", "1")(u)
+			for _, opt := range td.opts {
+				opt(u)
+			}
+			src.setFetcher(u.fetcher)
+
+			require.Nil(t, u.CheckForUpdate(), "check for update")
+			require.True(t, u.UpdateAvailable(), "no update available")
+			require.Nil(t, u.Install(), "install update")
+
+			headers := files.headers("/download/Dummy-2.0.alfredworkflow")
+			require.NotEqual(t, 0, len(headers), "file not downloaded")
+			h := headers[len(headers)-1]
+			assert.Equal(t, td.x, h.Get("Authorization"), "unexpected token")
+			if td.x == "" {
+				assert.Nil(t, h["X-Test"], "custom header sent to untrusted host")
+			} else {
+				assert.Equal(t, "1", h.Get("X-Test"), "custom header not sent")
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package update
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -44,22 +45,26 @@ var (
 			return err
 		}
 		defer res.Body.Close()
-
-		util.MustExist(filepath.Dir(path))
-		out, err := os.Create(path)
-		if err != nil {
-			return err
-		}
-		defer out.Close()
-		n, err := io.Copy(out, res.Body)
-		if err != nil {
-			return err
-		}
-		log.Printf("wrote %q (%d bytes)", util.PrettyPath(path), n)
-		return nil
+		return saveBody(res.Body, path)
 	}
 )
 
+// saveBody writes the body of an HTTP response to path.
+func saveBody(r io.Reader, path string) error {
+	util.MustExist(filepath.Dir(path))
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	n, err := io.Copy(out, r)
+	if err != nil {
+		return err
+	}
+	log.Printf("wrote %q (%d bytes)", util.PrettyPath(path), n)
+	return nil
+}
+
 // Source provides workflow files that can be downloaded.
 // This is what concrete updaters (e.g. GitHub, Gitea) should implement.
 // Source is called by the Updater after every updater interval.
@@ -72,14 +77,17 @@ type Source interface {
 type byVersion []Download
 
 // Len implements sort.Interface.
-func (s byVersion) Len() int      { return len(s) }
-func (s byVersion) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
-func (s byVersion) Less(i, j int) bool {
+func (s byVersion) Len() int           { return len(s) }
+func (s byVersion) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byVersion) Less(i, j int) bool { return versionLess(s[i], s[j]) }
+
+// versionLess returns true if a is older than b.
+func versionLess(a, b Download) bool {
 	// Compare workflow versions first, compatible Alfred version second.
-	if s[i].Version.Ne(s[j].Version) {
-		return s[i].Version.Lt(s[j].Version)
+	if a.Version.Ne(b.Version) {
+		return a.Version.Lt(b.Version)
 	}
-	return s[i].AlfredVersion().Lt(s[j].AlfredVersion())
+	return a.AlfredVersion().Lt(b.AlfredVersion())
 }
 
 // Download is an Alfred workflow available for download & installation.
@@ -92,6 +100,20 @@ type Download struct {
 	Filename   string
 	Version    SemVer // Semantic version no.
 	Prerelease bool   // Whether this version is a pre-release
+	SHA256     string // Hex-encoded SHA-256 digest of file. Optional.
+	Signature  string // Base64-encoded ed25519 signature of file. Optional.
+	// Channel is the release channel set by the Source, e.g. a Sparkle
+	// channel. Optional: by default, the channel is derived from Version.
+	Channel string
+	// ChecksumsURL and SignatureURL are the release's checksums and
+	// signature files. Install reads SHA256 and Signature from them.
+	// Set by the GitHub, Gitea and GitLab sources.
+	ChecksumsURL string
+	SignatureURL string
+	// Rollout is the fraction of installations (between 0 and 1) that
+	// are offered this version during a staged rollout. nil means all,
+	// and 0 none.
+	Rollout *float64
 }
 
 // AlfredVersion returns minimum compatible version of Alfred based on file extension.
@@ -132,14 +154,36 @@ type Updater struct {
 	CurrentVersion SemVer // Version of the installed workflow
 	Prereleases    bool   // Include pre-releases when checking for updates
 
+	// Channel is the release channel the user is on, e.g. "beta". If set,
+	// it overrides Prereleases, and releases of Channel and more stable
+	// channels are offered. See Channel and ChannelFromConfig.
+	Channel string
+	// Channels are the release channels, ordered from most to least
+	// stable. Default: DefaultChannels.
+	Channels []Channel
+
+	// Constraint restricts updates to matching versions, e.g. "^2.1"
+	// for updates within version 2. Default: any version.
+	Constraint Constraint
+
+	// PublicKey verifies the signatures of downloads. If set, Install
+	// refuses to install a download that isn't signed with the
+	// corresponding private key.
+	PublicKey ed25519.PublicKey
+
 	// AlfredVersion is the version of the running Alfred application.
 	// Read from $alfred_version environment variable.
 	AlfredVersion SemVer
 
+	// WorkflowUID identifies the installation in staged rollouts.
+	// Read from $alfred_workflow_uid environment variable.
+	WorkflowUID string
+
 	// When the remote release list was last checked (and possibly cached)
 	LastCheck      time.Time
 	updateInterval time.Duration // How often to check for an update
 	downloads      []Download    // Available workflow files
+	fetcher        *fetcher      // Makes authenticated & conditional requests
 
 	// Cache paths
 	cacheDir      string // Directory to store cache files in
@@ -164,6 +208,7 @@ func NewUpdater(src Source, currentVersion, cacheDir string) (*Updater, error) {
 		LastCheck:      time.Time{},
 		Source:         src,
 		cacheDir:       cacheDir,
+		fetcher:        &fetcher{cacheDir: filepath.Join(cacheDir, responseCacheDir)},
 		updateInterval: UpdateInterval,
 		pathLastCheck:  filepath.Join(cacheDir, "LastCheckTime.txt"),
 		pathDownloads:  filepath.Join(cacheDir, "Downloads.json"),
@@ -174,6 +219,7 @@ func NewUpdater(src Source, currentVersion, cacheDir string) (*Updater, error) {
 			u.AlfredVersion = v
 		}
 	}
+	u.WorkflowUID = os.Getenv("alfred_workflow_uid")
 
 	// Load LastCheck
 	if data, err := ioutil.ReadFile(u.pathLastCheck); err == nil {
@@ -243,25 +289,62 @@ func (u *Updater) CheckForUpdate() error {
 // Install downloads and installs the latest available version.
 // After the workflow file is downloaded, Install calls Alfred to
 // install the update.
+//
+// If the download has a SHA-256 digest or the Updater has a PublicKey,
+// Install checks the downloaded file and deletes it instead of
+// installing it if the digest or signature doesn't match. The digest and
+// signature are fetched from the release's checksums and signature files
+// if necessary.
 func (u *Updater) Install() error {
 	dl := u.latest()
 	if dl == nil {
 		return errors.New("no downloads available")
 	}
+	if r, ok := u.Source.(resolver); ok {
+		resolved, err := r.resolve(*dl)
+		if err != nil {
+			return fmt.Errorf("verify %s: %w", dl.Filename, err)
+		}
+		dl = &resolved
+	}
 	log.Printf("downloading version %s ...", dl.Version)
 	p := filepath.Join(u.cacheDir, dl.Filename)
-	if err := download(dl.URL, p); err != nil {
+	if err := u.download(dl.URL, p); err != nil {
+		u.removeFile(p)
 		return err
 	}
+	if err := u.verify(*dl, p); err != nil {
+		u.removeFile(p)
+		return fmt.Errorf("verify %s: %w", dl.Filename, err)
+	}
 
 	return runCommand("open", p)
 }
 
-// clearCache removes the update cache.
+// download saves URL to path. If the Updater has credentials or custom
+// headers, they are sent with the request if URL's host is trusted.
+func (u *Updater) download(URL, path string) error {
+	if u.fetcher.configured() {
+		return u.fetcher.download(URL, path)
+	}
+	return download(URL, path)
+}
+
+// clearCache removes the update cache, except for cached HTTP responses,
+// which are needed for conditional requests.
 func (u *Updater) clearCache() {
-	if err := util.ClearDirectory(u.cacheDir); err != nil {
+	infos, err := ioutil.ReadDir(u.cacheDir)
+	if err != nil && !os.IsNotExist(err) {
 		log.Printf("error: clear cache: %v", err)
 	}
+	for _, fi := range infos {
+		if fi.Name() == responseCacheDir {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(u.cacheDir, fi.Name())); err != nil {
+			log.Printf("error: clear cache: %v", err)
+		}
+	}
 	util.MustExist(u.cacheDir)
 }
 
@@ -278,7 +361,8 @@ func (u *Updater) cacheLastCheck() {
 }
 
 // Returns latest version that is compatible with the Updater's
-// Alfred version & pre-release preference.
+// Alfred version, release channel & version constraint, and has been
+// rolled out to this installation.
 func (u *Updater) latest() *Download {
 	if u.downloads == nil {
 		u.downloads = []Download{}
@@ -303,13 +387,21 @@ func (u *Updater) latest() *Download {
 	}
 	for _, dl := range u.downloads {
 		dl := dl
-		if dl.Prerelease && !u.Prereleases {
+		if !u.inChannel(dl) {
+			continue
+		}
+		if !u.Constraint.Check(dl.Version) {
+			log.Printf("excluded: %q: version %v doesn't match %q", dl.Filename, dl.Version, u.Constraint)
 			continue
 		}
 		if !u.AlfredVersion.IsZero() && dl.AlfredVersion().Gt(u.AlfredVersion) {
 			log.Printf("incompatible: %q: current=%v, required=%v", dl.Filename, u.AlfredVersion, dl.AlfredVersion())
 			continue
 		}
+		if !u.inRollout(dl) {
+			log.Printf("not yet available: %q: staged rollout to %g%% of installations", dl.Filename, *dl.Rollout*100)
+			continue
+		}
 		return &dl
 	}
 	return nil
@@ -351,16 +443,33 @@ func getURL(url string) ([]byte, error) {
 // openURL returns an http.Response. It will return an error if the
 // HTTP status code > 299.
 func openURL(url string) (*http.Response, error) {
-	log.Printf("fetching %s ...", url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return openRequest(req)
+}
+
+// openRequest returns the http.Response to a request. It will return an
+// error if the HTTP status code > 299, except for 304 (Not Modified), the
+// response to a successful conditional request.
+func openRequest(req *http.Request) (*http.Response, error) {
 	if client == nil {
 		client = makeHTTPClient()
 	}
-	r, err := client.Get(url)
+	return openRequestWith(client, req)
+}
+
+// openRequestWith is openRequest with a specific client.
+func openRequestWith(c *http.Client, req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	log.Printf("fetching %s ...", url)
+	r, err := c.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	log.Printf("[%d] %s", r.StatusCode, url)
-	if r.StatusCode > 299 {
+	if r.StatusCode > 299 && r.StatusCode != http.StatusNotModified {
 		r.Body.Close()
 		return nil, errors.New(r.Status)
 	}
@@ -0,0 +1,177 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// matches filename of a release's checksums file, e.g. "checksums.txt",
+// "SHA256SUMS" or "alfred-ssh_0.8.0_checksums.txt"
+var rxChecksumsFile = regexp.MustCompile(`(?i)(^|[._-])(sha256sums?|checksums?)(\.txt)?$`)
+
+// matches a line of a BSD-style checksums file, e.g. "SHA256 (file) = digest"
+var rxBSDChecksum = regexp.MustCompile(`^SHA256 \((.+)\) = ([0-9a-fA-F]+)$`)
+
+// PublicKey is an UpdaterOption that sets the key used to verify the
+// signatures of downloads. See Updater.PublicKey.
+func PublicKey(key ed25519.PublicKey) UpdaterOption {
+	return func(u *Updater) { u.PublicKey = key }
+}
+
+// verify checks the file at path against the digest and signature of dl.
+func (u *Updater) verify(dl Download, path string) error {
+	if dl.SHA256 == "" && u.PublicKey == nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if dl.SHA256 != "" {
+		sum := sha256.Sum256(data)
+		if s := hex.EncodeToString(sum[:]); !strings.EqualFold(s, dl.SHA256) {
+			return fmt.Errorf("SHA-256 mismatch: expected %s, got %s", dl.SHA256, s)
+		}
+	}
+
+	if u.PublicKey == nil {
+		return nil
+	}
+	if len(u.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key: %d bytes", len(u.PublicKey))
+	}
+	if dl.Signature == "" {
+		return errors.New("download is not signed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(dl.Signature)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+	if !ed25519.Verify(u.PublicKey, data, sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// removeFile deletes a rejected or partial download.
+func (u *Updater) removeFile(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("error: remove download: %v", err)
+	}
+}
+
+// resolver is a Source whose Downloads refer to checksums and signature
+// files. Install calls resolve for the Download it installs.
+type resolver interface {
+	resolve(dl Download) (Download, error)
+}
+
+// resolve fetches the digest and signature of a release file.
+func (src *source) resolve(dl Download) (Download, error) {
+	if dl.ChecksumsURL != "" {
+		data, err := src.fetchAsset(dl.ChecksumsURL)
+		if err != nil {
+			return dl, fmt.Errorf("fetch checksums: %w", err)
+		}
+		if dl.SHA256, err = findDigest(data, dl.Filename); err != nil {
+			return dl, fmt.Errorf("checksum of %s: %w", dl.Filename, err)
+		}
+	}
+	if dl.SignatureURL != "" {
+		data, err := src.fetchAsset(dl.SignatureURL)
+		if err != nil {
+			return dl, fmt.Errorf("fetch signature: %w", err)
+		}
+		if dl.Signature, err = parseSignature(data); err != nil {
+			return dl, fmt.Errorf("signature of %s: %w", dl.Filename, err)
+		}
+	}
+	return dl, nil
+}
+
+// fetchAsset fetches a checksums or signature file.
+func (src *source) fetchAsset(URL string) ([]byte, error) {
+	if src.fetcher != nil {
+		return src.fetcher.getAsset(URL)
+	}
+	return src.fetch(URL)
+}
+
+// assetURL returns the URL to fetch a release file from, which is its API
+// URL if the source sends credentials.
+func (src *source) assetURL(a asset) string {
+	if a.APIURL != "" && src.fetcher.authenticated(a.APIURL) {
+		return a.APIURL
+	}
+	return a.URL
+}
+
+// findDigest returns the digest of filename from a checksums file. The
+// file may be in the format of sha256sum ("<digest>  <filename>"), BSD
+// ("SHA256 (<filename>) = <digest>"), or contain only a digest.
+func findDigest(data []byte, filename string) (string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if s := strings.TrimSpace(scanner.Text()); s != "" {
+			lines = append(lines, s)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if len(lines) == 1 && !strings.ContainsAny(lines[0], " \t") {
+		return parseDigest(lines[0])
+	}
+	for _, s := range lines {
+		if m := rxBSDChecksum.FindStringSubmatch(s); len(m) == 3 {
+			if m[1] == filename {
+				return parseDigest(m[2])
+			}
+			continue
+		}
+		fields := strings.Fields(s)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == filename {
+			return parseDigest(fields[0])
+		}
+	}
+	return "", errors.New("not in checksums file")
+}
+
+// parseDigest validates a hex-encoded SHA-256 digest.
+func parseDigest(s string) (string, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 digest: %q", s)
+	}
+	return strings.ToLower(s), nil
+}
+
+// parseSignature returns a raw or base64-encoded ed25519 signature as base64.
+func parseSignature(data []byte) (string, error) {
+	if len(data) == ed25519.SignatureSize {
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	s := strings.TrimSpace(string(data))
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != ed25519.SignatureSize {
+		return "", errors.New("invalid ed25519 signature")
+	}
+	return s, nil
+}
@@ -0,0 +1,360 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testFileData                     = []byte("workflow file contents")
+	testPublicKey, testPrivateKey, _ = ed25519.GenerateKey(nil)
+)
+
+func testDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func testSignature(key ed25519.PrivateKey, data []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+}
+
+func TestUpdater_InstallVerify(t *testing.T) {
+	origRun := runCommand
+	origDownload := download
+	defer func() {
+		runCommand = origRun
+		download = origDownload
+	}()
+	download = func(URL, path string) error {
+		return ioutil.WriteFile(path, testFileData, 0600)
+	}
+
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	tests := []struct {
+		name      string
+		digest    string
+		signature string
+		key       ed25519.PublicKey
+		ok        bool
+	}{
+		{"unverified", "", "", nil, true},
+		{"digest", testDigest(testFileData), "", nil, true},
+		{"digest mismatch", testDigest([]byte("other")), "", nil, false},
+		{"signature", "", testSignature(testPrivateKey, testFileData), testPublicKey, true},
+		{"signature ignored", "", testSignature(otherKey, testFileData), nil, true},
+		{"digest and signature", testDigest(testFileData), testSignature(testPrivateKey, testFileData), testPublicKey, true},
+		{"unsigned", testDigest(testFileData), "", testPublicKey, false},
+		{"wrong key", "", testSignature(otherKey, testFileData), testPublicKey, false},
+		{"bad signature", "", testSignature(testPrivateKey, []byte("other")), testPublicKey, false},
+		{"invalid signature", "", "not base64", testPublicKey, false},
+		{"invalid key", "", testSignature(testPrivateKey, testFileData), ed25519.PublicKey("short"), false},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "aw-")
+			require.Nil(t, err, "create tempdir")
+			defer func() { panicOnError(os.RemoveAll(dir)) }()
+
+			me := &mockExec{}
+			runCommand = me.Run
+
+			src := &testSource{dls: []Download{{
+				URL:       "https://example.com/Dummy.alfredworkflow",
+				Filename:  "Dummy.alfredworkflow",
+				Version:   mustVersion("1.0"),
+				SHA256:    td.digest,
+				Signature: td.signature,
+			}}}
+			u, err := NewUpdater(src, "0.1", dir)
+			require.Nil(t, err, "create updater failed")
+			u.PublicKey = td.key
+			require.Nil(t, u.CheckForUpdate(), "get releases failed")
+
+			err = u.Install()
+			p := filepath.Join(dir, "Dummy.alfredworkflow")
+			if td.ok {
+				assert.Nil(t, err, "install failed")
+				assert.Equal(t, "open", me.name, "wrong command called")
+				assert.FileExists(t, p, "download deleted")
+				return
+			}
+			assert.NotNil(t, err, "bad download installed")
+			assert.Equal(t, "", me.name, "bad download opened")
+			_, err = os.Stat(p)
+			assert.True(t, os.IsNotExist(err), "bad download not deleted")
+		})
+	}
+}
+
+func TestUpdater_InstallDownloadFails(t *testing.T) {
+	origDownload := download
+	defer func() { download = origDownload }()
+	download = func(URL, path string) error {
+		if err := ioutil.WriteFile(path, []byte("partial"), 0600); err != nil {
+			return err
+		}
+		return errors.New("connection reset")
+	}
+
+	dir, err := ioutil.TempDir("", "aw-")
+	require.Nil(t, err, "create tempdir")
+	defer func() { panicOnError(os.RemoveAll(dir)) }()
+
+	u, err := NewUpdater(testSrc1, "0.1", dir)
+	require.Nil(t, err, "create updater failed")
+	require.Nil(t, u.CheckForUpdate(), "get releases failed")
+	assert.NotNil(t, u.Install(), "failed download installed")
+	_, err = os.Stat(filepath.Join(dir, "Dummy.alfredworkflow"))
+	assert.True(t, os.IsNotExist(err), "partial download not deleted")
+}
+
+func TestFindDigest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		d1 = testDigest([]byte("one"))
+		d2 = testDigest([]byte("two"))
+	)
+	tests := []struct {
+		name string
+		data string
+		x    string
+		ok   bool
+	}{
+		{"sha256sum", fmt.Sprintf("%s  Other.alfredworkflow\n%s  Dummy.alfredworkflow\n", d1, d2), d2, true},
+		{"binary mode", fmt.Sprintf("%s *Dummy.alfredworkflow\n", d1), d1, true},
+		{"BSD", fmt.Sprintf("SHA256 (Dummy.alfredworkflow) = %s\n", d2), d2, true},
+		{"digest only", d1 + "\n", d1, true},
+		{"upper case", fmt.Sprintf("%X  Dummy.alfredworkflow", sha256.Sum256([]byte("two"))), d2, true},
+		{"missing", fmt.Sprintf("%s  Other.alfredworkflow\n", d1), "", false},
+		{"empty", "", "", false},
+		{"invalid digest", "abc123  Dummy.alfredworkflow", "", false},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			t.Parallel()
+			v, err := findDigest([]byte(td.data), "Dummy.alfredworkflow")
+			if !td.ok {
+				assert.NotNil(t, err, "bad checksums accepted")
+				return
+			}
+			require.Nil(t, err, "parse checksums failed")
+			assert.Equal(t, td.x, v, "unexpected digest")
+		})
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	t.Parallel()
+
+	raw := ed25519.Sign(testPrivateKey, testFileData)
+	b64 := base64.StdEncoding.EncodeToString(raw)
+
+	s, err := parseSignature(raw)
+	require.Nil(t, err, "parse raw signature")
+	assert.Equal(t, b64, s, "unexpected signature")
+
+	s, err = parseSignature([]byte(b64 + "\n"))
+	require.Nil(t, err, "parse base64 signature")
+	assert.Equal(t, b64, s, "unexpected signature")
+
+	_, err = parseSignature([]byte("c2hvcnQ="))
+	assert.NotNil(t, err, "short signature accepted")
+}
+
+// releases JSON with a checksums file and a signature for one workflow file.
+const testVerifyReleases = `[
+	{
+		"tag_name": "v2.0",
+		"assets": [
+			{"name": "Dummy-2.0.alfredworkflow", "browser_download_url": "https://example.com/v2.0/Dummy-2.0.alfredworkflow"},
+			{"name": "Dummy-2.0.alfred4workflow", "browser_download_url": "https://example.com/v2.0/Dummy-2.0.alfred4workflow"},
+			{"name": "Dummy-2.0.alfred4workflow.sig", "browser_download_url": "https://example.com/v2.0/Dummy-2.0.alfred4workflow.sig"},
+			{"name": "checksums.txt", "browser_download_url": "https://example.com/v2.0/checksums.txt"}
+		]
+	},
+	{
+		"tag_name": "v1.0",
+		"assets": [
+			{"name": "Dummy-1.0.alfredworkflow", "browser_download_url": "https://example.com/v1.0/Dummy-1.0.alfredworkflow"},
+			{"name": "Dummy-1.0.alfredworkflow.sha256", "browser_download_url": "https://example.com/v1.0/Dummy-1.0.alfredworkflow.sha256"}
+		]
+	}
+]`
+
+func TestSourceVerify(t *testing.T) {
+	t.Parallel()
+
+	var (
+		d1  = testDigest([]byte("1.0"))
+		d2  = testDigest([]byte("2.0"))
+		d4  = testDigest([]byte("2.0 Alfred 4"))
+		sig = ed25519.Sign(testPrivateKey, []byte("2.0 Alfred 4"))
+	)
+	files := map[string]string{
+		"https://example.com/releases":                             testVerifyReleases,
+		"https://example.com/v2.0/checksums.txt":                   fmt.Sprintf("%s  Dummy-2.0.alfredworkflow\n%s  Dummy-2.0.alfred4workflow\n", d2, d4),
+		"https://example.com/v2.0/Dummy-2.0.alfred4workflow.sig":   string(sig),
+		"https://example.com/v1.0/Dummy-1.0.alfredworkflow.sha256": d1 + "  Dummy-1.0.alfredworkflow\n",
+	}
+	var fetched []string
+	src := &source{
+		URL: "https://example.com/releases",
+		fetch: func(URL string) ([]byte, error) {
+			fetched = append(fetched, URL)
+			if s, ok := files[URL]; ok {
+				return []byte(s), nil
+			}
+			return nil, errors.New("404 Not Found")
+		},
+	}
+
+	// checksums and signatures aren't fetched with releases
+	dls, err := src.Downloads()
+	require.Nil(t, err, "get downloads failed")
+	require.Equal(t, 3, len(dls), "unexpected downloads")
+	assert.Equal(t, []string{"https://example.com/releases"}, fetched, "unexpected requests")
+	assert.Equal(t, "Dummy-2.0.alfred4workflow", dls[0].Filename, "unexpected order")
+	assert.Equal(t, "https://example.com/v2.0/checksums.txt", dls[0].ChecksumsURL, "unexpected checksums URL")
+	assert.Equal(t, "https://example.com/v2.0/Dummy-2.0.alfred4workflow.sig", dls[0].SignatureURL, "unexpected signature URL")
+	assert.Equal(t, "", dls[1].SignatureURL, "unexpected signature URL")
+
+	tests := []struct {
+		dl     Download
+		digest string
+		sig    string
+	}{
+		{dls[0], d4, base64.StdEncoding.EncodeToString(sig)},
+		{dls[1], d2, ""},
+		{dls[2], d1, ""},
+	}
+	for _, td := range tests {
+		dl, err := src.resolve(td.dl)
+		require.Nil(t, err, "resolve %s", td.dl.Filename)
+		assert.Equal(t, td.digest, dl.SHA256, "unexpected digest")
+		assert.Equal(t, td.sig, dl.Signature, "unexpected signature")
+	}
+
+	// unreadable checksums file only affects its release
+	delete(files, "https://example.com/v2.0/checksums.txt")
+	src = &source{URL: src.URL, fetch: src.fetch}
+	dls, err = src.Downloads()
+	require.Nil(t, err, "missing checksums file broke downloads")
+	_, err = src.resolve(dls[0])
+	assert.NotNil(t, err, "missing checksums file ignored")
+	_, err = src.resolve(dls[2])
+	assert.Nil(t, err, "resolve other release")
+}
+
+// Install fetches the checksums & signature of the file it installs.
+func TestSourceVerify_Install(t *testing.T) {
+	origRun := runCommand
+	origDownload := download
+	defer func() {
+		runCommand = origRun
+		download = origDownload
+	}()
+	runCommand = (&mockExec{}).Run
+
+	files := map[string]string{
+		"https://example.com/releases":                           testVerifyReleases,
+		"https://example.com/v2.0/checksums.txt":                 testDigest([]byte("2.0 Alfred 4")) + "  Dummy-2.0.alfred4workflow\n",
+		"https://example.com/v2.0/Dummy-2.0.alfred4workflow.sig": string(ed25519.Sign(testPrivateKey, []byte("2.0 Alfred 4"))),
+	}
+	src := &source{
+		URL: "https://example.com/releases",
+		fetch: func(URL string) ([]byte, error) {
+			if s, ok := files[URL]; ok {
+				return []byte(s), nil
+			}
+			return nil, errors.New("404 Not Found")
+		},
+	}
+
+	tests := []struct {
+		name string
+		data string
+		ok   bool
+	}{
+		{"valid", "2.0 Alfred 4", true},
+		{"tampered", "2.0 Alfred 5", false},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			download = func(URL, path string) error {
+				return ioutil.WriteFile(path, []byte(td.data), 0600)
+			}
+			dir, err := ioutil.TempDir("", "aw-")
+			require.Nil(t, err, "create tempdir")
+			defer func() { panicOnError(os.RemoveAll(dir)) }()
+
+			u, err := NewUpdater(src, "0.1", dir)
+			require.Nil(t, err, "create updater failed")
+			u.PublicKey = testPublicKey
+			require.Nil(t, u.CheckForUpdate(), "get releases failed")
+			err = u.Install()
+			if td.ok {
+				assert.Nil(t, err, "install failed")
+			} else {
+				assert.NotNil(t, err, "tampered file installed")
+			}
+		})
+	}
+}
+
+func TestMetadataVerify(t *testing.T) {
+	t.Parallel()
+
+	var (
+		digest = testDigest(testFileData)
+		sig    = testSignature(testPrivateKey, testFileData)
+		tmpl   = `{"alfredworkflow": {"downloadurl": "https://example.com/Dummy.alfredworkflow", "version": "1.0", "sha256": %q, "signature": %q}}`
+	)
+	tests := []struct {
+		name      string
+		digest    string
+		signature string
+		ok        bool
+	}{
+		{"empty", "", "", true},
+		{"valid", digest, sig, true},
+		{"invalid digest", "xyz", "", false},
+		{"invalid signature", digest, "c2hvcnQ=", false},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			t.Parallel()
+			dl, err := parseMetadata([]byte(fmt.Sprintf(tmpl, td.digest, td.signature)))
+			if !td.ok {
+				assert.NotNil(t, err, "bad metadata accepted")
+				return
+			}
+			require.Nil(t, err, "parse metadata failed")
+			assert.Equal(t, td.digest, dl.SHA256, "unexpected digest")
+			assert.Equal(t, td.signature, dl.Signature, "unexpected signature")
+		})
+	}
+}
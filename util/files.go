@@ -5,7 +5,6 @@ package util
 
 import (
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -52,15 +51,16 @@ func ClearDirectory(p string) error {
 }
 
 // WriteFile is an atomic version of ioutil.WriteFile.
-// It first writes data to a temporary file and renames this to
-// filename if the write is successful.
+// It first writes data to a temporary file in the same directory and
+// renames this to filename if the write is successful, so readers never
+// see a partially-written file. The temporary file is hidden (its name
+// starts with a ".") so directory listings can ignore it.
 func WriteFile(filename string, data []byte, perm os.FileMode) error {
 	dir, name := filepath.Split(filename)
-	f, err := ioutil.TempFile(dir, name)
+	f, err := ioutil.TempFile(dir, "."+name)
 	if err != nil {
 		return err
 	}
-	defer closeOrPanic(f)
 
 	name = f.Name()
 	defer func() {
@@ -72,15 +72,20 @@ func WriteFile(filename string, data []byte, perm os.FileMode) error {
 		}
 	}()
 
-	if err := ioutil.WriteFile(name, data, perm); err != nil {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(name, perm); err != nil {
 		return err
 	}
 
 	return os.Rename(name, filename)
 }
-
-func closeOrPanic(c io.Closer) {
-	if err := c.Close(); err != nil {
-		panic(err)
-	}
-}
@@ -149,6 +149,12 @@ func TestWriteFile(t *testing.T) {
 		infos, err := ioutil.ReadDir(".")
 		require.Nil(t, err, "ReadDir failed")
 		assert.Equal(t, 1, len(infos), "unexpected no. of files")
+
+		// Overwrite with different permissions
+		require.Nil(t, WriteFile(name, []byte(`test2`), 0644), "WriteFile failed")
+		fi, err = os.Stat(name)
+		require.Nil(t, err, "stat file failed")
+		assert.Equal(t, os.FileMode(0644), fi.Mode(), "unexpected file mode")
 	})
 	require.Nil(t, err, "inTempDir failed")
 }
@@ -0,0 +1,51 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+package util
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// lockPollInterval is how often LockFile.Lock retries a held lock.
+var lockPollInterval = 20 * time.Millisecond
+
+// LockFile is an advisory, inter-process lock.
+//
+// On macOS, Linux and the BSDs, the lock is based on flock(2). The lock
+// file is created if it does not exist, and is not deleted when the lock
+// is released. On other platforms, the lock is held by creating the lock
+// file exclusively, and released by deleting it, so a lock file left
+// behind by a crashed process must be deleted by hand.
+//
+// A LockFile is not safe for concurrent use by multiple goroutines;
+// create one LockFile per goroutine instead.
+type LockFile struct {
+	Path string // Path of lock file
+	f    *os.File
+}
+
+// NewLockFile creates a new LockFile for path.
+func NewLockFile(path string) *LockFile {
+	return &LockFile{Path: path}
+}
+
+// Lock waits until the lock is acquired or ctx is done.
+func (l *LockFile) Lock(ctx context.Context) error {
+	for {
+		ok, err := l.TryLock()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package util
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFileKept is true if Unlock leaves the lock file in place.
+const lockFileKept = false
+
+// TryLock acquires the lock without blocking. It returns false if
+// another process (or another LockFile) holds the lock.
+func (l *LockFile) TryLock() (bool, error) {
+	if l.f != nil {
+		return false, fmt.Errorf("lock %q: already locked", l.Path)
+	}
+
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("open lock file: %w", err)
+	}
+	l.f = f
+	return true, nil
+}
+
+// Unlock releases the lock. It is a no-op if the lock isn't held.
+func (l *LockFile) Unlock() error {
+	if l.f == nil {
+		return nil
+	}
+	f := l.f
+	l.f = nil
+	// file must be closed before it can be deleted on Windows
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unlock %q: %w", l.Path, err)
+	}
+	if err := os.Remove(l.Path); err != nil {
+		return fmt.Errorf("unlock %q: %w", l.Path, err)
+	}
+	return nil
+}
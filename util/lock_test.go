@@ -0,0 +1,45 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Only one LockFile can hold the lock.
+func TestLockFile(t *testing.T) {
+	err := inTempDir(func(dir string) {
+		var (
+			a = NewLockFile("test.lock")
+			b = NewLockFile("test.lock")
+		)
+
+		ok, err := a.TryLock()
+		require.Nil(t, err, "lock a failed")
+		require.True(t, ok, "a not locked")
+
+		_, err = a.TryLock()
+		assert.NotNil(t, err, "a locked twice")
+
+		ok, err = b.TryLock()
+		require.Nil(t, err, "lock b failed")
+		assert.False(t, ok, "b locked while a holds lock")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, b.Lock(ctx), "b locked while a holds lock")
+
+		require.Nil(t, a.Unlock(), "unlock a failed")
+		require.Nil(t, a.Unlock(), "unlock a twice failed")
+		require.Nil(t, b.Lock(context.Background()), "lock b failed")
+		require.Nil(t, b.Unlock(), "unlock b failed")
+		assert.Equal(t, lockFileKept, PathExists("test.lock"), "unexpected lock file")
+	})
+	require.Nil(t, err, "inTempDir failed")
+}
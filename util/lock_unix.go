@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFileKept is true if Unlock leaves the lock file in place.
+const lockFileKept = true
+
+// TryLock acquires the lock without blocking. It returns false if
+// another process (or another LockFile) holds the lock.
+func (l *LockFile) TryLock() (bool, error) {
+	if l.f != nil {
+		return false, fmt.Errorf("lock %q: already locked", l.Path)
+	}
+
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return false, fmt.Errorf("open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, fmt.Errorf("lock %q: %w", l.Path, err)
+	}
+	l.f = f
+	return true, nil
+}
+
+// Unlock releases the lock. It is a no-op if the lock isn't held.
+func (l *LockFile) Unlock() error {
+	if l.f == nil {
+		return nil
+	}
+	f := l.f
+	l.f = nil
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+		f.Close()
+		return fmt.Errorf("unlock %q: %w", l.Path, err)
+	}
+	return f.Close()
+}
@@ -5,6 +5,7 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"log"
@@ -166,6 +167,38 @@ func RunCmd(cmd *exec.Cmd) ([]byte, error) {
 	return stdout.Bytes(), nil
 }
 
+// RunCmdContext is like RunCmd, but the command is killed if ctx is
+// cancelled before it exits. In that case, ctx's error is returned.
+func RunCmdContext(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		<-done
+		return nil, ctx.Err()
+	case err := <-done:
+		if err != nil {
+			log.Printf("------------- %v ---------------", cmd.Args)
+			log.Println(stderr.String())
+			log.Println("----------------------------------------------")
+			return nil, err
+		}
+	}
+
+	return stdout.Bytes(), nil
+}
+
 // QuoteAS converts string to an AppleScript string literal for insertion into AppleScript code.
 // It wraps the value in quotation marks, so don't insert additional ones.
 func QuoteAS(s string) string {
@@ -4,11 +4,14 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -108,6 +111,23 @@ func TestRun(t *testing.T) {
 	}
 }
 
+// RunCmdContext kills the command when the Context expires.
+func TestRunCmdContext(t *testing.T) {
+	t.Parallel()
+
+	out, err := RunCmdContext(context.Background(), exec.Command("echo", "hello"))
+	assert.Nil(t, err, "command failed")
+	assert.Equal(t, "hello", strings.TrimSpace(string(out)), "bad output")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = RunCmdContext(ctx, exec.Command("sleep", "5"))
+	assert.Equal(t, context.DeadlineExceeded, err, "unexpected error")
+	assert.True(t, time.Since(start) < time.Second, "command not killed")
+}
+
 func TestNoRun(t *testing.T) {
 	tests := []struct {
 		in      string
@@ -14,6 +14,9 @@ Paths
 There are a couple of convenience path functions, MustExist and
 ClearDirectory.
 
+WriteFile writes files atomically, and LockFile provides an advisory,
+inter-process lock.
+
 
 Formatting
 
@@ -30,6 +33,8 @@ are several Run* functions for executing script code and files.
 	RunJS()   // run JXA code & return output
 	RunCmd()  // run *exec.Cmd & return output
 
+	RunCmdContext()  // like RunCmd, but kills command when context is cancelled
+
 Run takes the path to a script or executable. If file is executable,
 it runs the file directly. If it's a script file, it tries to guess the
 appropriate interpreter.
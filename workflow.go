@@ -4,14 +4,17 @@
 package aw
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime/debug"
 	"sync"
+	"syscall"
 	"time"
 
 	"go.deanishe.net/fuzzy"
@@ -115,12 +118,22 @@ type Workflow struct {
 	// MagicAction for details.
 	magicActions *magicActions
 
+	// tasks are the functions registered with RegisterTask.
+	tasks map[string]TaskFunc
+
+	history *History // Selection history. Created by History().
+
 	logPrefix   string         // Written to debugger to force a newline
 	maxLogSize  int            // Maximum size of log file in bytes
 	magicPrefix string         // Overrides DefaultMagicPrefix for magic actions.
 	maxResults  int            // max. results to send to Alfred. 0 means send all.
+	pageSize    int            // Results per page. 0 means don't paginate.
+	page        int            // Page of results requested by Alfred
+	pageQuery   string         // Query without page marker
+	runTimeout  time.Duration  // Deadline for RunContext. 0 means no deadline.
 	sortOptions []fuzzy.Option // Options for fuzzy filtering
 	textErrors  bool           // Show errors as plaintext, not Alfred JSON
+	compactJSON bool           // Don't indent feedback unless debugging
 	helpURL     string         // URL to help page (shown if there's an error)
 	dir         string         // Directory workflow is in
 	cacheDir    string         // Workflow's cache directory
@@ -128,7 +141,9 @@ type Workflow struct {
 	sessionName string         // Name of the variable sessionID is stored in
 	sessionID   string         // Random session ID
 
-	execFunc commandRunner // Run external commands
+	execFunc commandRunner  // Run external commands
+	exitFunc func(code int) // Overrides package-level exitFunc if set
+	output   io.Writer      // Where feedback is written (default: STDOUT)
 }
 
 // New creates and initialises a new Workflow, passing any Options to
@@ -182,6 +197,9 @@ func NewFromEnv(env Env, opts ...Option) *Workflow {
 		logMA{wf},
 		cacheMA{wf},
 		clearCacheMA{wf},
+		cacheListMA{wf},
+		rememberMA{wf},
+		clearHistoryMA{wf},
 		dataMA{wf},
 		clearDataMA{wf},
 		resetMA{wf},
@@ -190,6 +208,8 @@ func NewFromEnv(env Env, opts ...Option) *Workflow {
 	wf.Configure(opts...)
 
 	wf.Cache = NewCache(wf.CacheDir())
+	logName := filepath.Base(wf.LogFile())
+	wf.Cache.excludeFiles(logName, logName+".1")
 	wf.Data = NewCache(wf.DataDir())
 	wf.Session = NewSession(wf.CacheDir(), wf.SessionID())
 	wf.Keychain = keychain.New(wf.BundleID())
@@ -300,12 +320,35 @@ func (wf *Workflow) Debug() bool { return wf.Config.GetBool(EnvVarDebug) }
 // Args returns command-line arguments passed to the program.
 // It intercepts "magic args" and runs the corresponding actions, terminating
 // the workflow. See MagicAction for full documentation.
+//
+// It also runs tasks started with Spawn. See RegisterTask.
+//
+// If the PageSize Option is set, Args also removes the page marker added
+// by the "More results…" Item from the last argument.
 func (wf *Workflow) Args() []string {
-	prefix := DefaultMagicPrefix
+	wf.runTask(os.Args[1:])
+	return wf.pageArgs(wf.magicActions.args(os.Args[1:], wf.getMagicPrefix()))
+}
+
+// pageArgs removes the page marker from the last argument and saves the
+// page number and query for SendFeedback.
+func (wf *Workflow) pageArgs(args []string) []string {
+	if wf.pageSize < 1 || len(args) == 0 {
+		return args
+	}
+	args = append([]string{}, args...)
+	i := len(args) - 1
+	args[i], wf.page = ParsePage(args[i])
+	wf.pageQuery = args[i]
+	return args
+}
+
+// getMagicPrefix returns the prefix for magic actions.
+func (wf *Workflow) getMagicPrefix() string {
 	if wf.magicPrefix != "" {
-		prefix = wf.magicPrefix
+		return wf.magicPrefix
 	}
-	return wf.magicActions.args(os.Args[1:], prefix)
+	return DefaultMagicPrefix
 }
 
 // Run runs your workflow function, catching any errors.
@@ -358,7 +401,57 @@ func (wf *Workflow) Run(fn func()) {
 	fn()
 
 	wf.Wait()
-	finishLog(false)
+	wf.finishLog(false)
+}
+
+// RunContext is like Run, but fn is passed a Context and may return an error.
+//
+// The Context is cancelled when the program receives SIGINT or SIGTERM
+// (which Alfred sends to a running Script Filter when the user changes
+// the query) or when the timeout set with the RunTimeout Option expires.
+// Pass it on to slow operations, such as Cache.LoadOrStoreContext or
+// util.RunCmdContext, so they abort cleanly.
+//
+// If fn returns an error, it is shown in Alfred and the workflow exits,
+// as with FatalError.
+func (wf *Workflow) RunContext(fn func(ctx context.Context) error) {
+	ctx, cancel := wf.newContext()
+	defer cancel()
+
+	wf.Run(func() {
+		if err := fn(ctx); err != nil {
+			wf.FatalError(err)
+		}
+	})
+}
+
+// newContext returns a Context that is cancelled on SIGINT/SIGTERM or
+// when the workflow's run timeout expires.
+func (wf *Workflow) newContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if wf.runTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, wf.runTimeout)
+		cancelParent := cancel
+		cancel = func() {
+			cancelTimeout()
+			cancelParent()
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sig)
+		select {
+		case s := <-sig:
+			log.Printf("received %v, cancelling ...", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
 }
 
 // --------------------------------------------------------------------
@@ -367,7 +460,7 @@ func (wf *Workflow) Run(fn func()) {
 // outputErrorMsg prints and logs error, then exits process.
 func (wf *Workflow) outputErrorMsg(msg string) {
 	if wf.textErrors {
-		fmt.Print(msg)
+		fmt.Fprint(wf.stdout(), msg)
 	} else {
 		wf.Feedback.Clear()
 		wf.NewItem(msg).Icon(IconError)
@@ -378,7 +471,24 @@ func (wf *Workflow) outputErrorMsg(msg string) {
 	if wf.helpURL != "" {
 		log.Printf("Get help at %s", wf.helpURL)
 	}
-	finishLog(true)
+	wf.finishLog(true)
+}
+
+// stdout returns the writer feedback is sent to.
+func (wf *Workflow) stdout() io.Writer {
+	if wf.output != nil {
+		return wf.output
+	}
+	return os.Stdout
+}
+
+// exit terminates the program with the given status code.
+func (wf *Workflow) exit(code int) {
+	if wf.exitFunc != nil {
+		wf.exitFunc(code)
+		return
+	}
+	exitFunc(code)
 }
 
 // awDataDir is the directory for AwGo's own data.
@@ -391,16 +501,13 @@ func (wf *Workflow) awCacheDir() string {
 	return util.MustExist(filepath.Join(wf.CacheDir(), "_aw"))
 }
 
-// --------------------------------------------------------------------
-// Package-level only
-
 // finishLog outputs the workflow duration
-func finishLog(fatal bool) {
+func (wf *Workflow) finishLog(fatal bool) {
 	s := util.Pad(fmt.Sprintf(" %v ", time.Since(startTime)), "-", 50)
 
 	if fatal {
 		log.Println(s)
-		exitFunc(1)
+		wf.exit(1)
 	} else {
 		log.Println(s)
 	}
@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// RefreshRerun is the value Feedback.Rerun is set to by LoadOrRefresh
+// while a cache is being refreshed in the background.
+const RefreshRerun = 0.5
+
+// RefreshRetry is how long LoadOrRefresh waits before trying again to
+// refresh a cache after the refresh command failed.
+var RefreshRetry = time.Minute
+
+// LoadOrRefresh loads data from Cache c without waiting for them to be
+// refreshed ("stale-while-revalidate").
+//
+// If the named cache does not exist or is older than maxAge, cmd is
+// started via RunJob to refresh it, and Feedback.Rerun is set to
+// RefreshRerun, so Alfred keeps re-running the Script Filter until cmd
+// has stored fresh data. Stale data are returned in the meantime. cmd
+// must save its data to c under name; LoadOrRefresh doesn't touch the cache.
+//
+// If cmd fails, Rerun is not set, a warning Item is added to the
+// feedback, and the refresh isn't retried until RefreshRetry has passed.
+//
+// refreshing is true if a refresh job for the cache is running. If the
+// cache doesn't exist yet, data is nil, so you may want to show a
+// "Loading…" item.
+//
+// If maxAge is 0, the cache is only refreshed if it doesn't exist.
+func (wf *Workflow) LoadOrRefresh(c *Cache, name string, maxAge time.Duration, cmd *exec.Cmd) (data []byte, refreshing bool, err error) {
+	if refreshing, err = wf.refreshCache(c, name, maxAge, cmd); err != nil {
+		return nil, refreshing, err
+	}
+	if !c.Exists(name) {
+		return nil, refreshing, nil
+	}
+	data, err = c.Load(name)
+	return data, refreshing, err
+}
+
+// LoadOrRefreshJSON is the JSON version of LoadOrRefresh. Cached data
+// are unmarshalled into v. If the cache does not exist yet, v is not
+// altered.
+func (wf *Workflow) LoadOrRefreshJSON(c *Cache, name string, maxAge time.Duration, cmd *exec.Cmd, v interface{}) (refreshing bool, err error) {
+	data, refreshing, err := wf.LoadOrRefresh(c, name, maxAge, cmd)
+	if err != nil || data == nil {
+		return refreshing, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return refreshing, fmt.Errorf("unmarshal cached data: %w", err)
+	}
+	return refreshing, nil
+}
+
+// refreshCache starts cmd if the named cache needs refreshing, and tells
+// Alfred to rerun the Script Filter while the job is running.
+func (wf *Workflow) refreshCache(c *Cache, name string, maxAge time.Duration, cmd *exec.Cmd) (bool, error) {
+	job := wf.Job(refreshJobName(c, name))
+	st := job.Status()
+	if st.State == JobRunning {
+		wf.Rerun(RefreshRerun)
+		return true, nil
+	}
+
+	var stale bool
+	if maxAge == 0 {
+		stale = !c.Exists(name)
+	} else {
+		stale = c.Expired(name, maxAge)
+	}
+	if !stale {
+		return false, nil
+	}
+
+	if (st.State == JobFailed || st.State == JobTimedOut) && time.Since(st.Finished) < RefreshRetry {
+		log.Printf("[cache] not refreshing %q: last refresh %s", name, st.State)
+		wf.NewWarningItem(fmt.Sprintf("Couldn't refresh %q", name), refreshError(job, st))
+		return false, nil
+	}
+
+	log.Printf("[cache] refreshing %q in background ...", name)
+	if _, err := wf.RunJob(job.Name, cmd, 0); err != nil && !IsJobExists(err) {
+		return false, fmt.Errorf("refresh cache %q: %w", name, err)
+	}
+	wf.Rerun(RefreshRerun)
+	return true, nil
+}
+
+// refreshError returns the last line of a failed refresh job's STDERR or
+// a description of its exit status.
+func refreshError(job *Job, st JobStatus) string {
+	data, _ := job.Stderr()
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if s := strings.TrimSpace(lines[len(lines)-1]); s != "" {
+		return s
+	}
+	if st.State == JobFailed && st.ExitCode > 0 {
+		return fmt.Sprintf("exit status %d", st.ExitCode)
+	}
+	return st.State.String()
+}
+
+// refreshJobName returns the name of the background job that refreshes
+// the named cache. The name includes a hash of the cache directory, so
+// Workflow.Cache and Workflow.Data can have caches with the same name.
+func refreshJobName(c *Cache, name string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(c.Dir))
+	return fmt.Sprintf("refresh-%s-%08x", strings.ReplaceAll(name, "/", "_"), h.Sum32())
+}
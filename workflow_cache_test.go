@@ -0,0 +1,127 @@
+// Copyright (c) 2021 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Stale data are returned while the cache is refreshed in the background.
+func TestWorkflow_LoadOrRefresh(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		var (
+			c      = wf.Cache
+			name   = "test.txt"
+			maxAge = time.Minute
+			then   = time.Now().Add(-time.Hour)
+		)
+		refresh := func() *exec.Cmd {
+			return exec.Command("/bin/sh", "-c", "sleep 0.1; printf fresh > '"+c.path(name)+"'")
+		}
+		job := wf.Job(refreshJobName(c, name))
+
+		// no data
+		data, refreshing, err := wf.LoadOrRefresh(c, name, maxAge, refresh())
+		require.Nil(t, err, "load/refresh failed")
+		assert.Nil(t, data, "unexpected data")
+		assert.True(t, refreshing, "cache not refreshing")
+		assert.Equal(t, RefreshRerun, wf.Feedback.rerun, "rerun not set")
+		require.Equal(t, JobSucceeded, waitForJob(t, job, 5*time.Second).State, "refresh failed")
+
+		// stale data
+		require.Nil(t, c.Store(name, []byte("stale")), "store data")
+		require.Nil(t, os.Chtimes(c.path(name), then, then), "set mtime")
+		wf.Feedback.rerun = 0
+
+		data, refreshing, err = wf.LoadOrRefresh(c, name, maxAge, refresh())
+		require.Nil(t, err, "load/refresh failed")
+		assert.Equal(t, []byte("stale"), data, "unexpected data")
+		assert.True(t, refreshing, "cache not refreshing")
+		assert.Equal(t, RefreshRerun, wf.Feedback.rerun, "rerun not set")
+		require.Equal(t, JobSucceeded, waitForJob(t, job, 5*time.Second).State, "refresh failed")
+
+		// fresh data
+		wf.Feedback.rerun = 0
+		data, refreshing, err = wf.LoadOrRefresh(c, name, maxAge, refresh())
+		require.Nil(t, err, "load/refresh failed")
+		assert.Equal(t, []byte("fresh"), data, "unexpected data")
+		assert.False(t, refreshing, "cache refreshing")
+		assert.Equal(t, 0.0, wf.Feedback.rerun, "rerun set")
+
+		// maxAge 0 never refreshes existing data
+		require.Nil(t, os.Chtimes(c.path(name), then, then), "set mtime")
+		_, refreshing, err = wf.LoadOrRefresh(c, name, 0, refresh())
+		require.Nil(t, err, "load/refresh failed")
+		assert.False(t, refreshing, "cache refreshing")
+	})
+}
+
+// A failed refresh isn't retried until RefreshRetry has passed.
+func TestWorkflow_LoadOrRefresh_failed(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		var (
+			c    = wf.Cache
+			name = "test.txt"
+			job  = wf.Job(refreshJobName(c, name))
+		)
+		fail := func() *exec.Cmd { return exec.Command("/bin/sh", "-c", "echo 'no network' >&2; exit 2") }
+
+		_, refreshing, err := wf.LoadOrRefresh(c, name, time.Minute, fail())
+		require.Nil(t, err, "load/refresh failed")
+		assert.True(t, refreshing, "cache not refreshing")
+		st := waitForJob(t, job, 5*time.Second)
+		require.Equal(t, JobFailed, st.State, "refresh didn't fail")
+		started := st.Started
+
+		// failed job isn't restarted and error is shown
+		wf.Feedback.rerun = 0
+		data, refreshing, err := wf.LoadOrRefresh(c, name, time.Minute, fail())
+		require.Nil(t, err, "load/refresh failed")
+		assert.Nil(t, data, "unexpected data")
+		assert.False(t, refreshing, "cache refreshing")
+		assert.Equal(t, 0.0, wf.Feedback.rerun, "rerun set")
+		assert.Equal(t, started, job.Status().Started, "refresh restarted")
+		require.Equal(t, 1, len(wf.Feedback.Items), "no warning item")
+		it := wf.Feedback.Items[0]
+		assert.Equal(t, `Couldn't refresh "test.txt"`, it.title, "unexpected title")
+		assert.Equal(t, "no network", *it.subtitle, "unexpected subtitle")
+		assert.False(t, it.valid, "warning is valid")
+	})
+}
+
+// JSON data are unmarshalled.
+func TestWorkflow_LoadOrRefreshJSON(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		name := "test.json"
+		require.Nil(t, wf.Data.StoreJSON(name, &TestData{A: "a"}), "store data")
+
+		v := &TestData{}
+		refreshing, err := wf.LoadOrRefreshJSON(wf.Data, name, 0, exec.Command("/usr/bin/true"), v)
+		require.Nil(t, err, "load/refresh failed")
+		assert.False(t, refreshing, "cache refreshing")
+		assert.Equal(t, "a", v.A, "unexpected data")
+	})
+}
+
+// Caches with the same name in different directories have different jobs.
+func TestRefreshJobName(t *testing.T) {
+	t.Parallel()
+
+	a := refreshJobName(&Cache{Dir: "/tmp/a"}, "dir/name")
+	b := refreshJobName(&Cache{Dir: "/tmp/b"}, "dir/name")
+	assert.NotEqual(t, a, b, "job names are the same")
+	assert.NotContains(t, a, "/", "job name contains slash")
+}
@@ -130,12 +130,20 @@ func (wf *Workflow) SendFeedback() *Workflow {
 	// Set session ID
 	wf.Var("AW_SESSION_ID", wf.SessionID())
 
-	// Truncate Items if maxResults is set
-	if wf.maxResults > 0 && len(wf.Feedback.Items) > wf.maxResults {
+	// Split Items into pages or truncate them if maxResults is set.
+	// maxResults is ignored when paginating, so all pages are reachable.
+	if wf.pageSize > 0 {
+		wf.Feedback.Paginate(wf.pageQuery, wf.page, wf.pageSize)
+	} else if wf.maxResults > 0 && len(wf.Feedback.Items) > wf.maxResults {
 		wf.Feedback.Items = wf.Feedback.Items[0:wf.maxResults]
 	}
+	wf.Feedback.Group()
 
-	if err := wf.Feedback.Send(); err != nil {
+	// Omit JSON fields the running version of Alfred doesn't understand
+	wf.Feedback.version = parseAlfredVersion(wf.Config.Get(EnvVarAlfredVersion))
+	wf.Feedback.compact = wf.compactJSON && !wf.Debug()
+
+	if err := wf.Feedback.send(wf.stdout()); err != nil {
 		log.Fatalf("Error generating JSON : %v", err)
 	}
 
@@ -52,7 +52,7 @@ func TestNewFileItem(t *testing.T) {
 	assert.Equal(t, "info.plist", it.title, "unexpected title")
 	assert.Equal(t, ipShort, *it.subtitle, "unexpected subtitle")
 	assert.Equal(t, ipPath, *it.uid, "unexpected UID")
-	assert.True(t, it.file, "unexpected file")
+	assert.Equal(t, TypeFile, it.typ, "unexpected type")
 	assert.Equal(t, IconType("fileicon"), it.icon.Type, "unexpected value type")
 	assert.Equal(t, ipPath, it.icon.Value, "unexpected icon value")
 }
@@ -3,7 +3,12 @@
 
 package aw
 
-import "go.deanishe.net/fuzzy"
+import (
+	"io"
+	"time"
+
+	"go.deanishe.net/fuzzy"
+)
 
 // Option is a configuration option for Workflow.
 // Pass one or more Options to New() or Workflow.Configure().
@@ -85,7 +90,8 @@ func MaxLogSize(bytes int) Option {
 }
 
 // MaxResults is the maximum number of results to send to Alfred.
-// 0 means send all results.
+// 0 means send all results. It is ignored if PageSize is set: use
+// PageSize to make all results reachable.
 // Default: 0
 func MaxResults(num int) Option {
 	return func(wf *Workflow) Option {
@@ -95,6 +101,69 @@ func MaxResults(num int) Option {
 	}
 }
 
+// PageSize sets the number of results per page sent by
+// Workflow.SendFeedback. If there are more results, a "More results…"
+// Item is added, which autocompletes to the query plus a page marker that
+// Workflow.Args removes. 0 means don't paginate. MaxResults has no
+// effect on paginated results.
+// Default: 0
+func PageSize(num int) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.pageSize
+		wf.pageSize = num
+		return PageSize(prev)
+	}
+}
+
+// UseConfigBackend sets how Workflow.Config saves workflow variables.
+// See ConfigBackend.
+// Default: BackendAuto
+func UseConfigBackend(b ConfigBackend) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Config.Backend
+		wf.Config.Backend = b
+		return UseConfigBackend(prev)
+	}
+}
+
+// CompactJSON tells Workflow to send feedback to Alfred without
+// indentation, which is faster and smaller for large result sets.
+// Feedback is still indented when debugging (i.e. when alfred_debug is
+// set), so it remains readable in Alfred's debugger.
+// Default: false
+func CompactJSON(on bool) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.compactJSON
+		wf.compactJSON = on
+		return CompactJSON(prev)
+	}
+}
+
+// RunTimeout sets the deadline of the Context passed to the function
+// called by Workflow.RunContext. 0 means no deadline.
+// Default: 0
+func RunTimeout(d time.Duration) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.runTimeout
+		wf.runTimeout = d
+		return RunTimeout(prev)
+	}
+}
+
+// UseHistory sets whether Workflow.Filter blends the frecency scores
+// from Workflow.History() into the fuzzy match scores of Items.
+func UseHistory(on bool) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Feedback.History != nil
+		if on {
+			wf.Feedback.History = wf.History()
+		} else {
+			wf.Feedback.History = nil
+		}
+		return UseHistory(prev)
+	}
+}
+
 // TextErrors tells Workflow to print errors as text, not JSON.
 // Messages are still sent to STDOUT. Set to true if error
 // should be captured by Alfred, e.g. if output goes to a Notification.
@@ -106,6 +175,35 @@ func TextErrors(on bool) Option {
 	}
 }
 
+// Output sets the writer Script Filter feedback (and text errors) are
+// written to. If w is nil, feedback is written to STDOUT.
+//
+// Default: os.Stdout
+//
+// This is mostly useful for testing. See subpackage awtest.
+func Output(w io.Writer) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.output
+		wf.output = w
+		return Output(prev)
+	}
+}
+
+// ExitFunc sets the function Workflow calls to terminate the program after
+// a fatal error or a magic action has been run. If fn is nil, os.Exit is
+// called.
+//
+// Default: os.Exit
+//
+// This is mostly useful for testing. See subpackage awtest.
+func ExitFunc(fn func(code int)) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.exitFunc
+		wf.exitFunc = fn
+		return ExitFunc(prev)
+	}
+}
+
 // SortOptions sets the fuzzy sorting options for Workflow.Filter().
 // See fuzzy and fuzzy.Option for info on (configuring) the sorting
 // algorithm.
@@ -133,6 +231,29 @@ func SessionName(name string) Option {
 	}
 }
 
+// UseMatcher tells Workflow.Filter to match Items with m instead of
+// fuzzy matching. Pass nil to use fuzzy matching again. SortOptions are
+// ignored while a Matcher is set. See Matcher.
+func UseMatcher(m Matcher) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Feedback.Matcher
+		wf.Feedback.Matcher = m
+		return UseMatcher(prev)
+	}
+}
+
+// SortFields tells Workflow.Filter to match the query against Items'
+// titles, subtitles, match fields and keywords, weighted by w.
+// Pass nil to only match titles/match fields again.
+// See FieldWeights.
+func SortFields(w *FieldWeights) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Feedback.Weights
+		wf.Feedback.Weights = w
+		return SortFields(prev)
+	}
+}
+
 // SuppressUIDs prevents UIDs from being set on feedback Items.
 //
 // This turns off Alfred's knowledge, i.e. prevents Alfred from
@@ -4,6 +4,7 @@
 package aw
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,7 +12,9 @@ import (
 	"log"
 	"os"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -52,6 +55,34 @@ func TestNew(t *testing.T) {
 			MaxResults(10),
 			func(wf *Workflow) bool { return wf.maxResults == 10 },
 			"Set MaxResults"},
+		{
+			UseConfigBackend(BackendInfoPlist),
+			func(wf *Workflow) bool { return wf.Config.Backend == BackendInfoPlist },
+			"Set UseConfigBackend"},
+		{
+			PageSize(20),
+			func(wf *Workflow) bool { return wf.pageSize == 20 },
+			"Set PageSize"},
+		{
+			RunTimeout(time.Second),
+			func(wf *Workflow) bool { return wf.runTimeout == time.Second },
+			"Set RunTimeout"},
+		{
+			CompactJSON(true),
+			func(wf *Workflow) bool { return wf.compactJSON },
+			"Set CompactJSON"},
+		{
+			SortFields(&DefaultFieldWeights),
+			func(wf *Workflow) bool { return wf.Feedback.Weights == &DefaultFieldWeights },
+			"Set SortFields"},
+		{
+			UseMatcher(PrefixMatcher{}),
+			func(wf *Workflow) bool { return wf.Feedback.Matcher == PrefixMatcher{} },
+			"Set UseMatcher"},
+		{
+			UseHistory(true),
+			func(wf *Workflow) bool { return wf.Feedback.History == wf.History() },
+			"Set UseHistory"},
 		{
 			LogPrefix("blah"),
 			func(wf *Workflow) bool { return wf.logPrefix == "blah" },
@@ -121,6 +152,59 @@ func TestWorkflow_Run_Rescue(t *testing.T) {
 	})
 }
 
+// RunContext passes a Context to fn and treats errors as fatal.
+func TestWorkflow_RunContext(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		var called bool
+		wf.RunContext(func(ctx context.Context) error {
+			called = true
+			assert.Nil(t, ctx.Err(), "context already done")
+			return nil
+		})
+		assert.True(t, called, "fn wasn't called")
+
+		me := &mockExit{}
+		exitFunc = me.Exit
+		defer func() { exitFunc = os.Exit }()
+		wf.RunContext(func(ctx context.Context) error { return errors.New("oops") })
+		assert.Equal(t, 1, me.code, "error did not exit")
+	})
+}
+
+// RunContext's Context expires after RunTimeout.
+func TestWorkflow_RunContext_timeout(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		wf.Configure(RunTimeout(10 * time.Millisecond))
+		wf.RunContext(func(ctx context.Context) error {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok, "no deadline set")
+			select {
+			case <-ctx.Done():
+				assert.Equal(t, context.DeadlineExceeded, ctx.Err(), "unexpected error")
+			case <-time.After(time.Second):
+				t.Error("context not cancelled")
+			}
+			return nil
+		})
+	})
+}
+
+// RunContext's Context is cancelled by SIGINT.
+func TestWorkflow_RunContext_signal(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		wf.RunContext(func(ctx context.Context) error {
+			require.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGINT), "send SIGINT")
+			select {
+			case <-ctx.Done():
+				assert.Equal(t, context.Canceled, ctx.Err(), "unexpected error")
+			case <-time.After(time.Second):
+				t.Error("context not cancelled")
+			}
+			return nil
+		})
+	})
+}
+
 // TestWorkflowDir verifies that AwGo finds the right directory.
 func TestWorkflow_Dir(t *testing.T) {
 	t.Parallel()